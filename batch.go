@@ -0,0 +1,161 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// BatchOK is the status of a line whose output isn't a ResponseMsg
+	BatchOK = "ok"
+	// BatchError is the status of a line which failed
+	BatchError = "error"
+	// MaxBatchLine is the longest JSON line RunBatch reads
+	MaxBatchLine = 64 * 1024 * 1024
+)
+
+// BatchResult is the outcome of one line of a JSON Lines batch. Input is the
+// line so failed lines can be selected and run again. Response is set when
+// the output was a ResponseMsg (e.g. from create, update or delete),
+// otherwise Output holds the JSON the line returned.
+type BatchResult struct {
+	Line     int             `json:"line"`
+	URI      string          `json:"uri,omitempty"`
+	Status   string          `json:"status"`
+	Response *ResponseMsg    `json:"response,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Input    json.RawMessage `json:"input"`
+}
+
+// BatchFunc runs one line of a batch and returns its output
+type BatchFunc func(ctx context.Context, payload string) (string, error)
+
+// batchResult describes the outcome of fn for line number n
+func batchResult(n int, payload, output string, err error) *BatchResult {
+	result := &BatchResult{Line: n, Status: BatchOK, Input: json.RawMessage(payload)}
+	in := struct {
+		URI string `json:"uri"`
+	}{}
+	if json.Unmarshal([]byte(payload), &in) == nil {
+		result.URI = in.URI
+	} else {
+		result.Input, _ = json.Marshal(payload)
+	}
+	if err != nil {
+		result.Status, result.Error = BatchError, err.Error()
+		return result
+	}
+	output = strings.TrimSpace(output)
+	msg := new(ResponseMsg)
+	if json.Unmarshal([]byte(output), msg) == nil && msg.Status != "" {
+		result.Response, result.Status = msg, msg.Status
+		if msg.URI != "" {
+			result.URI = msg.URI
+		}
+		if msg.Error != nil {
+			result.Status, result.Error = BatchError, fmt.Sprintf("%v", msg.Error)
+		}
+	} else if output != "" && json.Valid([]byte(output)) == true {
+		result.Output = json.RawMessage(output)
+	} else if output != "" {
+		result.Output, _ = json.Marshal(output)
+	}
+	return result
+}
+
+// RunBatch calls fn with each non-blank line read from in using up to
+// workers go routines and writes one BatchResult JSON line per input line
+// to out as each finishes. It returns the number of lines which failed.
+func RunBatch(ctx context.Context, in io.Reader, out io.Writer, workers int, fn BatchFunc) (int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > MaxExportWorkers {
+		workers = MaxExportWorkers
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failed  int
+		written error
+	)
+	type batchLine struct {
+		n       int
+		payload string
+	}
+	enc := json.NewEncoder(out)
+	lines := make(chan *batchLine)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range lines {
+				output, err := fn(ctx, line.payload)
+				result := batchResult(line.n, line.payload, output, err)
+				mu.Lock()
+				if result.Status == BatchError {
+					failed++
+				}
+				if err := enc.Encode(result); err != nil && written == nil {
+					written = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), MaxBatchLine)
+	n := 0
+	for scanner.Scan() {
+		n++
+		payload := strings.TrimSpace(scanner.Text())
+		if payload == "" {
+			continue
+		}
+		select {
+		case lines <- &batchLine{n: n, payload: payload}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(lines)
+	wg.Wait()
+	if err := scanner.Err(); err != nil {
+		return failed, fmt.Errorf("Can't read batch line %d, %w", n+1, err)
+	}
+	if ctx.Err() != nil {
+		return failed, fmt.Errorf("Batch stopped after line %d, %w", n, ctx.Err())
+	}
+	if written != nil {
+		return failed, fmt.Errorf("Can't write batch results, %w", written)
+	}
+	return failed, nil
+}
@@ -20,7 +20,7 @@
 package cait
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -66,7 +66,6 @@ func getenv(envvar, defaultValue string) string {
 func New(apiURL, username, password, dataset string) *ArchivesSpaceAPI {
 	api := new(ArchivesSpaceAPI)
 	api.BaseURL, _ = url.Parse(getenv("CAIT_API_URL", apiURL))
-	api.CallURL, _ = url.Parse(getenv("CAIT_API_URL", apiURL))
 	api.AuthToken = getenv("CAIT_API_TOKEN", "")
 	api.Username = getenv("CAIT_USERNAME", username)
 	api.Password = getenv("CAIT_PASSWORD", password)
@@ -75,18 +74,50 @@ func New(apiURL, username, password, dataset string) *ArchivesSpaceAPI {
 	api.Htdocs = getenv("CAIT_HTDOCS", "htdocs")
 	api.HtdocsIndex = getenv("CAIT_HTDOCS_INDEX", "htdocs.bleve")
 	api.Templates = getenv("CAIT_TEMPLATES", "templates/default")
+	api.HTTPClient = &http.Client{}
+	api.Retry = DefaultRetryPolicy()
 	return api
 }
 
-// UpdateCallPath takes the BaseURL Path attribute, copies it into CallURL, applies appends a path for next API call
-func (api *ArchivesSpaceAPI) UpdateCallPath(p string) string {
-	api.CallURL.Path = api.BaseURL.Path + p
-	return api.CallURL.Path
+// CallURL returns the URL for an API call by appending path p to BaseURL
+// and encoding the query q (q may be nil). BaseURL is copied so CallURL is
+// safe to use from concurrent go routines.
+func (api *ArchivesSpaceAPI) CallURL(p string, q url.Values) string {
+	u := *api.BaseURL
+	u.Path = api.BaseURL.Path + p
+	if q != nil {
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// client returns the http.Client used for requests
+func (api *ArchivesSpaceAPI) client() *http.Client {
+	if api.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return api.HTTPClient
+}
+
+// token returns the current session token
+func (api *ArchivesSpaceAPI) token() string {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.AuthToken
+}
+
+// setToken replaces the session token returning the previous one
+func (api *ArchivesSpaceAPI) setToken(token string) string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	old := api.AuthToken
+	api.AuthToken = token
+	return old
 }
 
 // IsAuth returns true if the auth token has been set, false otherwise
 func (api *ArchivesSpaceAPI) IsAuth() bool {
-	if api.AuthToken == "" {
+	if api.token() == "" {
 		return false
 	}
 	return true
@@ -94,64 +125,70 @@ func (api *ArchivesSpaceAPI) IsAuth() bool {
 
 // Login authenticates against the ArchivesSpace REST API setting the AuthToken
 // value in the ArchivesSpaceAPI struct.
-func (api *ArchivesSpaceAPI) Login() error {
+func (api *ArchivesSpaceAPI) Login(ctx context.Context) error {
 	// See https://golang.org/pkg/net/url/#pkg-examples for example building a URL from parts.
 	// Command line example: curl -F "password=admin" "http://localhost:8089/users/admin/login"
 	var data map[string]interface{}
 
 	// If we already have a token set then logout and get a new one
 	if api.IsAuth() == true {
-		api.Logout()
+		api.Logout(ctx)
 	}
 
-	api.UpdateCallPath(fmt.Sprintf("/users/%s/login", api.Username))
 	form := url.Values{}
 	form.Add("password", api.Password)
+	req, err := http.NewRequestWithContext(ctx, "POST", api.CallURL(fmt.Sprintf("/users/%s/login", api.Username), nil), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	res, err := http.PostForm(api.CallURL.String(), form)
+	res, err := api.client().Do(req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
-	if res.Status != "200 OK" {
-		return fmt.Errorf("ArchivesSpace returned HTTP status %s", res.Status)
-	}
 	content, err := ioutil.ReadAll(res.Body)
 
 	if err != nil {
-		return fmt.Errorf("ArchivesSpace return unreadable body: %s", err)
+		return fmt.Errorf("ArchivesSpace return unreadable body: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return newAPIError("POST", req.URL.String(), res, content)
 	}
 
 	if err = json.Unmarshal(content, &data); err != nil {
-		return fmt.Errorf("Can't process JSON response %s\n\t%s", content, err)
+		return fmt.Errorf("Can't process JSON response %s\n\t%w", content, err)
+	}
+	session, ok := data["session"].(string)
+	if ok == false {
+		return fmt.Errorf("Login response missing session, %s", content)
 	}
-	api.AuthToken = data["session"].(string)
+	api.setToken(session)
 	return nil
 }
 
 // Logout clear the authentication token for the session with the API
-func (api *ArchivesSpaceAPI) Logout() error {
+func (api *ArchivesSpaceAPI) Logout(ctx context.Context) error {
 	// Save the token and invalidate the one in our cait struct.
-	token := api.AuthToken
-	api.AuthToken = ""
+	token := api.setToken("")
 	// Using the copied token try to logout from the service.
-	api.UpdateCallPath(`/logout`)
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", api.CallURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", api.CallURL(`/logout`, nil), nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Add("X-ArchivesSpace-Session", token)
-	_, err = client.Do(req)
+	res, err := api.client().Do(req)
 	if err != nil {
 		return err
 	}
+	res.Body.Close()
 	return nil
 }
 
 // API the common HTTP request processing for interacting with ArchivesSpaceAPI
-func (api *ArchivesSpaceAPI) API(method string, url string, data interface{}) ([]byte, error) {
+func (api *ArchivesSpaceAPI) API(ctx context.Context, method string, url string, data interface{}) ([]byte, error) {
 	var (
 		payload []byte
 		err     error
@@ -159,106 +196,87 @@ func (api *ArchivesSpaceAPI) API(method string, url string, data interface{}) ([
 	if data != nil {
 		payload, err = json.Marshal(data)
 		if err != nil {
-			return nil, fmt.Errorf("API(%q, %q, data), %s", method, url, err)
-		}
-	}
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
-	if err != nil {
-		return nil, fmt.Errorf("Can't create request: %s", err)
-	}
-	req.Header.Add("X-ArchivesSpace-Session", api.AuthToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	if method == "POST" {
-		res, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("Request error: %s", err)
+			return nil, fmt.Errorf("API(%q, %q, data), %w", method, redactURL(url), err)
 		}
-		defer res.Body.Close()
-		content, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("Read body error: %s", err)
-		}
-		return content, nil
 	}
-	res, err := client.Do(req)
+	res, content, err := api.doWithRetry(ctx, method, url, "application/json", payload)
 	if err != nil {
-		return nil, fmt.Errorf("Request error: %s", err)
+		return nil, fmt.Errorf("Request error: %w", err)
 	}
-	defer res.Body.Close()
-	if res.Status != "200 OK" {
-		return nil, fmt.Errorf("ArchiveSpace API error %s", res.Status)
-	}
-	content, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("Read body error: %s", err)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, newAPIError(method, url, res, content)
 	}
 	return content, nil
 }
 
 // CreateAPI is a generalized call to create an object form an interface.
-func (api *ArchivesSpaceAPI) CreateAPI(url string, obj interface{}) (*ResponseMsg, error) {
-	content, err := api.API("POST", url, obj)
+func (api *ArchivesSpaceAPI) CreateAPI(ctx context.Context, url string, obj interface{}) (*ResponseMsg, error) {
+	if err := api.validate(ctx, obj); err != nil {
+		return nil, err
+	}
+	content, err := api.API(ctx, "POST", url, obj)
 	if err != nil {
-		return nil, fmt.Errorf("Create API, %s, %s", content, err)
+		return nil, fmt.Errorf("Create API, %w", err)
 	}
 	data := new(ResponseMsg)
 	err = json.Unmarshal(content, data)
 	if err != nil {
-		return nil, fmt.Errorf("Create API, unmarshal response msg, %s", err)
+		return nil, fmt.Errorf("Create API, unmarshal response msg, %w", err)
 	}
 	return data, nil
 }
 
 // GetAPI is a generalized call to get a specific object from an interface
 // obj is modified as a side effect
-func (api *ArchivesSpaceAPI) GetAPI(url string, obj interface{}) error {
-	content, err := api.API("GET", url, nil)
+func (api *ArchivesSpaceAPI) GetAPI(ctx context.Context, url string, obj interface{}) error {
+	content, err := api.API(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
 	err = json.Unmarshal(content, obj)
 	if err != nil {
-		return fmt.Errorf("unmarshal error %s, %s\n", content, err)
+		return fmt.Errorf("unmarshal error %s, %w\n", content, err)
 	}
 	return nil
 }
 
 // UpdateAPI is a generalized call to update an object from an interface.
-func (api *ArchivesSpaceAPI) UpdateAPI(url string, obj interface{}) (*ResponseMsg, error) {
-	content, err := api.API("POST", url, obj)
+func (api *ArchivesSpaceAPI) UpdateAPI(ctx context.Context, url string, obj interface{}) (*ResponseMsg, error) {
+	if err := api.validate(ctx, obj); err != nil {
+		return nil, err
+	}
+	content, err := api.API(ctx, "POST", url, obj)
 	if err != nil {
-		return nil, fmt.Errorf("UpdateAPI(%q, obj) %s", url, err)
+		return nil, fmt.Errorf("UpdateAPI(%q, obj) %w", redactURL(url), err)
 	}
 	data := new(ResponseMsg)
 	err = json.Unmarshal(content, data)
 	if err != nil {
-		return nil, fmt.Errorf("Could not unpack UpdateAPI() response [%s] %s", content, err)
+		return nil, fmt.Errorf("Could not unpack UpdateAPI() response [%s] %w", content, err)
 	}
 	return data, nil
 }
 
 // DeleteAPI is a generalized call to update an object form an interface
-func (api *ArchivesSpaceAPI) DeleteAPI(url string, obj interface{}) (*ResponseMsg, error) {
-	content, err := api.API("DELETE", url, obj)
+func (api *ArchivesSpaceAPI) DeleteAPI(ctx context.Context, url string, obj interface{}) (*ResponseMsg, error) {
+	content, err := api.API(ctx, "DELETE", url, obj)
 	if err != nil {
-		return nil, fmt.Errorf("DeleteAPI(%q, obj) %s", url, err)
+		return nil, fmt.Errorf("DeleteAPI(%q, obj) %w", redactURL(url), err)
 	}
 
 	data := new(ResponseMsg)
 	err = json.Unmarshal(content, data)
 	if err != nil {
-		return nil, fmt.Errorf("Cannnot decode DeleteAPI() response %s", err)
+		return nil, fmt.Errorf("Cannnot decode DeleteAPI() response %w", err)
 	}
 	return data, nil
 }
 
 // ListAPI return a list of IDs from ArchivesSpace for given URL
-func (api *ArchivesSpaceAPI) ListAPI(url string) ([]int, error) {
-	content, err := api.API("GET", url, nil)
+func (api *ArchivesSpaceAPI) ListAPI(ctx context.Context, url string) ([]int, error) {
+	content, err := api.API(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("ListAPI(%q) %s", url, err)
+		return nil, fmt.Errorf("ListAPI(%q) %w", url, err)
 	}
 
 	// content should look something like
@@ -266,7 +284,7 @@ func (api *ArchivesSpaceAPI) ListAPI(url string) ([]int, error) {
 	var ids []int
 	err = json.Unmarshal(content, &ids)
 	if err != nil {
-		return nil, fmt.Errorf("ListAPI(%q) %s", url, err)
+		return nil, fmt.Errorf("ListAPI(%q) %w", url, err)
 	}
 	return ids, nil
 }
@@ -274,48 +292,43 @@ func (api *ArchivesSpaceAPI) ListAPI(url string) ([]int, error) {
 // CreateRepository will create a repository via the REST API for
 // ArchivesSpace defined in the ArchivesSpaceAPI struct.
 // It will return the created record.
-func (api *ArchivesSpaceAPI) CreateRepository(repo *Repository) (*ResponseMsg, error) {
-	api.UpdateCallPath("/repositories")
-	return api.CreateAPI(api.CallURL.String(), repo)
+func (api *ArchivesSpaceAPI) CreateRepository(ctx context.Context, repo *Repository) (*ResponseMsg, error) {
+	return api.CreateAPI(ctx, api.CallURL("/repositories", nil), repo)
 }
 
 // GetRepository returns the repository details based on Id
-func (api *ArchivesSpaceAPI) GetRepository(id int) (*Repository, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/repositories/%d`, id))
+func (api *ArchivesSpaceAPI) GetRepository(ctx context.Context, id int) (*Repository, error) {
 	repo := new(Repository)
-	err := api.GetAPI(api.CallURL.String(), repo)
+	err := api.GetAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d`, id), nil), repo)
 	if err != nil {
-		return nil, fmt.Errorf("GetRepostiory(%d) %s", id, err)
+		return nil, fmt.Errorf("GetRepostiory(%d) %w", id, err)
 	}
 	repo.ID = URIToID(repo.URI)
 	return repo, nil
 }
 
 // UpdateRepository takes a repository structure and sends it to the ArchivesSpace REST API
-func (api *ArchivesSpaceAPI) UpdateRepository(repo *Repository) (*ResponseMsg, error) {
-	api.UpdateCallPath(repo.URI)
-	return api.UpdateAPI(api.CallURL.String(), repo)
+func (api *ArchivesSpaceAPI) UpdateRepository(ctx context.Context, repo *Repository) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(repo.URI, nil), repo)
 }
 
 // DeleteRepository takes a repository structure and sends it to the ArchivesSpace REST API
-func (api *ArchivesSpaceAPI) DeleteRepository(repo *Repository) (*ResponseMsg, error) {
-	api.UpdateCallPath(fmt.Sprintf("/repositories/%d", repo.ID))
-	return api.DeleteAPI(api.CallURL.String(), repo)
+func (api *ArchivesSpaceAPI) DeleteRepository(ctx context.Context, repo *Repository) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d", repo.ID), nil), repo)
 }
 
 // ListRepositoryIDs returns the numeric ids for all respoistories via the ArchivesSpace REST API
-func (api *ArchivesSpaceAPI) ListRepositoryIDs() ([]int, error) {
+func (api *ArchivesSpaceAPI) ListRepositoryIDs(ctx context.Context) ([]int, error) {
 	var ids []int
 	var repos []Repository
 
-	api.UpdateCallPath(`/repositories`)
-	content, err := api.API("GET", api.CallURL.String(), nil)
+	content, err := api.API(ctx, "GET", api.CallURL(`/repositories`, nil), nil)
 	if err != nil {
-		return nil, fmt.Errorf("ListRepositoryIDs() %s", err)
+		return nil, fmt.Errorf("ListRepositoryIDs() %w", err)
 	}
 	err = json.Unmarshal(content, &repos)
 	if err != nil {
-		return nil, fmt.Errorf("ListRepositoryIDs() %s", err)
+		return nil, fmt.Errorf("ListRepositoryIDs() %w", err)
 	}
 	// Now I need to populate out id list
 	for i := range repos {
@@ -327,18 +340,16 @@ func (api *ArchivesSpaceAPI) ListRepositoryIDs() ([]int, error) {
 }
 
 // ListRepositories returns a list of repositories available via the ArchivesSpace REST API
-func (api *ArchivesSpaceAPI) ListRepositories() ([]Repository, error) {
-	api.UpdateCallPath(`/repositories`)
-
-	content, err := api.API("GET", api.CallURL.String(), nil)
+func (api *ArchivesSpaceAPI) ListRepositories(ctx context.Context) ([]Repository, error) {
+	content, err := api.API(ctx, "GET", api.CallURL(`/repositories`, nil), nil)
 	if err != nil {
-		return nil, fmt.Errorf("ListRepositories() %s", err)
+		return nil, fmt.Errorf("ListRepositories() %w", err)
 	}
 
 	var repos []Repository
 	err = json.Unmarshal(content, &repos)
 	if err != nil {
-		return nil, fmt.Errorf("ListRepositories() %s", err)
+		return nil, fmt.Errorf("ListRepositories() %w", err)
 	}
 	// Now I need to populate the repos[?].ID fields
 	for i := range repos {
@@ -348,172 +359,151 @@ func (api *ArchivesSpaceAPI) ListRepositories() ([]Repository, error) {
 }
 
 // CreateAgent creates a Agent recod via the ArchivesSpace API
-func (api *ArchivesSpaceAPI) CreateAgent(aType string, agent *Agent) (*ResponseMsg, error) {
-	api.UpdateCallPath(fmt.Sprintf("/agents/%s", aType))
+func (api *ArchivesSpaceAPI) CreateAgent(ctx context.Context, aType string, agent *Agent) (*ResponseMsg, error) {
 	agent.LockVersion = "0"
-	return api.CreateAPI(api.CallURL.String(), agent)
+	return api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/agents/%s", aType), nil), agent)
 }
 
 // GetAgent return an Agent via the ArchivesSpace API
-func (api *ArchivesSpaceAPI) GetAgent(agentType string, agentID int) (*Agent, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/agents/%s/%d`, agentType, agentID))
-
+func (api *ArchivesSpaceAPI) GetAgent(ctx context.Context, agentType string, agentID int) (*Agent, error) {
 	agent := new(Agent)
-	err := api.GetAPI(api.CallURL.String(), agent)
+	err := api.GetAPI(ctx, api.CallURL(fmt.Sprintf(`/agents/%s/%d`, agentType, agentID), nil), agent)
 	if err != nil {
-		return nil, fmt.Errorf("GetAgent(%s, %d) %s", agentType, agentID, err)
+		return nil, fmt.Errorf("GetAgent(%s, %d) %w", agentType, agentID, err)
 	}
 	agent.ID = URIToID(agent.URI)
 	return agent, nil
 }
 
 // UpdateAgent creates a Agent recod via the ArchivesSpace API
-func (api *ArchivesSpaceAPI) UpdateAgent(agent *Agent) (*ResponseMsg, error) {
-	api.UpdateCallPath(agent.URI)
-	return api.UpdateAPI(api.CallURL.String(), agent)
+func (api *ArchivesSpaceAPI) UpdateAgent(ctx context.Context, agent *Agent) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(agent.URI, nil), agent)
 }
 
 // DeleteAgent creates a Agent record via the ArchivesSpace API
-func (api *ArchivesSpaceAPI) DeleteAgent(agent *Agent) (*ResponseMsg, error) {
-	api.UpdateCallPath(agent.URI)
-	return api.DeleteAPI(api.CallURL.String(), agent)
+func (api *ArchivesSpaceAPI) DeleteAgent(ctx context.Context, agent *Agent) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(agent.URI, nil), agent)
 }
 
 // ListAgents return an array of Agents via the ArchivesSpace API
-func (api *ArchivesSpaceAPI) ListAgents(agentType string) ([]int, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/agents/%s`, agentType))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListAgents(ctx context.Context, agentType string) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	return api.ListAPI(api.CallURL.String())
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/agents/%s`, agentType), q))
 }
 
 // CreateAccession creates a new Accession record in a Repository
-func (api *ArchivesSpaceAPI) CreateAccession(repoID int, accession *Accession) (*ResponseMsg, error) {
-	api.UpdateCallPath(fmt.Sprintf("/repositories/%d/accessions", repoID))
+func (api *ArchivesSpaceAPI) CreateAccession(ctx context.Context, repoID int, accession *Accession) (*ResponseMsg, error) {
 	accession.LockVersion = "0"
-	return api.CreateAPI(api.CallURL.String(), accession)
+	return api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/accessions", repoID), nil), accession)
 }
 
 // GetAccession retrieves an Accession record from a Repository
-func (api *ArchivesSpaceAPI) GetAccession(repoID, accessionID int) (*Accession, error) {
-	api.UpdateCallPath(fmt.Sprintf("/repositories/%d/accessions/%d", repoID, accessionID))
-
+func (api *ArchivesSpaceAPI) GetAccession(ctx context.Context, repoID, accessionID int) (*Accession, error) {
 	accession := new(Accession)
-	err := api.GetAPI(api.CallURL.String(), accession)
+	err := api.GetAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/accessions/%d", repoID, accessionID), nil), accession)
 	if err != nil {
-		return nil, fmt.Errorf("GetAccession(%d, %d) %s", repoID, accessionID, err)
+		return nil, fmt.Errorf("GetAccession(%d, %d) %w", repoID, accessionID, err)
 	}
 	p := strings.Split(accession.URI, "/")
 	accession.ID, err = strconv.Atoi(p[len(p)-1])
 	if err != nil {
-		return accession, fmt.Errorf("Accession ID parse error %d %s", accession.ID, err)
+		return accession, fmt.Errorf("Accession ID parse error %d %w", accession.ID, err)
 	}
 	return accession, nil
 }
 
 // UpdateAccession updates an existing Accession record in a Repository
-func (api *ArchivesSpaceAPI) UpdateAccession(accession *Accession) (*ResponseMsg, error) {
-	api.UpdateCallPath(accession.URI)
-	return api.UpdateAPI(api.CallURL.String(), accession)
+func (api *ArchivesSpaceAPI) UpdateAccession(ctx context.Context, accession *Accession) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(accession.URI, nil), accession)
 }
 
 // DeleteAccession deleted an Accession record from a Repository
-func (api *ArchivesSpaceAPI) DeleteAccession(accession *Accession) (*ResponseMsg, error) {
-	api.UpdateCallPath(accession.URI)
-	return api.DeleteAPI(api.CallURL.String(), accession)
+func (api *ArchivesSpaceAPI) DeleteAccession(ctx context.Context, accession *Accession) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(accession.URI, nil), accession)
 }
 
 // ListAccessions return a list of Accession IDs from a Repository
-func (api *ArchivesSpaceAPI) ListAccessions(repositoryID int) ([]int, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/repositories/%d/accessions`, repositoryID))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListAccessions(ctx context.Context, repositoryID int) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	return api.ListAPI(api.CallURL.String())
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/accessions`, repositoryID), q))
 }
 
 // CreateSubject creates a new Subject in ArchivesSpace
-func (api *ArchivesSpaceAPI) CreateSubject(subject *Subject) (*ResponseMsg, error) {
-	api.UpdateCallPath("/subjects")
+func (api *ArchivesSpaceAPI) CreateSubject(ctx context.Context, subject *Subject) (*ResponseMsg, error) {
 	subject.LockVersion = "0"
-	return api.CreateAPI(api.CallURL.String(), subject)
+	return api.CreateAPI(ctx, api.CallURL("/subjects", nil), subject)
 }
 
 // GetSubject retrieves a subject record from ArchivesSpace
-func (api *ArchivesSpaceAPI) GetSubject(subjectID int) (*Subject, error) {
-	api.UpdateCallPath(fmt.Sprintf("/subjects/%d", subjectID))
-
+func (api *ArchivesSpaceAPI) GetSubject(ctx context.Context, subjectID int) (*Subject, error) {
 	subject := new(Subject)
-	err := api.GetAPI(api.CallURL.String(), subject)
+	err := api.GetAPI(ctx, api.CallURL(fmt.Sprintf("/subjects/%d", subjectID), nil), subject)
+	if err != nil {
+		return nil, fmt.Errorf("GetSubject(%d) %w", subjectID, err)
+	}
 	p := strings.Split(subject.URI, "/")
 	subject.ID, err = strconv.Atoi(p[len(p)-1])
 	if err != nil {
-		return subject, fmt.Errorf("Accession ID parse error %d %s", subject.ID, err)
+		return subject, fmt.Errorf("Accession ID parse error %d %w", subject.ID, err)
 	}
 	return subject, nil
 }
 
 // UpdateSubject updates an existing subject record in ArchivesSpace
-func (api *ArchivesSpaceAPI) UpdateSubject(subject *Subject) (*ResponseMsg, error) {
-	api.UpdateCallPath(subject.URI)
-	return api.UpdateAPI(api.CallURL.String(), subject)
+func (api *ArchivesSpaceAPI) UpdateSubject(ctx context.Context, subject *Subject) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(subject.URI, nil), subject)
 }
 
 // DeleteSubject deletes a subject from ArchivesSpace
-func (api *ArchivesSpaceAPI) DeleteSubject(subject *Subject) (*ResponseMsg, error) {
-	api.UpdateCallPath(subject.URI)
-	return api.DeleteAPI(api.CallURL.String(), subject)
+func (api *ArchivesSpaceAPI) DeleteSubject(ctx context.Context, subject *Subject) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(subject.URI, nil), subject)
 }
 
 // ListSubjects return a list of Subject IDs from ArchivesSpace
-func (api *ArchivesSpaceAPI) ListSubjects() ([]int, error) {
-	api.UpdateCallPath(`/subjects`)
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListSubjects(ctx context.Context) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	return api.ListAPI(api.CallURL.String())
+	return api.ListAPI(ctx, api.CallURL(`/subjects`, q))
 }
 
 // CreateVocabulary creates a new Vocabulary in ArchivesSpace
-func (api *ArchivesSpaceAPI) CreateVocabulary(vocabulary *Vocabulary) (*ResponseMsg, error) {
-	api.UpdateCallPath("/vocabularies")
+func (api *ArchivesSpaceAPI) CreateVocabulary(ctx context.Context, vocabulary *Vocabulary) (*ResponseMsg, error) {
 	vocabulary.LockVersion = "0"
-	return api.CreateAPI(api.CallURL.String(), vocabulary)
+	return api.CreateAPI(ctx, api.CallURL("/vocabularies", nil), vocabulary)
 }
 
 // GetVocabulary retrieves a vocabulary record from ArchivesSpace
-func (api *ArchivesSpaceAPI) GetVocabulary(vocabularyID int) (*Vocabulary, error) {
-	api.UpdateCallPath(fmt.Sprintf("/vocabularies/%d", vocabularyID))
-
+func (api *ArchivesSpaceAPI) GetVocabulary(ctx context.Context, vocabularyID int) (*Vocabulary, error) {
 	vocabulary := new(Vocabulary)
-	err := api.GetAPI(api.CallURL.String(), vocabulary)
+	err := api.GetAPI(ctx, api.CallURL(fmt.Sprintf("/vocabularies/%d", vocabularyID), nil), vocabulary)
+	if err != nil {
+		return nil, fmt.Errorf("GetVocabulary(%d) %w", vocabularyID, err)
+	}
 	p := strings.Split(vocabulary.URI, "/")
 	vocabulary.ID, err = strconv.Atoi(p[len(p)-1])
 	if err != nil {
-		return vocabulary, fmt.Errorf("Accession ID parse error %d %s", vocabulary.ID, err)
+		return vocabulary, fmt.Errorf("Accession ID parse error %d %w", vocabulary.ID, err)
 	}
 	return vocabulary, nil
 }
 
 // UpdateVocabulary updates an existing vocabulary record in ArchivesSpace
-func (api *ArchivesSpaceAPI) UpdateVocabulary(vocabulary *Vocabulary) (*ResponseMsg, error) {
-	api.UpdateCallPath(vocabulary.URI)
-	return api.UpdateAPI(api.CallURL.String(), vocabulary)
+func (api *ArchivesSpaceAPI) UpdateVocabulary(ctx context.Context, vocabulary *Vocabulary) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(vocabulary.URI, nil), vocabulary)
 }
 
 // DeleteVocabulary deletes a vocabulary from ArchivesSpace
-func (api *ArchivesSpaceAPI) DeleteVocabulary(vocabulary *Vocabulary) (*ResponseMsg, error) {
-	api.UpdateCallPath(vocabulary.URI)
-	return api.DeleteAPI(api.CallURL.String(), vocabulary)
+func (api *ArchivesSpaceAPI) DeleteVocabulary(ctx context.Context, vocabulary *Vocabulary) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(vocabulary.URI, nil), vocabulary)
 }
 
 // ListVocabularies return a list of Vocabulary IDs from ArchivesSpace
-func (api *ArchivesSpaceAPI) ListVocabularies() ([]int, error) {
-	api.UpdateCallPath(`/vocabularies`)
-	content, err := api.API("GET", api.CallURL.String(), nil)
+func (api *ArchivesSpaceAPI) ListVocabularies(ctx context.Context) ([]int, error) {
+	content, err := api.API(ctx, "GET", api.CallURL(`/vocabularies`, nil), nil)
 	if err != nil {
-		return nil, fmt.Errorf("ListVocabularies() %s", err)
+		return nil, fmt.Errorf("ListVocabularies() %w", err)
 	}
 	var (
 		ids          []int
@@ -521,13 +511,13 @@ func (api *ArchivesSpaceAPI) ListVocabularies() ([]int, error) {
 	)
 	err = json.Unmarshal([]byte(content), &vocabularies)
 	if err != nil {
-		return nil, fmt.Errorf("ListVocabularies() %s", err)
+		return nil, fmt.Errorf("ListVocabularies() %w", err)
 	}
 	for _, val := range vocabularies {
 		p := strings.Split(val.URI, "/")
 		id, err := strconv.Atoi(p[len(p)-1])
 		if err != nil {
-			return nil, fmt.Errorf("ListVocabularies() %s", err)
+			return nil, fmt.Errorf("ListVocabularies() %w", err)
 		}
 		ids = append(ids, id)
 	}
@@ -535,19 +525,16 @@ func (api *ArchivesSpaceAPI) ListVocabularies() ([]int, error) {
 }
 
 // CreateTerm creates a new Term in ArchivesSpace
-func (api *ArchivesSpaceAPI) CreateTerm(vocabularyID int, term *Term) (*ResponseMsg, error) {
-	api.UpdateCallPath(fmt.Sprintf("/vocabularies/%d/terms", vocabularyID))
+func (api *ArchivesSpaceAPI) CreateTerm(ctx context.Context, vocabularyID int, term *Term) (*ResponseMsg, error) {
 	term.LockVersion = "0"
-	return api.CreateAPI(api.CallURL.String(), term)
+	return api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/vocabularies/%d/terms", vocabularyID), nil), term)
 }
 
 // GetTerm retrieves a term record from ArchivesSpace
-func (api *ArchivesSpaceAPI) GetTerm(vocabularyID, termID int) (*Term, error) {
-	api.UpdateCallPath(fmt.Sprintf("/vocabularies/%d/terms", vocabularyID))
-
-	terms, err := api.ListTerms(vocabularyID)
+func (api *ArchivesSpaceAPI) GetTerm(ctx context.Context, vocabularyID, termID int) (*Term, error) {
+	terms, err := api.ListTerms(ctx, vocabularyID)
 	if err != nil {
-		return nil, fmt.Errorf("GetTerm(%d, %d) %s", vocabularyID, termID, err)
+		return nil, fmt.Errorf("GetTerm(%d, %d) %w", vocabularyID, termID, err)
 	}
 	for _, term := range terms {
 		term.ID = URIToID(term.URI)
@@ -559,32 +546,28 @@ func (api *ArchivesSpaceAPI) GetTerm(vocabularyID, termID int) (*Term, error) {
 }
 
 // UpdateTerm updates an existing term record in ArchivesSpace
-func (api *ArchivesSpaceAPI) UpdateTerm(term *Term) (*ResponseMsg, error) {
-	api.UpdateCallPath(term.URI)
-	return api.UpdateAPI(api.CallURL.String(), term)
+func (api *ArchivesSpaceAPI) UpdateTerm(ctx context.Context, term *Term) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(term.URI, nil), term)
 }
 
 // DeleteTerm deletes a term from ArchivesSpace
-func (api *ArchivesSpaceAPI) DeleteTerm(term *Term) (*ResponseMsg, error) {
-	api.UpdateCallPath(term.URI)
-	return api.DeleteAPI(api.CallURL.String(), term)
+func (api *ArchivesSpaceAPI) DeleteTerm(ctx context.Context, term *Term) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(term.URI, nil), term)
 }
 
 // ListTermIDs return a list of Term IDs from ArchivesSpace
-func (api *ArchivesSpaceAPI) ListTermIDs(vocabularyID int) ([]int, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/vocabularies/%d/terms`, vocabularyID))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListTermIDs(ctx context.Context, vocabularyID int) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	data, err := api.API("GET", api.CallURL.String(), nil)
+	data, err := api.API(ctx, "GET", api.CallURL(fmt.Sprintf(`/vocabularies/%d/terms`, vocabularyID), q), nil)
 	if err != nil {
-		return nil, fmt.Errorf("Can't get Terms for vocabulary %d, %s", vocabularyID, err)
+		return nil, fmt.Errorf("Can't get Terms for vocabulary %d, %w", vocabularyID, err)
 	}
 	// Now Unpack list of terms into a []Term
 	var terms []*Term
 	err = json.Unmarshal(data, &terms)
 	if err != nil {
-		return nil, fmt.Errorf("Can't decode terms for vocabularly %d, %s", vocabularyID, err)
+		return nil, fmt.Errorf("Can't decode terms for vocabularly %d, %w", vocabularyID, err)
 	}
 	var ids []int
 	for _, term := range terms {
@@ -599,19 +582,17 @@ func (api *ArchivesSpaceAPI) ListTermIDs(vocabularyID int) ([]int, error) {
 }
 
 // ListTerms return a list of Term IDs from ArchivesSpace
-func (api *ArchivesSpaceAPI) ListTerms(vocabularyID int) ([]*Term, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/vocabularies/%d/terms`, vocabularyID))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListTerms(ctx context.Context, vocabularyID int) ([]*Term, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	data, err := api.API("GET", api.CallURL.String(), nil)
+	data, err := api.API(ctx, "GET", api.CallURL(fmt.Sprintf(`/vocabularies/%d/terms`, vocabularyID), q), nil)
 	if err != nil {
-		return nil, fmt.Errorf("Can't get Terms for vocabulary %d, %s", vocabularyID, err)
+		return nil, fmt.Errorf("Can't get Terms for vocabulary %d, %w", vocabularyID, err)
 	}
 	// Now Unpack list of terms into a []Term
 	var terms []*Term
 	if err := json.Unmarshal(data, &terms); err != nil {
-		return nil, fmt.Errorf("Can't decode terms for vocabularly %d, %s", vocabularyID, err)
+		return nil, fmt.Errorf("Can't decode terms for vocabularly %d, %w", vocabularyID, err)
 	}
 	for _, term := range terms {
 		//FIXME: Get the Term id and set terms[i].ID to that value.
@@ -622,153 +603,238 @@ func (api *ArchivesSpaceAPI) ListTerms(vocabularyID int) ([]*Term, error) {
 }
 
 // CreateLocation creates a new Location in ArchivesSpace
-func (api *ArchivesSpaceAPI) CreateLocation(location *Location) (*ResponseMsg, error) {
-	api.UpdateCallPath(fmt.Sprintf("/locations"))
+func (api *ArchivesSpaceAPI) CreateLocation(ctx context.Context, location *Location) (*ResponseMsg, error) {
 	location.LockVersion = "0"
-	return api.CreateAPI(api.CallURL.String(), location)
+	return api.CreateAPI(ctx, api.CallURL("/locations", nil), location)
 }
 
 // GetLocation retrieves a location record from ArchivesSpace
-func (api *ArchivesSpaceAPI) GetLocation(ID int) (*Location, error) {
-	api.UpdateCallPath(fmt.Sprintf("/locations/%d", ID))
-
+func (api *ArchivesSpaceAPI) GetLocation(ctx context.Context, ID int) (*Location, error) {
 	location := new(Location)
-	err := api.GetAPI(api.CallURL.String(), location)
+	err := api.GetAPI(ctx, api.CallURL(fmt.Sprintf("/locations/%d", ID), nil), location)
 	if err != nil {
-		return nil, fmt.Errorf("GetLocation(%d) %s", ID, err)
+		return nil, fmt.Errorf("GetLocation(%d) %w", ID, err)
 	}
 	p := strings.Split(location.URI, "/")
 	location.ID, err = strconv.Atoi(p[len(p)-1])
 	if err != nil {
-		return location, fmt.Errorf("Accession ID parse error %d %s", location.ID, err)
+		return location, fmt.Errorf("Accession ID parse error %d %w", location.ID, err)
 	}
 	return location, nil
 }
 
 // UpdateLocation updates an existing location record in ArchivesSpace
-func (api *ArchivesSpaceAPI) UpdateLocation(location *Location) (*ResponseMsg, error) {
-	api.UpdateCallPath(location.URI)
-	return api.UpdateAPI(api.CallURL.String(), location)
+func (api *ArchivesSpaceAPI) UpdateLocation(ctx context.Context, location *Location) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(location.URI, nil), location)
 }
 
 // DeleteLocation deletes a location from ArchivesSpace
-func (api *ArchivesSpaceAPI) DeleteLocation(location *Location) (*ResponseMsg, error) {
-	api.UpdateCallPath(location.URI)
-	return api.DeleteAPI(api.CallURL.String(), location)
+func (api *ArchivesSpaceAPI) DeleteLocation(ctx context.Context, location *Location) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(location.URI, nil), location)
 }
 
 // ListLocations return a list of Location IDs from ArchivesSpace
-func (api *ArchivesSpaceAPI) ListLocations() ([]int, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/locations`))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListLocations(ctx context.Context) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	return api.ListAPI(api.CallURL.String())
+	return api.ListAPI(ctx, api.CallURL(`/locations`, q))
 }
 
 // CreateDigitalObject - return a new digital object
-func (api *ArchivesSpaceAPI) CreateDigitalObject(repoID int, obj *DigitalObject) (*ResponseMsg, error) {
+func (api *ArchivesSpaceAPI) CreateDigitalObject(ctx context.Context, repoID int, obj *DigitalObject) (*ResponseMsg, error) {
 	// NOTE: attempt extract accession ID for the edge of importing a digital object as opposed to a clean create
 	uriPrefix := fmt.Sprintf("/repositories/%d/digital_objects", repoID)
 	obj.JSONModelType = "digital_object"
 	obj.LockVersion = "0"
-	api.UpdateCallPath(uriPrefix)
 	// We need to create the object
-	responseMsg, responseErr := api.CreateAPI(api.CallURL.String(), obj)
-	if responseErr != nil || responseMsg.Status != "created" {
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(uriPrefix, nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
 		return responseMsg, responseErr
 	}
 	// NOTE: In the case we're importing a digital_object from another ArchivesSpace instance.
 	// We need to correct the URI assignment, lock version info and attach to the accession of necessary
 	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
 	obj.LockVersion = responseMsg.LockVersion
 	return responseMsg, responseErr
 }
 
 // GetDigitalObject - return a given digital object
-func (api *ArchivesSpaceAPI) GetDigitalObject(repoID, objID int) (*DigitalObject, error) {
-	api.UpdateCallPath(fmt.Sprintf("/repositories/%d/digital_objects/%d", repoID, objID))
-
+func (api *ArchivesSpaceAPI) GetDigitalObject(ctx context.Context, repoID, objID int) (*DigitalObject, error) {
 	obj := new(DigitalObject)
-	err := api.GetAPI(api.CallURL.String(), obj)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/digital_objects/%d", repoID, objID), nil)
+	err := api.GetAPI(ctx, u, obj)
 	if err != nil {
-		return nil, fmt.Errorf("GetDigitalObject() %s, error, %s", api.CallURL.String(), err)
+		return nil, fmt.Errorf("GetDigitalObject() %s, error, %w", u, err)
 	}
 	obj.ID = URIToID(obj.URI)
 	return obj, nil
 }
 
 // UpdateDigitalObject - returns an updated digital
-func (api *ArchivesSpaceAPI) UpdateDigitalObject(obj *DigitalObject) (*ResponseMsg, error) {
-	api.UpdateCallPath(obj.URI)
-	return api.UpdateAPI(api.CallURL.String(), obj)
+func (api *ArchivesSpaceAPI) UpdateDigitalObject(ctx context.Context, obj *DigitalObject) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
 }
 
 // DeleteDigitalObject - return the results of deleting a digital object
-func (api *ArchivesSpaceAPI) DeleteDigitalObject(obj *DigitalObject) (*ResponseMsg, error) {
-	api.UpdateCallPath(obj.URI)
+func (api *ArchivesSpaceAPI) DeleteDigitalObject(ctx context.Context, obj *DigitalObject) (*ResponseMsg, error) {
 	//FIXME: If we're Updating we may need to unlink existing accessions
-	return api.DeleteAPI(api.CallURL.String(), obj)
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
 }
 
 // ListDigitalObjects - return a list of digital object ids
-func (api *ArchivesSpaceAPI) ListDigitalObjects(repoID int) ([]int, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/repositories/%d/digital_objects`, repoID))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListDigitalObjects(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/digital_objects`, repoID), q))
+}
+
+// CreateDigitalObjectComponent - return a new digital object component, the component
+// must have a digital_object ref and may have a parent component ref
+func (api *ArchivesSpaceAPI) CreateDigitalObjectComponent(ctx context.Context, repoID int, obj *DigitalObjectComponent) (*ResponseMsg, error) {
+	obj.JSONModelType = "digital_object_component"
+	obj.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/digital_object_components", repoID), nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
+	obj.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetDigitalObjectComponent - return a given digital object component
+func (api *ArchivesSpaceAPI) GetDigitalObjectComponent(ctx context.Context, repoID, objID int) (*DigitalObjectComponent, error) {
+	obj := new(DigitalObjectComponent)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/digital_object_components/%d", repoID, objID), nil)
+	err := api.GetAPI(ctx, u, obj)
+	if err != nil {
+		return nil, fmt.Errorf("GetDigitalObjectComponent() %s, error, %w", u, err)
+	}
+	obj.ID = URIToID(obj.URI)
+	return obj, nil
+}
+
+// UpdateDigitalObjectComponent - returns an updated digital object component
+func (api *ArchivesSpaceAPI) UpdateDigitalObjectComponent(ctx context.Context, obj *DigitalObjectComponent) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// DeleteDigitalObjectComponent - return the results of deleting a digital object component
+func (api *ArchivesSpaceAPI) DeleteDigitalObjectComponent(ctx context.Context, obj *DigitalObjectComponent) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// ListDigitalObjectComponents - return a list of digital object component ids
+func (api *ArchivesSpaceAPI) ListDigitalObjectComponents(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	return api.ListAPI(api.CallURL.String())
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/digital_object_components`, repoID), q))
 }
 
 // CreateResource - return a new resource
-func (api *ArchivesSpaceAPI) CreateResource(repoID int, obj *Resource) (*ResponseMsg, error) {
-	// NOTE: attempt extract accession ID for the edge of importing a digital object as opposed to a clean create
-	uriPrefix := fmt.Sprintf("/repositories/%d/digital_objects", repoID)
-	obj.JSONModelType = "digital_object"
+func (api *ArchivesSpaceAPI) CreateResource(ctx context.Context, repoID int, obj *Resource) (*ResponseMsg, error) {
+	uriPrefix := fmt.Sprintf("/repositories/%d/resources", repoID)
+	obj.JSONModelType = "resource"
 	obj.LockVersion = "0"
-	api.UpdateCallPath(uriPrefix)
 	// We need to create the object
-	responseMsg, responseErr := api.CreateAPI(api.CallURL.String(), obj)
-	if responseErr != nil || responseMsg.Status != "created" {
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(uriPrefix, nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
 		return responseMsg, responseErr
 	}
-	// NOTE: In the case we're importing a digital_object from another ArchivesSpace instance.
-	// We need to correct the URI assignment, lock version info and attach to the accession of necessary
+	// NOTE: In the case we're importing a resource from another ArchivesSpace instance.
+	// We need to correct the URI assignment and lock version info
 	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
 	obj.LockVersion = responseMsg.LockVersion
 	return responseMsg, responseErr
 }
 
 // GetResource - return a given resource
-func (api *ArchivesSpaceAPI) GetResource(repoID, objID int) (*Resource, error) {
-	api.UpdateCallPath(fmt.Sprintf("/repositories/%d/resources/%d", repoID, objID))
-
+func (api *ArchivesSpaceAPI) GetResource(ctx context.Context, repoID, objID int) (*Resource, error) {
 	obj := new(Resource)
-	err := api.GetAPI(api.CallURL.String(), obj)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/resources/%d", repoID, objID), nil)
+	err := api.GetAPI(ctx, u, obj)
 	if err != nil {
-		return nil, fmt.Errorf("GetResource() %s, error, %s", api.CallURL.String(), err)
+		return nil, fmt.Errorf("GetResource() %s, error, %w", u, err)
 	}
-	//obj.ID = URIToID(obj.URI)
+	obj.ID = URIToID(obj.URI)
 	return obj, nil
 }
 
 // UpdateResource - returns an updated resource
-func (api *ArchivesSpaceAPI) UpdateResource(obj *Resource) (*ResponseMsg, error) {
-	api.UpdateCallPath(obj.URI)
-	return api.UpdateAPI(api.CallURL.String(), obj)
+func (api *ArchivesSpaceAPI) UpdateResource(ctx context.Context, obj *Resource) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
 }
 
 // DeleteResource - return the results of deleting a resource
-func (api *ArchivesSpaceAPI) DeleteResource(obj *Resource) (*ResponseMsg, error) {
-	api.UpdateCallPath(obj.URI)
-	return api.DeleteAPI(api.CallURL.String(), obj)
+func (api *ArchivesSpaceAPI) DeleteResource(ctx context.Context, obj *Resource) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
 }
 
 // ListResources - return a list of resource ids
-func (api *ArchivesSpaceAPI) ListResources(repoID int) ([]int, error) {
-	api.UpdateCallPath(fmt.Sprintf(`/repositories/%d/resources`, repoID))
-	q := api.CallURL.Query()
+func (api *ArchivesSpaceAPI) ListResources(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
 	q.Set("all_ids", "true")
-	api.CallURL.RawQuery = q.Encode()
-	return api.ListAPI(api.CallURL.String())
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/resources`, repoID), q))
+}
+
+// CreateArchivalObject - return a new archival object, obj.Resource
+// (e.g. {"ref": "/repositories/2/resources/1"}) should reference the
+// resource and obj.Parent the parent archival object if there is one
+func (api *ArchivesSpaceAPI) CreateArchivalObject(ctx context.Context, repoID int, obj *ArchivalObject) (*ResponseMsg, error) {
+	uriPrefix := fmt.Sprintf("/repositories/%d/archival_objects", repoID)
+	obj.JSONModelType = "archival_object"
+	obj.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(uriPrefix, nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
+	obj.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetArchivalObject - return a given archival object
+func (api *ArchivesSpaceAPI) GetArchivalObject(ctx context.Context, repoID, objID int) (*ArchivalObject, error) {
+	obj := new(ArchivalObject)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/archival_objects/%d", repoID, objID), nil)
+	err := api.GetAPI(ctx, u, obj)
+	if err != nil {
+		return nil, fmt.Errorf("GetArchivalObject() %s, error, %w", u, err)
+	}
+	obj.ID = URIToID(obj.URI)
+	return obj, nil
+}
+
+// UpdateArchivalObject - returns an updated archival object
+func (api *ArchivesSpaceAPI) UpdateArchivalObject(ctx context.Context, obj *ArchivalObject) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// DeleteArchivalObject - return the results of deleting an archival object
+func (api *ArchivesSpaceAPI) DeleteArchivalObject(ctx context.Context, obj *ArchivalObject) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// ListArchivalObjects - return a list of archival object ids
+func (api *ArchivesSpaceAPI) ListArchivalObjects(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/archival_objects`, repoID), q))
+}
+
+// ListArchivalObjectChildren - return the immediate children of an archival object
+func (api *ArchivesSpaceAPI) ListArchivalObjectChildren(ctx context.Context, repoID, objID int) ([]*ArchivalObject, error) {
+	var objs []*ArchivalObject
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/archival_objects/%d/children", repoID, objID), nil)
+	if err := api.GetAPI(ctx, u, &objs); err != nil {
+		return nil, fmt.Errorf("ListArchivalObjectChildren() %s, error, %w", u, err)
+	}
+	for _, obj := range objs {
+		obj.ID = URIToID(obj.URI)
+	}
+	return objs, nil
 }
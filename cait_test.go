@@ -1,4 +1,3 @@
-//
 // Package cait is a collection of structures and functions
 // for interacting with ArchivesSpace's REST API
 //
@@ -16,15 +15,23 @@
 // 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
 //
 // THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
-//
 package cait
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -88,6 +95,7 @@ func TestArchiveSpaceAPI(t *testing.T) {
 		t.SkipNow()
 	}
 
+	ctx := context.Background()
 	cait := New(caitURL, caitUsername, caitPassword, collectionName)
 	if cait.BaseURL == nil {
 		t.Errorf("%s\t%s", cait.BaseURL.String(), caitURL)
@@ -99,7 +107,7 @@ func TestArchiveSpaceAPI(t *testing.T) {
 	if cait.IsAuth() == true {
 		t.Error("cait.IsAuth() returning true before authentication")
 	}
-	err := cait.Login()
+	err := cait.Login(ctx)
 	if err != nil {
 		t.Errorf("%s\t%s", err, cait.BaseURL.String())
 		t.FailNow()
@@ -108,7 +116,7 @@ func TestArchiveSpaceAPI(t *testing.T) {
 		t.Error("cait.IsAuth() return false after authentication")
 	}
 
-	err = cait.Logout()
+	err = cait.Logout(ctx)
 	if err != nil {
 		t.Errorf("Logout() %s", err)
 	}
@@ -122,11 +130,12 @@ func TestRepository(t *testing.T) {
 		t.Skip()
 	}
 
+	ctx := context.Background()
 	cait := New(caitURL, caitUsername, caitPassword, collectionName)
 	tm := time.Now()
 	repoCode := fmt.Sprintf("%d", tm.Unix())
 
-	err := cait.Login()
+	err := cait.Login(ctx)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -134,7 +143,7 @@ func TestRepository(t *testing.T) {
 	repo1 := new(Repository)
 	repo1.RepoCode = repoCode
 	repo1.Name = "This is a test generated from go_test"
-	response, err := cait.CreateRepository(repo1)
+	response, err := cait.CreateRepository(ctx, repo1)
 	if err != nil {
 		t.Errorf("Error from CreateRepository() %s", err)
 	}
@@ -146,7 +155,7 @@ func TestRepository(t *testing.T) {
 	}
 	repo1.ID = response.ID
 
-	repo2, err := cait.GetRepository(repo1.ID)
+	repo2, err := cait.GetRepository(ctx, repo1.ID)
 	if err != nil {
 		t.Errorf("GetRepository() error: %s", err)
 	}
@@ -163,7 +172,7 @@ func TestRepository(t *testing.T) {
 	repo2.Name = fmt.Sprintf("Modified Name: %s", repo2.Name)
 	repo2.URL = `http://www.archive.example.edu`
 	repo2.ImageURL = `http://www.archive.example.edu/logo.svg`
-	response, err = cait.UpdateRepository(repo2)
+	response, err = cait.UpdateRepository(ctx, repo2)
 	if err != nil {
 		t.Errorf("UpdateRepository failed for %v: %s", repo2, err)
 	}
@@ -171,7 +180,7 @@ func TestRepository(t *testing.T) {
 		t.Errorf("UpdateRepository() should return a response.Status of Updated %s", response)
 	}
 	isOK := true
-	repo1, err = cait.GetRepository(repo2.ID)
+	repo1, err = cait.GetRepository(ctx, repo2.ID)
 	if err != nil {
 		t.Errorf("GetRepository() %d after update failed %s", repo2.ID, err)
 		isOK = false
@@ -193,20 +202,20 @@ func TestRepository(t *testing.T) {
 		t.FailNow()
 	}
 
-	repos, err := cait.ListRepositories()
+	repos, err := cait.ListRepositories(ctx)
 	if err != nil {
 		t.Errorf("ListRepostiories failed for %v : %s", cait, err)
 	} else if len(repos) == 0 {
 		t.Errorf("Expected one or more in repository list: %v", repos)
 	}
 
-	_, err = cait.DeleteRepository(repo2)
+	_, err = cait.DeleteRepository(ctx, repo2)
 	if err != nil {
 		t.Errorf("DeleteRepository failed for %v: %s", repo2, err)
 		t.FailNow()
 	}
 
-	_, err = cait.GetRepository(repo1.ID)
+	_, err = cait.GetRepository(ctx, repo1.ID)
 	if err == nil {
 		t.Errorf("GetRepository() should return an error after a deleting repo id %d: %s", repo1.ID, err)
 		t.FailNow()
@@ -221,8 +230,9 @@ func TestAgent(t *testing.T) {
 		t.Skip()
 	}
 
+	ctx := context.Background()
 	cait := New(caitURL, caitUsername, caitPassword, collectionName)
-	err := cait.Login()
+	err := cait.Login(ctx)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -230,11 +240,11 @@ func TestAgent(t *testing.T) {
 
 	// Test the listing of agents by Type or individually by type/id
 	for _, aType := range []string{"people", "families", "corporate_entities", "software"} {
-		if agentIDs, err := cait.ListAgents(aType); err != nil {
+		if agentIDs, err := cait.ListAgents(ctx, aType); err != nil {
 			t.Errorf(`ListAgents("%s") error: %s`, aType, err)
 		} else if len(agentIDs) > 0 {
 			for _, id := range agentIDs {
-				if agentInfo, err := cait.GetAgent(aType, id); err != nil {
+				if agentInfo, err := cait.GetAgent(ctx, aType, id); err != nil {
 					t.Errorf(`GetAgent("%s", %d) error: %s`, aType, id, err)
 				} else {
 					if agentInfo.ID != id {
@@ -260,7 +270,7 @@ func TestAgent(t *testing.T) {
 	agent0.Names = append(agent0.Names, name0)
 
 	aType := "people"
-	response, err := cait.CreateAgent(aType, agent0)
+	response, err := cait.CreateAgent(ctx, aType, agent0)
 	if err != nil {
 		t.Errorf(`CreateAgent("%s", %s) error: %s`, aType, agent0, err)
 		t.FailNow()
@@ -271,7 +281,7 @@ func TestAgent(t *testing.T) {
 	}
 	agent0.ID = response.ID
 
-	agent1, err := cait.GetAgent(aType, agent0.ID)
+	agent1, err := cait.GetAgent(ctx, aType, agent0.ID)
 	if err != nil {
 		t.Errorf(`GetAgent(%d) failed %s`, agent0.ID, err)
 		t.FailNow()
@@ -282,7 +292,7 @@ func TestAgent(t *testing.T) {
 		t.FailNow()
 	}
 	agent1.Names[0].NameOrder = "inverted"
-	response, err = cait.UpdateAgent(agent1)
+	response, err = cait.UpdateAgent(ctx, agent1)
 	if err != nil {
 		t.Errorf(`UpdateAgent(%s), error: %s`, agent1, err)
 		t.FailNow()
@@ -291,12 +301,12 @@ func TestAgent(t *testing.T) {
 		t.Errorf(`UpdateAgent(%s), status error: %s`, agent1, response)
 		t.FailNow()
 	}
-	agent2, _ := cait.GetAgent(aType, agent1.ID)
+	agent2, _ := cait.GetAgent(ctx, aType, agent1.ID)
 	if strings.Compare(agent2.Names[0].NameOrder, "inverted") != 0 {
 		t.Errorf("UpdateAgent(%s), error: Failed to update Names[0].NameOrder [%s] != [%s]", agent1, agent1.Names[0].NameOrder, agent2.Names[0].NameOrder)
 		t.FailNow()
 	}
-	response, err = cait.DeleteAgent(agent2)
+	response, err = cait.DeleteAgent(ctx, agent2)
 	if err != nil {
 		t.Errorf("DeleteAgent(%s), error: %s", agent2, err)
 		t.FailNow()
@@ -327,8 +337,9 @@ func TestAccession(t *testing.T) {
 		t.Skip()
 	}
 
+	ctx := context.Background()
 	cait := New(caitURL, caitUsername, caitPassword, collectionName)
-	err := cait.Login()
+	err := cait.Login(ctx)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -339,8 +350,8 @@ func TestAccession(t *testing.T) {
 	repo := new(Repository)
 	repo.RepoCode = repoCode
 	repo.Name = fmt.Sprintf("This is a test data generated from go_test for working with Accession data in test Repository %s", repoCode)
-	response, err := cait.CreateRepository(repo)
-	defer cait.DeleteRepository(repo)
+	response, err := cait.CreateRepository(ctx, repo)
+	defer cait.DeleteRepository(ctx, repo)
 	if err != nil {
 		t.Errorf("Error from CreateRepository() %s", err)
 	}
@@ -348,7 +359,7 @@ func TestAccession(t *testing.T) {
 		t.Errorf("Erro from CreateRepository() %s", response)
 	}
 	repo.ID = response.ID
-	repo, err = cait.GetRepository(repo.ID)
+	repo, err = cait.GetRepository(ctx, repo.ID)
 	if repo == nil {
 		t.Errorf("Repository should not be nil")
 	}
@@ -358,7 +369,7 @@ func TestAccession(t *testing.T) {
 	}
 
 	// Test the listing of accessions
-	accessionIDs, err := cait.ListAccessions(repo.ID)
+	accessionIDs, err := cait.ListAccessions(ctx, repo.ID)
 	if err != nil {
 		t.Errorf(`ListAccessions() error: %s`, err)
 		t.FailNow()
@@ -374,7 +385,7 @@ func TestAccession(t *testing.T) {
 		accession1.ID0 = fmt.Sprintf("%04d", tm.Year())
 		accession1.ID1 = fmt.Sprintf("%04d", i)
 		accession1.AccessionDate = fmt.Sprintf("%d-%02d-%02d", tm.Year(), tm.Month(), tm.Day())
-		response, err = cait.CreateAccession(repo.ID, accession1)
+		response, err = cait.CreateAccession(ctx, repo.ID, accession1)
 		if err != nil {
 			t.Errorf("Can't create accession %v, %s", accession1, err)
 			t.FailNow()
@@ -385,7 +396,7 @@ func TestAccession(t *testing.T) {
 		}
 		accession1.ID = response.ID
 		accession1.URI = response.URI
-		accession2, err := cait.GetAccession(repo.ID, accession1.ID)
+		accession2, err := cait.GetAccession(ctx, repo.ID, accession1.ID)
 		if err != nil {
 			t.Errorf("GetAccession(%d, %d) error %s", repo.ID, accession1.ID, err)
 		}
@@ -400,7 +411,7 @@ func TestAccession(t *testing.T) {
 		}
 	}
 
-	accessionIDs, err = cait.ListAccessions(repo.ID)
+	accessionIDs, err = cait.ListAccessions(ctx, repo.ID)
 	if err != nil {
 		t.Errorf(`ListAccessions() error: %s`, err)
 		t.FailNow()
@@ -411,7 +422,7 @@ func TestAccession(t *testing.T) {
 	}
 
 	for _, id := range accessionIDs {
-		accessionInfo, err := cait.GetAccession(repo.ID, id)
+		accessionInfo, err := cait.GetAccession(ctx, repo.ID, id)
 		if err != nil {
 			t.Errorf(`GetAccession(%d) error: %s`, id, err)
 		}
@@ -435,8 +446,9 @@ func TestVocabularies(t *testing.T) {
 		t.Skip()
 	}
 
+	ctx := context.Background()
 	cait := New(caitURL, caitUsername, caitPassword, collectionName)
-	err := cait.Login()
+	err := cait.Login(ctx)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -447,7 +459,7 @@ func TestVocabularies(t *testing.T) {
 	voc.Name = fmt.Sprintf("test from Go %d", now.Unix())
 	voc.RefID = fmt.Sprintf("testFromGo%d", now.Unix())
 
-	response, err := cait.CreateVocabulary(voc)
+	response, err := cait.CreateVocabulary(ctx, voc)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
@@ -466,6 +478,2214 @@ func TestVocabularies(t *testing.T) {
 	}
 }
 
+func TestConcurrentCalls(t *testing.T) {
+	// A single ArchivesSpaceAPI should be usable from several go routines
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-ArchivesSpace-Session") != "test-token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"uri":%q,"jsonmodel_type":"accession","lock_version":0}`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.AuthToken = "test-token"
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			accession, err := api.GetAccession(ctx, 2, id)
+			if err != nil {
+				t.Errorf("GetAccession(ctx, 2, %d) %s", id, err)
+				return
+			}
+			if accession.ID != id {
+				t.Errorf("expected accession %d, got %d (%s)", id, accession.ID, accession.URI)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// A cancelled context should stop the request
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := api.GetAccession(cctx, 2, 1); err == nil {
+		t.Errorf("expected an error for a cancelled context")
+	}
+}
+
+func TestRetryAndRelogin(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		failures = 2
+		logins   = 0
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/users/tester/login" {
+			logins++
+			fmt.Fprintf(w, `{"session":"token-%d"}`, logins)
+			return
+		}
+		if r.Header.Get("X-ArchivesSpace-Session") == "expired" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprintf(w, `{"code":"SESSION_GONE","error":"No session found for expired"}`)
+			return
+		}
+		if failures > 0 {
+			failures--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"uri":%q,"lock_version":0}`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "tester", "secret", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Username = "tester"
+	api.AuthToken = "good"
+	api.Retry = &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Relogin: true}
+	ctx := context.Background()
+
+	if _, err := api.GetSubject(ctx, 1); err != nil {
+		t.Errorf("GetSubject(ctx, 1) should succeed after retries, %s", err)
+	}
+	if retries, relogins := api.RetryStats(); retries != 2 || relogins != 0 {
+		t.Errorf("expected 2 retries and 0 re-logins, got %d, %d", retries, relogins)
+	}
+
+	api.AuthToken = "expired"
+	if _, err := api.GetSubject(ctx, 2); err != nil {
+		t.Errorf("GetSubject(ctx, 2) should succeed after re-login, %s", err)
+	}
+	if _, relogins := api.RetryStats(); relogins != 1 {
+		t.Errorf("expected 1 re-login, got %d", relogins)
+	}
+	if api.AuthToken != "token-1" {
+		t.Errorf("expected new session token, got %q", api.AuthToken)
+	}
+
+	// Without a retry policy the first failure is returned
+	mu.Lock()
+	failures = 1
+	mu.Unlock()
+	api.Retry = nil
+	if _, err := api.GetSubject(ctx, 3); err == nil {
+		t.Errorf("GetSubject(ctx, 3) should fail without a retry policy")
+	}
+
+	// Retry-After is capped by MaxDelay
+	policy := &RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	for _, retryAfter := range []string{"3600", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)} {
+		header := http.Header{"Retry-After": []string{retryAfter}}
+		if d := policy.backoff(0, header); d != policy.MaxDelay {
+			t.Errorf("expected Retry-After %q to be capped at %s, got %s", retryAfter, policy.MaxDelay, d)
+		}
+	}
+	if d := policy.backoff(0, http.Header{"Retry-After": []string{"0"}}); d != 0 {
+		t.Errorf("expected Retry-After 0 to be honored, got %s", d)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/accessions"):
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":{"id_0":["Property is required but was missing"],"accession_date":["Property is required but was missing"]}}`)
+		case r.Method == "POST":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, `{"error":"The record you tried to update has been modified since you fetched it."}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"Accession not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+
+	var apiErr *APIError
+	_, err := api.CreateAccession(ctx, 2, new(Accession))
+	if errors.As(err, &apiErr) == false {
+		t.Fatalf("CreateAccession() expected an *APIError, got %T %s", err, err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Method != "POST" {
+		t.Errorf("unexpected status or method %s", apiErr)
+	}
+	if len(apiErr.Validation["id_0"]) != 1 || len(apiErr.Validation["accession_date"]) != 1 {
+		t.Errorf("expected validation errors for id_0 and accession_date, %s", apiErr)
+	}
+
+	accession := &Accession{URI: "/repositories/2/accessions/1"}
+	_, err = api.UpdateAccession(ctx, accession)
+	if errors.As(err, &apiErr) == false || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("UpdateAccession() expected a 409 *APIError, got %s", err)
+	} else if apiErr.Message == "" {
+		t.Errorf("expected a message for the conflict, %s", apiErr)
+	}
+
+	_, err = api.GetAccession(ctx, 2, 1)
+	if errors.As(err, &apiErr) == false || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetAccession() expected a 404 *APIError, got %s", err)
+	} else if strings.HasSuffix(apiErr.URL, "/repositories/2/accessions/1") == false {
+		t.Errorf("unexpected URL in error %s", apiErr.URL)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	total := 5
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if ids, ok := q["id_set[]"]; ok == true {
+			out := []string{}
+			for _, id := range ids {
+				out = append(out, fmt.Sprintf(`{"uri":"/repositories/2/accessions/%s","title":"Accession %s"}`, id, id))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(out, ","))
+			return
+		}
+		page, pageSize := 0, 0
+		fmt.Sscanf(q.Get("page"), "%d", &page)
+		fmt.Sscanf(q.Get("page_size"), "%d", &pageSize)
+		if page < 1 || pageSize < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":"page and page_size are required"}`)
+			return
+		}
+		lastPage := (total + pageSize - 1) / pageSize
+		out := []string{}
+		for id := (page-1)*pageSize + 1; id <= total && id <= page*pageSize; id++ {
+			out = append(out, fmt.Sprintf(`{"uri":"/repositories/2/accessions/%d","title":"Accession %d"}`, id, id))
+		}
+		fmt.Fprintf(w, `{"first_page":1,"last_page":%d,"this_page":%d,"total":%d,"results":[%s]}`, lastPage, page, total, strings.Join(out, ","))
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	accessions, info, err := api.AccessionList(2).Page(ctx, 3, 2)
+	if err != nil {
+		t.Fatalf("AccessionList().Page() %s", err)
+	}
+	if len(accessions) != 1 || info.ThisPage != 3 || info.LastPage != 3 || info.Total != total {
+		t.Errorf("unexpected page %d records, %+v", len(accessions), info)
+	}
+	if len(accessions) > 0 && accessions[0].ID != 5 {
+		t.Errorf("expected accession ID 5, got %d", accessions[0].ID)
+	}
+
+	seen := []int{}
+	err = api.AccessionList(2).Each(ctx, 2, func(accession *Accession) error {
+		seen = append(seen, accession.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("AccessionList().Each() %s", err)
+	}
+	if len(seen) != total || seen[0] != 1 || seen[total-1] != total {
+		t.Errorf("expected accessions 1 to %d, got %v", total, seen)
+	}
+
+	stop := errors.New("stop")
+	cnt := 0
+	err = api.AccessionList(2).Each(ctx, 2, func(accession *Accession) error {
+		cnt++
+		if cnt == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || cnt != 3 {
+		t.Errorf("expected iteration to stop after 3 records, %d, %s", cnt, err)
+	}
+
+	ids := make([]int, MaxIDSetSize+10)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	accessions, err = api.AccessionList(2).Get(ctx, ids)
+	if err != nil {
+		t.Fatalf("AccessionList().Get() %s", err)
+	}
+	if len(accessions) != len(ids) || accessions[len(ids)-1].ID != len(ids) {
+		t.Errorf("expected %d accessions, got %d", len(ids), len(accessions))
+	}
+
+	streamed := []int{}
+	records, errc := api.AccessionList(2).Stream(ctx, 2)
+	for accession := range records {
+		streamed = append(streamed, accession.ID)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("AccessionList().Stream() %s", err)
+	}
+	if fmt.Sprintf("%v", streamed) != fmt.Sprintf("%v", seen) {
+		t.Errorf("expected streamed accessions %v, got %v", seen, streamed)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	records, errc = api.AccessionList(2).Stream(cctx, 2)
+	<-records
+	cancel()
+	for range records {
+	}
+	if err := <-errc; errors.Is(err, context.Canceled) == false {
+		t.Errorf("expected the stream to stop with context.Canceled, got %v", err)
+	}
+}
+
+func TestResourceTree(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tree/root"):
+			fmt.Fprintf(w, `{"uri":"/repositories/2/resources/1","title":"Papers","jsonmodel_type":"resource","child_count":3,"waypoints":2,"waypoint_size":2,
+"precomputed_waypoints":{"":{"0":[
+{"uri":"/repositories/2/archival_objects/10","title":"Series 1","level":"series","position":0,"child_count":1,"waypoints":1,"waypoint_size":2},
+{"uri":"/repositories/2/archival_objects/11","title":"Series 2","level":"series","position":1,"child_count":0,"waypoints":0,"waypoint_size":2}]}}}`)
+		case strings.HasSuffix(r.URL.Path, "/tree/waypoint"):
+			q := r.URL.Query()
+			switch {
+			case q.Get("parent_node") == "" && q.Get("offset") == "1":
+				fmt.Fprintf(w, `[{"uri":"/repositories/2/archival_objects/12","title":"Series 3","level":"series","position":2,"child_count":0,"waypoints":0,"waypoint_size":2}]`)
+			case q.Get("parent_node") == "/repositories/2/archival_objects/10" && q.Get("offset") == "0":
+				fmt.Fprintf(w, `[{"uri":"/repositories/2/archival_objects/20","title":"Folder 1","level":"file","position":0,"parent_id":10,"child_count":0,"waypoints":0,"waypoint_size":2}]`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error":"waypoint not found"}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	titles := []string{}
+	err := api.WalkResourceTree(ctx, 2, 1, func(node *TreeNode, depth int) error {
+		titles = append(titles, fmt.Sprintf("%d %s", depth, node.Title))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkResourceTree() %s", err)
+	}
+	expected := "0 Papers, 1 Series 1, 2 Folder 1, 1 Series 2, 1 Series 3"
+	if strings.Join(titles, ", ") != expected {
+		t.Errorf("expected %q, got %q", expected, strings.Join(titles, ", "))
+	}
+
+	tree, err := api.GetResourceTree(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("GetResourceTree() %s", err)
+	}
+	if len(tree.Children) != 3 || len(tree.Children[0].Children) != 1 {
+		t.Fatalf("unexpected tree shape %+v", tree)
+	}
+	if folder := tree.Children[0].Children[0]; folder.ID != 20 || folder.Level != "file" {
+		t.Errorf("unexpected folder node %+v", folder)
+	}
+	cnt := 0
+	tree.Walk(func(node *ResourceTree, depth int) error {
+		cnt++
+		return nil
+	})
+	if cnt != 5 {
+		t.Errorf("expected to walk 5 nodes, walked %d", cnt)
+	}
+}
+
+func TestResolveInstances(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/repositories/2/top_containers/5":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/top_containers/5","indicator":"12","type":"box","barcode":"3900012",
+"container_profile":{"ref":"/container_profiles/1"},
+"container_locations":[{"status":"previous","ref":"/locations/8"},{"status":"current","start_date":"2017-06-01","ref":"/locations/9"}],
+"series":[],"collection":[{"ref":"/repositories/2/resources/1","display_string":"Papers"}],"active_restrictions":[]}`)
+		case "/container_profiles/1":
+			fmt.Fprintf(w, `{"uri":"/container_profiles/1","name":"Hollinger box","dimension_units":"inches","height":"10","width":"5","depth":"15"}`)
+		case "/locations/9":
+			fmt.Fprintf(w, `{"uri":"/locations/9","title":"Archives Vault [Room: 10]","building":"Archives"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	accession := new(Accession)
+	src := []byte(`{"uri":"/repositories/2/accessions/1","instances":[
+{"instance_type":"mixed_materials","sub_container":{"top_container":{"ref":"/repositories/2/top_containers/5"},"type_2":"folder","indicator_2":"1"}},
+{"instance_type":"mixed_materials","sub_container":{"top_container":{"ref":"/repositories/2/top_containers/5"},"type_2":"folder","indicator_2":"2"}},
+{"instance_type":"digital_object","digital_object":{"ref":"/repositories/2/digital_objects/3"}}]}`)
+	if err := json.Unmarshal(src, accession); err != nil {
+		t.Fatalf("Can't decode accession, %s", err)
+	}
+	containers, err := api.ResolveAccessionInstances(ctx, accession)
+	if err != nil {
+		t.Fatalf("ResolveAccessionInstances() %s", err)
+	}
+	if len(containers) != 3 {
+		t.Fatalf("expected 3 instances, got %d", len(containers))
+	}
+	ic := containers[1]
+	if ic.TopContainer == nil || ic.TopContainer.Indicator != "12" || ic.TopContainer.ID != 5 {
+		t.Errorf("unexpected top container %+v", ic.TopContainer)
+	}
+	if ic.SubContainer == nil || ic.SubContainer.Indicator2 != "2" {
+		t.Errorf("unexpected sub container %+v", ic.SubContainer)
+	}
+	if ic.ContainerProfile == nil || ic.ContainerProfile.Depth != "15" {
+		t.Errorf("unexpected container profile %+v", ic.ContainerProfile)
+	}
+	if ic.ContainerLocation == nil || ic.ContainerLocation.StartDate != "2017-06-01" {
+		t.Errorf("unexpected container location %+v", ic.ContainerLocation)
+	}
+	if ic.Location == nil || ic.Location.Building != "Archives" {
+		t.Errorf("unexpected location %+v", ic.Location)
+	}
+	if containers[2].TopContainer != nil || containers[2].InstanceType != "digital_object" {
+		t.Errorf("expected an unresolved digital object instance %+v", containers[2])
+	}
+	if requests["/repositories/2/top_containers/5"] != 1 {
+		t.Errorf("expected shared top container to be fetched once, %d", requests["/repositories/2/top_containers/5"])
+	}
+}
+
+func TestLinkedEvents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/2/events/7":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/events/7","event_type":"accession","outcome":"pass",
+"date":{"date_type":"single","label":"event","begin":"2017-01-10"},
+"linked_agents":[{"role":"implementer","ref":"/agents/people/3"}],
+"linked_records":[{"role":"source","ref":"/repositories/2/accessions/1"}]}`)
+		case "/repositories/2/events/8":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/events/8","event_type":"processed","linked_records":[{"role":"outcome","ref":"/repositories/2/accessions/1"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"Event not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	accession := &Accession{
+		URI: "/repositories/2/accessions/1",
+		LinkedEvents: []map[string]interface{}{
+			{"ref": "/repositories/2/events/7"},
+			{"ref": "/repositories/2/events/8"},
+		},
+	}
+	events, err := api.GetAccessionEvents(ctx, accession)
+	if err != nil {
+		t.Fatalf("GetAccessionEvents() %s", err)
+	}
+	if len(events) != 2 || events[0].ID != 7 || events[1].EventType != "processed" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if len(events[0].LinkedAgents) != 1 || events[0].LinkedAgents[0]["role"] != "implementer" {
+		t.Errorf("unexpected linked agents %+v", events[0].LinkedAgents)
+	}
+	if len(events[0].LinkedRecords) != 1 || events[0].LinkedRecords[0]["ref"] != accession.URI {
+		t.Errorf("unexpected linked records %+v", events[0].LinkedRecords)
+	}
+
+	accession.LinkedEvents = append(accession.LinkedEvents, map[string]interface{}{"ref": "/repositories/2/events/9"})
+	if _, err := api.GetAccessionEvents(ctx, accession); err == nil {
+		t.Errorf("expected an error resolving a missing event")
+	}
+}
+
+func TestRightsStatements(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/repositories/2/accessions/1":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/accessions/1","title":"Papers of Jane Doe",
+"rights_statements":[{"rights_type":"copyright","status":"copyrighted","start_date":"2017-01-10",
+"linked_agents":[{"role":"rights_holder","ref":"/agents/people/3"}]},
+{"rights_type":"license","linked_agents":[{"role":"rights_holder","ref":"/agents/people/3"},{"role":"rights_holder","ref":"/agents/corporate_entities/4"}]}],
+"collection_management":{"uri":"/repositories/2/collection_management/5","processing_priority":"high"}}`)
+		case "/repositories/2/collection_management/5":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/collection_management/5","processing_priority":"high","processing_plan":"Rehouse","processors":"jdoe"}`)
+		case "/agents/people/3":
+			fmt.Fprintf(w, `{"uri":"/agents/people/3","title":"Jane Doe","agent_type":"agent_person"}`)
+		case "/agents/corporate_entities/4":
+			fmt.Fprintf(w, `{"uri":"/agents/corporate_entities/4","title":"Caltech","agent_type":"agent_corporate_entity"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"Record not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+
+	accession, err := api.GetAccession(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("GetAccession() %s", err)
+	}
+	if len(accession.RightsStatements) != 2 || accession.RightsStatements[0].Status != "copyrighted" || accession.RightsStatements[0].StartDate != "2017-01-10" {
+		t.Fatalf("unexpected rights statements %s", stringify(accession.RightsStatements))
+	}
+	agents, err := api.GetAccessionRightsStatements(ctx, accession)
+	if err != nil {
+		t.Fatalf("GetAccessionRightsStatements() %s", err)
+	}
+	if len(agents) != 3 || agents[0].Role != "rights_holder" || agents[0].Agent.Title != "Jane Doe" || agents[2].Agent.Title != "Caltech" {
+		t.Fatalf("unexpected rights statement agents %s", stringify(agents))
+	}
+	if agents[1].Statement != accession.RightsStatements[1] {
+		t.Errorf("expected the second agent to come from the license statement")
+	}
+	if requests["/agents/people/3"] != 1 {
+		t.Errorf("expected a shared agent to be fetched once, %d", requests["/agents/people/3"])
+	}
+
+	cm, err := api.GetAccessionCollectionManagement(ctx, accession)
+	if err != nil {
+		t.Fatalf("GetAccessionCollectionManagement() %s", err)
+	}
+	if cm.ProcessingPlan != "Rehouse" || cm.Processors != "jdoe" {
+		t.Errorf("unexpected collection management %s", stringify(cm))
+	}
+
+	resource := &Resource{
+		RightsStatements:     []*RightsStatement{{RightsType: "statute", LinkedAgents: []map[string]interface{}{{"role": "rights_holder", "ref": "/agents/people/9"}}}},
+		CollectionManagement: &CollectionManagement{ProcessingPriority: "low"},
+	}
+	if cm, err := api.GetResourceCollectionManagement(ctx, resource); err != nil || cm != resource.CollectionManagement {
+		t.Errorf("expected an unsaved collection management record as is, got %v, %v", cm, err)
+	}
+	if _, err := api.GetResourceRightsStatements(ctx, resource); err == nil {
+		t.Errorf("expected an error resolving a missing agent")
+	}
+}
+
+func TestProvisionUser(t *testing.T) {
+	roster := `username,first_name,last_name,email,password,repo_id,groups
+jdoe,Jane,Doe,jdoe@example.edu,secret,2,repository-basic-data-entry;repository-viewers
+asmith,Alex,Smith,asmith@example.edu,,2,
+`
+	entries, err := ReadRoster(strings.NewReader(roster))
+	if err != nil {
+		t.Fatalf("ReadRoster() %s", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "Jane Doe" || len(entries[0].Groups) != 2 || entries[1].RepoID != 2 {
+		t.Fatalf("unexpected roster entries %+v", entries)
+	}
+	if _, err := ReadRoster(strings.NewReader("name,email\nJane,jdoe@example.edu\n")); err == nil {
+		t.Errorf("expected an error for a roster without usernames")
+	}
+
+	var (
+		mu      sync.Mutex
+		created []string
+		updated []string
+		groups  []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/users/byusername/jdoe":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"User not found"}`)
+		case r.URL.Path == "/users/byusername/asmith":
+			fmt.Fprintf(w, `{"uri":"/users/4","username":"asmith","name":"A. Smith"}`)
+		case r.Method == "POST" && r.URL.Path == "/users":
+			if r.URL.Query().Get("password") != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error":{"password":["Property is required but was missing"]}}`)
+				return
+			}
+			created = append(created, r.URL.Path)
+			fmt.Fprintf(w, `{"status":"Created","id":5,"lock_version":0,"uri":"/users/5"}`)
+		case r.Method == "POST" && r.URL.Path == "/users/4":
+			updated = append(updated, r.URL.Path)
+			fmt.Fprintf(w, `{"status":"Updated","id":4,"lock_version":1,"uri":"/users/4"}`)
+		case r.URL.Path == "/repositories/2/groups":
+			fmt.Fprintf(w, `[{"uri":"/repositories/2/groups/10","group_code":"repository-basic-data-entry"},{"uri":"/repositories/2/groups/11","group_code":"repository-viewers"}]`)
+		case r.Method == "POST" && r.URL.Path == "/users/5/groups":
+			q := r.URL.Query()
+			if q.Get("repo_id") != "2" || q.Get("remove_groups") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error":"unexpected query %s"}`, r.URL.RawQuery)
+				return
+			}
+			groups = append(groups, q["groups[]"]...)
+			fmt.Fprintf(w, `{"status":"Updated","id":5,"lock_version":1,"uri":"/users/5"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	for _, entry := range entries {
+		if _, err := api.ProvisionUser(ctx, entry); err != nil {
+			t.Errorf("ProvisionUser(%q) %s", entry.Username, err)
+		}
+	}
+	if len(created) != 1 || len(updated) != 1 {
+		t.Errorf("expected one created and one updated account, %v, %v", created, updated)
+	}
+	if strings.Join(groups, " ") != "/repositories/2/groups/10 /repositories/2/groups/11" {
+		t.Errorf("unexpected group assignment %v", groups)
+	}
+
+	entry := &RosterEntry{Username: "jdoe", Password: "secret", RepoID: 2, Groups: []string{"no-such-group"}}
+	if _, err := api.ProvisionUser(ctx, entry); err == nil {
+		t.Errorf("expected an error for an unknown group code")
+	}
+}
+
+func TestJobs(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		polls     int
+		submitted map[string]interface{}
+	)
+	jobLog := "Starting report\nReport complete\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repositories/2/jobs":
+			json.NewDecoder(r.Body).Decode(&submitted)
+			fmt.Fprintf(w, `{"status":"Created","id":5,"lock_version":0,"uri":"/repositories/2/jobs/5"}`)
+		case r.URL.Path == "/repositories/2/jobs/5":
+			polls++
+			status := "running"
+			if polls > 2 {
+				status = "completed"
+			}
+			fmt.Fprintf(w, `{"uri":"/repositories/2/jobs/5","job_type":"report_job","status":%q}`, status)
+		case r.URL.Path == "/repositories/2/jobs/5/log":
+			// the log grows by a line while the job is running
+			offset := 0
+			fmt.Sscanf(r.URL.Query().Get("offset"), "%d", &offset)
+			end := strings.Index(jobLog, "\n") + 1
+			if polls > 2 {
+				end = len(jobLog)
+			}
+			if offset < end {
+				fmt.Fprintf(w, "%s", jobLog[offset:end])
+			}
+		case r.URL.Path == "/repositories/2/jobs/5/output_files":
+			fmt.Fprintf(w, `[9]`)
+		case r.URL.Path == "/repositories/2/jobs/5/output_files/9":
+			fmt.Fprintf(w, "id,title\n1,Papers\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	job, err := NewJob("report_job", &ReportJob{ReportType: "repository_report", Format: "csv"})
+	if err != nil {
+		t.Fatalf("NewJob() %s", err)
+	}
+	if _, err := api.SubmitJob(ctx, 2, job); err != nil {
+		t.Fatalf("SubmitJob() %s", err)
+	}
+	if job.ID != 5 {
+		t.Errorf("expected job ID 5, got %d", job.ID)
+	}
+	if params, ok := submitted["job"].(map[string]interface{}); ok == false || params["jsonmodel_type"] != "report_job" || params["report_type"] != "repository_report" {
+		t.Errorf("unexpected job submitted %+v", submitted)
+	}
+
+	buf := new(bytes.Buffer)
+	job, err = api.StreamJobLog(ctx, 2, 5, buf, time.Millisecond)
+	if err != nil {
+		t.Fatalf("StreamJobLog() %s", err)
+	}
+	if job.Status != "completed" || buf.String() != jobLog {
+		t.Errorf("unexpected job %s, log %q", job.Status, buf.String())
+	}
+
+	fileIDs, err := api.ListJobOutputFiles(ctx, 2, 5)
+	if err != nil || len(fileIDs) != 1 {
+		t.Fatalf("ListJobOutputFiles() %v, %s", fileIDs, err)
+	}
+	buf.Reset()
+	if err := api.GetJobOutputFile(ctx, 2, 5, fileIDs[0], buf); err != nil {
+		t.Fatalf("GetJobOutputFile() %s", err)
+	}
+	if strings.HasPrefix(buf.String(), "id,title") == false {
+		t.Errorf("unexpected output file %q", buf.String())
+	}
+	buf.Reset()
+	err = api.GetJobOutputFile(ctx, 2, 5, 10, buf)
+	apiErr := new(APIError)
+	if errors.As(err, &apiErr) == false || apiErr.StatusCode != http.StatusNotFound || buf.Len() != 0 {
+		t.Errorf("expected a 404 APIError and no output for a missing file, got %v, %q", err, buf.String())
+	}
+
+	mu.Lock()
+	polls = 0
+	mu.Unlock()
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := api.WaitForJob(waitCtx, 2, 5, time.Second); err == nil {
+		t.Errorf("expected WaitForJob() to time out")
+	}
+}
+
+func TestEnumerations(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches int
+		posts   int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/config/enumerations":
+			fetches++
+			fmt.Fprintf(w, `[
+{"uri":"/config/enumerations/1","name":"extent_extent_type","editable":true,"values":["linear_feet","cubic_feet","reels"],
+"enumeration_values":[{"uri":"/config/enumeration_values/1","value":"linear_feet"},{"uri":"/config/enumeration_values/2","value":"cubic_feet"},{"uri":"/config/enumeration_values/3","value":"reels","suppressed":true}]},
+{"uri":"/config/enumerations/2","name":"date_type","values":["single","inclusive","bulk"]},
+{"uri":"/config/enumerations/3","name":"resource_resource_type","values":["collection","papers","records"]},
+{"uri":"/config/enumerations/4","name":"accession_resource_type","values":["collection","publications"]}]`)
+		case r.Method == "POST" && r.URL.Path == "/config/enumerations/migration":
+			var migration map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&migration)
+			if migration["enum_uri"] != "/config/enumerations/1" || migration["from"] != "reels" || migration["to"] != "linear_feet" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error":"unexpected migration %v"}`, migration)
+				return
+			}
+			fmt.Fprintf(w, `{"status":"Updated","id":1}`)
+		case r.Method == "POST":
+			posts++
+			fmt.Fprintf(w, `{"status":"Created","id":3,"uri":"/repositories/2/accessions/3"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.ValidateEnumerations = true
+	ctx := context.Background()
+
+	accession := &Accession{
+		Title:        "Test accession",
+		ResourceType: "collection",
+		Extents:      []*Extent{{Portion: "whole", Number: "2", ExtentType: "linear_feet"}},
+		Dates:        []*Date{{DateType: "single", Expression: "1920"}},
+	}
+	if _, err := api.CreateAccession(ctx, 2, accession); err != nil {
+		t.Fatalf("CreateAccession() with valid values %s", err)
+	}
+
+	accession.ResourceType = "scrapbook"
+	accession.Extents[0].ExtentType = "reels"
+	accession.Dates[0].DateType = "circa"
+	_, err := api.CreateAccession(ctx, 2, accession)
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) == false {
+		t.Fatalf("expected a *ValidationError, got %T %s", err, err)
+	}
+	for _, field := range []string{"resource_type", "extents/0/extent_type", "dates/0/date_type"} {
+		if _, ok := validationErr.Validation[field]; ok == false {
+			t.Errorf("expected %s to be invalid, %s", field, validationErr)
+		}
+	}
+	if posts != 1 || fetches != 1 {
+		t.Errorf("expected one POST and one enumeration fetch, %d, %d", posts, fetches)
+	}
+
+	// Accessions and resources have their own resource_type enumerations
+	table, err := api.EnumerationTable(ctx)
+	if err != nil {
+		t.Fatalf("EnumerationTable() %s", err)
+	}
+	if err := table.Validate(&Accession{ResourceType: "publications"}); err != nil {
+		t.Errorf("expected publications to be a valid accession resource_type, %s", err)
+	}
+	if err := table.Validate(&Accession{ResourceType: "records"}); errors.As(err, &validationErr) == false {
+		t.Errorf("expected records to be an invalid accession resource_type, got %v", err)
+	}
+	if err := table.Validate(&Resource{ResourceType: "records"}); err != nil {
+		t.Errorf("expected records to be a valid resource resource_type, %s", err)
+	}
+	if err := table.Validate(&Resource{ResourceType: "publications"}); errors.As(err, &validationErr) == false {
+		t.Errorf("expected publications to be an invalid resource resource_type, got %v", err)
+	}
+
+	if _, err := api.MigrateEnumerationValue(ctx, 1, "reels", "linear_feet"); err != nil {
+		t.Errorf("MigrateEnumerationValue() %s", err)
+	}
+	if _, err := api.EnumerationTable(ctx); err != nil || fetches != 2 {
+		t.Errorf("expected the enumeration cache to be reset after a migration, %d fetches, %v", fetches, err)
+	}
+}
+
+func TestClassifications(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/2/classifications":
+			fmt.Fprintf(w, `{"first_page":1,"last_page":1,"this_page":1,"total":1,"results":[
+{"uri":"/repositories/2/classifications/1","identifier":"RG1","title":"Office of the President","jsonmodel_type":"classification"}]}`)
+		case "/repositories/2/classification_terms":
+			fmt.Fprintf(w, `{"first_page":1,"last_page":1,"this_page":1,"total":2,"results":[
+{"uri":"/repositories/2/classification_terms/3","identifier":"1","title":"Correspondence","jsonmodel_type":"classification_term",
+"classification":{"ref":"/repositories/2/classifications/1"}},
+{"uri":"/repositories/2/classification_terms/4","identifier":"2","title":"Letters","jsonmodel_type":"classification_term",
+"classification":{"ref":"/repositories/2/classifications/1"},"parent":{"ref":"/repositories/2/classification_terms/3"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	classifications, err := api.GetClassificationMap(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetClassificationMap() %s", err)
+	}
+	if len(classifications.Classifications) != 1 || len(classifications.Terms) != 2 {
+		t.Fatalf("unexpected classification map %+v", classifications)
+	}
+	view := classifications.Resolve("/repositories/2/classification_terms/4")
+	if view == nil {
+		t.Fatalf("expected a view for term 4")
+	}
+	if view.URI != "/repositories/2/classification_terms/4" || view.Title != "Letters" || view.Identifier != "RG1/1/2" {
+		t.Errorf("unexpected view %+v", view)
+	}
+	expected := []string{"RG1 Office of the President", "1 Correspondence", "2 Letters"}
+	if strings.Join(view.Hierarchy, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected hierarchy %q, got %q", expected, view.Hierarchy)
+	}
+	if classifications.Resolve("/repositories/2/classification_terms/99") != nil {
+		t.Errorf("expected nil for an unknown term")
+	}
+
+	accession := &Accession{
+		URI: "/repositories/2/accessions/1",
+		Classifications: []map[string]interface{}{
+			{"ref": "/repositories/2/classification_terms/4"},
+		},
+	}
+	v, err := accession.NormalizeView(nil, nil, nil, classifications)
+	if err != nil {
+		t.Fatalf("NormalizeView() %s", err)
+	}
+	if len(v.Classifications) != 1 || v.Classifications[0].Identifier != "RG1/1/2" {
+		t.Errorf("unexpected classifications %+v", v.Classifications)
+	}
+
+	// Refs resolved on export are used when there is no map
+	classifications.ResolveRefs(accession.Classifications)
+	src, _ := json.Marshal(accession)
+	accession = new(Accession)
+	json.Unmarshal(src, &accession)
+	v, err = accession.NormalizeView(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NormalizeView() %s", err)
+	}
+	if len(v.Classifications) != 1 || len(v.Classifications[0].Hierarchy) != 3 {
+		t.Errorf("unexpected classifications from _resolved %+v", v.Classifications)
+	}
+}
+
+func TestDigitalObjectComponents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/2/digital_objects/5/tree/root":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/digital_objects/5","title":"Oral history","jsonmodel_type":"digital_object","child_count":2,"waypoints":1,"waypoint_size":10,
+"precomputed_waypoints":{"":{"0":[
+{"uri":"/repositories/2/digital_object_components/7","title":"Tape 1","position":0,"child_count":1,"waypoints":1,"waypoint_size":10},
+{"uri":"/repositories/2/digital_object_components/8","title":"Tape 2","position":1,"child_count":0,"waypoints":0,"waypoint_size":10}]}}}`)
+		case "/repositories/2/digital_objects/5/tree/waypoint":
+			fmt.Fprintf(w, `[{"uri":"/repositories/2/digital_object_components/9","title":"Side A","position":0,"child_count":0,"waypoints":0,"waypoint_size":10}]`)
+		case "/repositories/2/digital_object_components":
+			if ids := r.URL.Query()["id_set[]"]; strings.Join(ids, ",") != "7,9,8" {
+				t.Errorf("unexpected id_set %q", ids)
+			}
+			fmt.Fprintf(w, `[
+{"uri":"/repositories/2/digital_object_components/8","title":"Tape 2","position":1,"publish":true,"digital_object":{"ref":"/repositories/2/digital_objects/5"},
+"file_versions":[{"file_uri":"https://example.edu/tape2.mp3","publish":true}]},
+{"uri":"/repositories/2/digital_object_components/9","title":"Side A","position":0,"publish":true,"digital_object":{"ref":"/repositories/2/digital_objects/5"},
+"parent":{"ref":"/repositories/2/digital_object_components/7"},
+"file_versions":[{"file_uri":"https://example.edu/tape1a.mp3","publish":true},{"file_uri":"https://example.edu/tape1a.wav","publish":true}]},
+{"uri":"/repositories/2/digital_object_components/7","title":"Tape 1","position":0,"publish":true,"digital_object":{"ref":"/repositories/2/digital_objects/5"}}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	obj := &DigitalObject{
+		URI:          "/repositories/2/digital_objects/5",
+		Title:        "Oral history",
+		FileVersions: []*FileVersion{{FileURI: "https://example.edu/transcript.pdf"}},
+	}
+	components, err := api.ResolveDigitalObjectComponents(ctx, obj)
+	if err != nil {
+		t.Fatalf("ResolveDigitalObjectComponents() %s", err)
+	}
+	if len(components) != 3 || components[0].ID != 7 || components[1].ID != 9 || components[2].ID != 8 {
+		t.Fatalf("expected components in tree order, got %+v", components)
+	}
+
+	view := obj.NormalizeView()
+	if len(view.FileURIs) != 1 || len(view.FileVersions) != 1 {
+		t.Errorf("unexpected file versions %+v", view.FileVersions)
+	}
+	if len(view.Components) != 3 {
+		t.Fatalf("expected 3 components, got %+v", view.Components)
+	}
+	titles := []string{}
+	for _, c := range view.Components {
+		titles = append(titles, fmt.Sprintf("%d %s %d", c.Depth, c.Title, len(c.FileURIs)))
+	}
+	expected := "1 Tape 1 0, 2 Side A 2, 1 Tape 2 1"
+	if strings.Join(titles, ", ") != expected {
+		t.Errorf("expected %q, got %q", expected, strings.Join(titles, ", "))
+	}
+	if view.Components[1].FileURIs[1] != "https://example.edu/tape1a.wav" {
+		t.Errorf("expected file versions in order, got %+v", view.Components[1].FileURIs)
+	}
+
+	tree, err := api.GetDigitalObjectTree(ctx, 2, 5)
+	if err != nil {
+		t.Fatalf("GetDigitalObjectTree() %s", err)
+	}
+	if len(tree.Children) != 2 || len(tree.Children[0].Children) != 1 || tree.Children[0].Children[0].Title != "Side A" {
+		t.Errorf("unexpected tree %+v", tree)
+	}
+}
+
+func TestAgentMerge(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		updates []map[string]interface{}
+		merges  []map[string]interface{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/agents/families/4" && r.Method == "POST":
+			obj := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&obj)
+			mu.Lock()
+			updates = append(updates, obj)
+			mu.Unlock()
+			fmt.Fprintf(w, `{"status":"Updated","id":4,"lock_version":2}`)
+		case r.URL.Path == "/merge_requests/agent":
+			obj := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&obj)
+			mu.Lock()
+			merges = append(merges, obj)
+			mu.Unlock()
+			fmt.Fprintf(w, `{"status":"OK"}`)
+		case r.URL.Path == "/search":
+			q := r.URL.Query()
+			if q.Get("q") != `agent_uris:"/agents/people/2"` {
+				fmt.Fprintf(w, `{"first_page":1,"last_page":1,"this_page":1,"results":[]}`)
+				return
+			}
+			if q.Get("page") == "1" {
+				fmt.Fprintf(w, `{"first_page":1,"last_page":2,"this_page":1,"results":[{"uri":"/repositories/2/accessions/1","title":"Papers","primary_type":"accession"}]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"first_page":1,"last_page":2,"this_page":2,"results":[{"uri":"/repositories/2/resources/3","title":"Collection","primary_type":"resource"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	agent := &Agent{URI: "/agents/families/4", Title: "Smith family"}
+	rel := &AgentRelationshipParentchild{Relator: "is_parent_of", Ref: "/agents/people/2"}
+	if _, err := api.AddAgentRelationship(ctx, agent, rel); err != nil {
+		t.Fatalf("AddAgentRelationship() %s", err)
+	}
+	if _, err := api.AddAgentRelationship(ctx, agent, rel); err == nil {
+		t.Errorf("expected an error adding a duplicate relationship")
+	}
+	relationships, err := agent.Relationships()
+	if err != nil {
+		t.Fatalf("Relationships() %s", err)
+	}
+	if len(relationships) != 1 {
+		t.Fatalf("expected one relationship, got %+v", relationships)
+	}
+	if pc, ok := relationships[0].(*AgentRelationshipParentchild); ok == false || pc.Ref != "/agents/people/2" {
+		t.Errorf("unexpected relationship %+v", relationships[0])
+	}
+	if _, err := api.RemoveAgentRelationship(ctx, agent, "is_parent_of", "/agents/people/2"); err != nil {
+		t.Fatalf("RemoveAgentRelationship() %s", err)
+	}
+	if _, err := api.RemoveAgentRelationship(ctx, agent, "", "/agents/people/2"); err == nil {
+		t.Errorf("expected an error removing a missing relationship")
+	}
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 updates, got %d", len(updates))
+	}
+	related, _ := updates[0]["related_agents"].([]interface{})
+	if len(related) != 1 || related[0].(map[string]interface{})["jsonmodel_type"] != "agent_relationship_parentchild" {
+		t.Errorf("unexpected related agents %+v", updates[0]["related_agents"])
+	}
+	if _, ok := updates[1]["related_agents"]; ok == true {
+		t.Errorf("expected the relationship to be removed, %+v", updates[1])
+	}
+
+	req := NewMergeRequest("/agents/people/1", "/agents/people/2", "/agents/people/5")
+	preview, err := api.PreviewAgentMerge(ctx, req)
+	if err != nil {
+		t.Fatalf("PreviewAgentMerge() %s", err)
+	}
+	if len(preview.Relinked["/agents/people/2"]) != 2 || len(preview.Relinked["/agents/people/5"]) != 0 {
+		t.Errorf("unexpected preview %+v", preview.Relinked)
+	}
+	if len(merges) != 0 {
+		t.Errorf("preview should not submit a merge request")
+	}
+	if _, err := api.MergeAgents(ctx, req); err != nil {
+		t.Fatalf("MergeAgents() %s", err)
+	}
+	if len(merges) != 1 || merges[0]["jsonmodel_type"] != "merge_request" || len(merges[0]["victims"].([]interface{})) != 2 {
+		t.Errorf("unexpected merge request %+v", merges)
+	}
+	if _, err := api.MergeAgents(ctx, NewMergeRequest("/agents/people/1", "/agents/people/1")); err == nil {
+		t.Errorf("expected an error merging an agent into itself")
+	}
+}
+
+func TestLocationBatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/locations/batch":
+			batch := new(LocationBatch)
+			json.NewDecoder(r.Body).Decode(&batch)
+			if batch.Building != "Archives" || batch.Coordinate1Range == nil || batch.Coordinate2Range.End != "B" {
+				t.Errorf("unexpected batch %+v", batch)
+			}
+			if r.URL.Query().Get("dry_run") == "true" {
+				fmt.Fprintf(w, `[{"title":"Archives, 2, 201 [Range: 1, Shelf: A]"},{"title":"Archives, 2, 201 [Range: 1, Shelf: B]"},
+{"title":"Archives, 2, 201 [Range: 2, Shelf: A]"},{"title":"Archives, 2, 201 [Range: 2, Shelf: B]"}]`)
+				return
+			}
+			fmt.Fprintf(w, `["/locations/1","/locations/2","/locations/3","/locations/4"]`)
+		case "/locations/batch_update":
+			update := new(LocationBatchUpdate)
+			json.NewDecoder(r.Body).Decode(&update)
+			if len(update.RecordURIs) != 2 || update.Room != "202" || update.JSONModelType != "location_batch_update" {
+				t.Errorf("unexpected batch update %+v", update)
+			}
+			fmt.Fprintf(w, `{"status":"Updated"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	batch := &LocationBatch{Building: "Archives", Floor: "2", Room: "201"}
+	if _, err := api.CreateLocationBatch(ctx, batch, true); err == nil {
+		t.Errorf("expected an error for a batch without coordinate ranges")
+	}
+	if err := batch.SetCoordinateRanges("Range:1-2", "Shelf"); err == nil {
+		t.Errorf("expected an error parsing a range without bounds")
+	}
+	if err := batch.SetCoordinateRanges("Range:1-2", "Shelf:A-B"); err != nil {
+		t.Fatalf("SetCoordinateRanges() %s", err)
+	}
+	if batch.Coordinate1Range.Label != "Range" || batch.Coordinate2Range.Start != "A" || batch.Coordinate3Range != nil {
+		t.Errorf("unexpected ranges %+v %+v %+v", batch.Coordinate1Range, batch.Coordinate2Range, batch.Coordinate3Range)
+	}
+	titles, err := api.CreateLocationBatch(ctx, batch, true)
+	if err != nil {
+		t.Fatalf("CreateLocationBatch(dry run) %s", err)
+	}
+	if len(titles) != 4 || titles[3] != "Archives, 2, 201 [Range: 2, Shelf: B]" {
+		t.Errorf("unexpected titles %q", titles)
+	}
+	uris, err := api.CreateLocationBatch(ctx, batch, false)
+	if err != nil {
+		t.Fatalf("CreateLocationBatch() %s", err)
+	}
+	if len(uris) != 4 || uris[0] != "/locations/1" {
+		t.Errorf("unexpected uris %q", uris)
+	}
+
+	update := &LocationBatchUpdate{Room: "202", RecordURIs: []string{"/locations/1", "/locations/2"}}
+	if _, err := api.UpdateLocationBatch(ctx, update); err != nil {
+		t.Fatalf("UpdateLocationBatch() %s", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	aq := NewAdvancedQuery(And(
+		Field("title", "oral history"),
+		Or(Literal("primary_type", "accession"), Field("keyword", "tape").Negate()),
+		DateField("create_time", "greater_than", "2016-01-01"),
+		BoolField("publish", false),
+	))
+	src, err := json.Marshal(aq)
+	if err != nil {
+		t.Fatalf("json.Marshal(aq) %s", err)
+	}
+	expected := `{"jsonmodel_type":"advanced_query","query":{"jsonmodel_type":"boolean_query","op":"AND","subqueries":[` +
+		`{"field":"title","jsonmodel_type":"field_query","literal":false,"negated":false,"value":"oral history"},` +
+		`{"jsonmodel_type":"boolean_query","op":"OR","subqueries":[` +
+		`{"field":"primary_type","jsonmodel_type":"field_query","literal":true,"negated":false,"value":"accession"},` +
+		`{"field":"keyword","jsonmodel_type":"field_query","literal":false,"negated":true,"value":"tape"}]},` +
+		`{"comparator":"greater_than","field":"create_time","jsonmodel_type":"date_field_query","value":"2016-01-01"},` +
+		`{"field":"publish","jsonmodel_type":"boolean_field_query","value":false}]}}`
+	if string(src) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, src)
+	}
+	decoded := new(AdvancedQuery)
+	if err := json.Unmarshal(src, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal(aq) %s", err)
+	}
+	if bq, ok := decoded.Query.(*BooleanQuery); ok == false || len(bq.Subqueries) != 4 {
+		t.Fatalf("unexpected decoded query %+v", decoded.Query)
+	} else if dq, ok := bq.Subqueries[2].(*DateFieldQuery); ok == false || dq.Value != "2016-01-01" {
+		t.Errorf("unexpected date field query %+v", bq.Subqueries[2])
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repositories/2/search" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("aq") != expected || q.Get("type[]") != "accession" || q.Get("facet[]") != "subjects" {
+			t.Errorf("unexpected search query %s", r.URL.RawQuery)
+		}
+		if q.Get("page") == "1" {
+			fmt.Fprintf(w, `{"first_page":1,"last_page":2,"this_page":1,"page_size":1,"total_hits":2,
+"results":[{"id":"/repositories/2/accessions/1","uri":"/repositories/2/accessions/1","title":"Oral histories","primary_type":"accession",
+"json":"{\"uri\":\"/repositories/2/accessions/1\",\"title\":\"Oral histories\",\"id_0\":\"2016-001\"}"}],
+"facets":{"facet_fields":{"subjects":["Music",2,"Physics",1]}}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"first_page":1,"last_page":2,"this_page":2,"page_size":1,"total_hits":2,
+"results":[{"id":"/repositories/2/accessions/2","uri":"/repositories/2/accessions/2","title":"More oral histories","primary_type":"accession"}]}`)
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", "")
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	if _, err := api.Search(ctx, 2, &SearchOptions{}); err == nil {
+		t.Errorf("expected an error searching without a query")
+	}
+	opts := &SearchOptions{AdvancedQuery: aq, Types: []string{"accession"}, Facets: []string{"subjects"}, PageSize: 1}
+	results, err := api.Search(ctx, 2, opts)
+	if err != nil {
+		t.Fatalf("Search() %s", err)
+	}
+	if results.TotalHits != 2 || len(results.Results) != 1 || results.LastPage != 2 {
+		t.Errorf("unexpected results %+v", results)
+	}
+	counts := results.Facets.Counts("subjects")
+	if len(counts) != 2 || counts[0].Value != "Music" || counts[0].Count != 2 {
+		t.Errorf("unexpected facet counts %+v", counts)
+	}
+	accession := new(Accession)
+	if err := results.Results[0].Decode(accession); err != nil || accession.ID0 != "2016-001" {
+		t.Errorf("unexpected decoded accession %+v, %v", accession, err)
+	}
+
+	uris := []string{}
+	err = api.EachSearchResult(ctx, 2, opts, func(result *SearchResult) error {
+		uris = append(uris, result.URI)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachSearchResult() %s", err)
+	}
+	if strings.Join(uris, ",") != "/repositories/2/accessions/1,/repositories/2/accessions/2" {
+		t.Errorf("unexpected uris %q", uris)
+	}
+}
+
+func TestIncrementalExport(t *testing.T) {
+	since := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	queries := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.Query().Get("modified_since"))
+		if r.URL.Query().Get("all_ids") == "true" {
+			fmt.Fprintf(w, "[1,3]")
+			return
+		}
+		fmt.Fprintf(w, `{"first_page":1,"last_page":1,"this_page":1,"total":2,"results":[`+
+			`{"uri":"/subjects/1","title":"Maps","system_mtime":"2024-03-02T08:00:00Z"},`+
+			`{"uri":"/subjects/3","title":"Globes","system_mtime":"2024-03-05T10:30:00Z"}]}`)
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	ctx := context.Background()
+
+	titles := []string{}
+	err := api.SubjectList().EachModifiedSince(ctx, since, 10, func(subject *Subject) error {
+		titles = append(titles, subject.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubjectList().EachModifiedSince() %s", err)
+	}
+	if len(titles) != 2 || titles[1] != "Globes" {
+		t.Errorf("unexpected subjects %v", titles)
+	}
+	expected := fmt.Sprintf("/subjects?%d", since.Unix())
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("expected query %q, got %v", expected, queries)
+	}
+
+	state := &ExportState{Collection: "subjects.ds"}
+	if state.Since().IsZero() == false {
+		t.Errorf("expected a zero high-water mark, got %s", state.Since())
+	}
+	for _, mtime := range []string{"2024-03-02T08:00:00Z", "2024-03-05T10:30:00Z", "2024-03-04T00:00:00Z", "not a time"} {
+		state.Observe(mtime)
+	}
+	if state.SystemMTime != "2024-03-05T10:30:00Z" {
+		t.Errorf("expected high-water mark 2024-03-05T10:30:00Z, got %q", state.SystemMTime)
+	}
+	if exportStateKey("repository-2/accessions.ds") != "repository-2_accessions.ds.json" {
+		t.Errorf("unexpected export state key %q", exportStateKey("repository-2/accessions.ds"))
+	}
+
+	vanished := VanishedKeys([]string{"3.json", "1.json", "2.json", "collection.json", "7.json"}, []int{1, 3})
+	if strings.Join(vanished, ",") != "2.json,7.json" {
+		t.Errorf("expected 2.json and 7.json to have vanished, got %v", vanished)
+	}
+	if IsDeleted([]byte(`{"uri":"/subjects/2","_deleted":true}`)) == false || IsDeleted([]byte(`{"uri":"/subjects/1"}`)) == true {
+		t.Errorf("IsDeleted() didn't recognize the deleted mark")
+	}
+
+	queries = []string{}
+	api.Incremental = true
+	if err := api.ExportSubjects(ctx, false); err != nil {
+		t.Fatalf("ExportSubjects() %s", err)
+	}
+	if len(queries) != 2 || queries[0] != "/subjects?" || queries[1] != "/subjects?" {
+		t.Errorf("expected a full page request then an id list, got %v", queries)
+	}
+}
+
+func TestExportWorkers(t *testing.T) {
+	total, failPage := 450, 3
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		page, pageSize := 0, 0
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		fmt.Sscanf(r.URL.Query().Get("page_size"), "%d", &pageSize)
+		if page == failPage {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"error":"database unavailable"}`)
+			return
+		}
+		out := []string{}
+		for id := (page-1)*pageSize + 1; id <= total && id <= page*pageSize; id++ {
+			out = append(out, fmt.Sprintf(`{"uri":"/subjects/%d","title":"Subject %d"}`, id, id))
+		}
+		lastPage := (total + pageSize - 1) / pageSize
+		fmt.Fprintf(w, `{"first_page":1,"last_page":%d,"this_page":%d,"total":%d,"results":[%s]}`, lastPage, page, total, strings.Join(out, ","))
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	api.ExportWorkers = 2
+	updates := []ExportProgress{}
+	api.ExportProgress = func(progress ExportProgress) {
+		updates = append(updates, progress)
+	}
+	err := api.ExportSubjects(context.Background(), false)
+	report := new(ExportReport)
+	if errors.As(err, &report) == false {
+		t.Fatalf("expected an *ExportReport, got %v", err)
+	}
+	if len(report.Failures) != 1 || strings.Contains(report.Failures[0].URI, "page=3") == false {
+		t.Errorf("expected page 3 to be reported, got %s", report)
+	}
+	if maxActive > 2 {
+		t.Errorf("expected at most 2 requests at once, got %d", maxActive)
+	}
+	if len(updates) != 4 {
+		t.Fatalf("expected 4 progress updates, got %d", len(updates))
+	}
+	last := updates[len(updates)-1]
+	if last.Done != total-100 || last.Total != total || last.Collection != "subjects.ds" || last.Rate <= 0 {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+
+	progress := &ExportProgress{Done: 50, Total: 150, Started: time.Now().Add(-10 * time.Second)}
+	progress.update(progress.Started.Add(10 * time.Second))
+	if progress.Rate != 5 || progress.ETA != 20*time.Second {
+		t.Errorf("expected 5 records/s and a 20s ETA, got %s", progress)
+	}
+	// records skipped by resuming don't count towards the rate
+	progress = &ExportProgress{Done: 150, Resumed: 100, Total: 250, Started: time.Now().Add(-10 * time.Second)}
+	progress.update(progress.Started.Add(10 * time.Second))
+	if progress.Rate != 5 || progress.ETA != 20*time.Second {
+		t.Errorf("expected 5 records/s and a 20s ETA after resuming, got %s", progress)
+	}
+	api.ExportWorkers = 100
+	if api.exportWorkers() != MaxExportWorkers {
+		t.Errorf("expected workers to be bounded by %d, got %d", MaxExportWorkers, api.exportWorkers())
+	}
+}
+
+func TestExportCheckpoint(t *testing.T) {
+	ids := []int{}
+	for id := 1; id <= 250; id++ {
+		ids = append(ids, id*2)
+	}
+	for _, c := range []struct{ lastID, page int }{{0, 1}, {200, 2}, {201, 2}, {198, 1}, {500, 3}} {
+		if page := resumePage(ids, c.lastID, 100); page != c.page {
+			t.Errorf("expected resume after %d at page %d, got %d", c.lastID, c.page, page)
+		}
+	}
+
+	cp := NewExportCheckpoint()
+	if cp.Version != Version || cp.Started == "" {
+		t.Errorf("expected version and start time, got %+v", cp)
+	}
+	cp.SetLastID("subjects.ds", 200)
+	cp.Complete("repositories")
+	if cp.IsCompleted("repositories") == false || cp.IsCompleted("subjects.ds") == true || cp.LastID("subjects.ds") != 200 {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+
+	total := 450
+	pages := []int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("all_ids") == "true" {
+			out := []string{}
+			for id := 1; id <= total; id++ {
+				out = append(out, fmt.Sprintf("%d", id))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(out, ","))
+			return
+		}
+		page, pageSize := 0, 0
+		fmt.Sscanf(q.Get("page"), "%d", &page)
+		fmt.Sscanf(q.Get("page_size"), "%d", &pageSize)
+		pages = append(pages, page)
+		out := []string{}
+		for id := (page-1)*pageSize + 1; id <= total && id <= page*pageSize; id++ {
+			out = append(out, fmt.Sprintf(`{"uri":"/subjects/%d","title":"Subject %d"}`, id, id))
+		}
+		lastPage := (total + pageSize - 1) / pageSize
+		fmt.Fprintf(w, `{"first_page":1,"last_page":%d,"this_page":%d,"total":%d,"results":[%s]}`, lastPage, page, total, strings.Join(out, ","))
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	if err := api.exportSubjects(context.Background(), cp, false); err != nil {
+		t.Fatalf("exportSubjects() %s", err)
+	}
+	if fmt.Sprintf("%v", pages) != "[3 4 5]" {
+		t.Errorf("expected pages 3 to 5 to be fetched, got %v", pages)
+	}
+	if cp.LastID("subjects.ds") != total {
+		t.Errorf("expected last id %d, got %d", total, cp.LastID("subjects.ds"))
+	}
+}
+
+func TestLayout(t *testing.T) {
+	if name := DefaultLayout.Collection("accession", 2); name != "repository-2/accessions.ds" {
+		t.Errorf("unexpected accession collection %q", name)
+	}
+	if name := DefaultLayout.Collection("agent", "people"); name != "agents.ds/people" {
+		t.Errorf("unexpected agent collection %q", name)
+	}
+	if name := LegacyLayout.Collection("subject"); name != "subjects" {
+		t.Errorf("unexpected legacy subject collection %q", name)
+	}
+	if name := DefaultLayout.Collection("widget"); name != "" {
+		t.Errorf("expected no collection for an unknown type, got %q", name)
+	}
+	names := LegacyLayout.Collections("agent", nil)
+	if len(names) != len(AgentTypes) || names[0] != "agents/people" {
+		t.Errorf("unexpected agent collections %v", names)
+	}
+	names = DefaultLayout.Collections("classification", []int{2, 3})
+	if strings.Join(names, ",") != "repository-2/classifications.ds,repository-3/classifications.ds" {
+		t.Errorf("unexpected classification collections %v", names)
+	}
+
+	api := New("http://localhost:8089", "", "", t.TempDir())
+	api.Layout = Layout{"subject": "terms/subjects"}
+	if api.Collection("subject") != "terms/subjects" || api.Collection("accession", 4) != "repository-4/accessions.ds" {
+		t.Errorf("expected api.Layout to override DefaultLayout, got %q and %q", api.Collection("subject"), api.Collection("accession", 4))
+	}
+	moves, err := api.MigrateLayout(LegacyLayout, DefaultLayout, []int{2}, true)
+	if err != nil {
+		t.Fatalf("MigrateLayout() %s", err)
+	}
+	if len(moves) != 0 {
+		t.Errorf("expected nothing to move in an empty dataset, got %d moves", len(moves))
+	}
+
+	// A legacy dataset with records is moved, a record already in the new
+	// collection is kept when it matches
+	api = New("http://localhost:8089", "", "", t.TempDir())
+	write := func(name, key, src string) {
+		c, err := CreateCollection(api, name)
+		if err != nil {
+			t.Fatalf("CreateCollection(%q) %s", name, err)
+		}
+		defer c.Close()
+		if err := WriteJSON(c, key, json.RawMessage(src)); err != nil {
+			t.Fatalf("WriteJSON(%q) %s", key, err)
+		}
+	}
+	keys := func(name string) string {
+		c, err := OpenCollection(api, name)
+		if err != nil {
+			return ""
+		}
+		defer c.Close()
+		return strings.Join(GetKeys(c), ",")
+	}
+	write("subjects", "1.json", `{"uri":"/subjects/1","title":"Science"}`)
+	write("subjects", "2.json", `{"uri":"/subjects/2","title":"Art"}`)
+	write("subjects.ds", "2.json", `{"title":"Art","uri":"/subjects/2"}`)
+	moves, err = api.MigrateLayout(LegacyLayout, DefaultLayout, []int{2}, false)
+	if err != nil {
+		t.Fatalf("MigrateLayout() %s", err)
+	}
+	if len(moves) != 1 || moves[0].From != "subjects" || moves[0].Records != 2 {
+		t.Errorf("unexpected moves %s", stringify(moves))
+	}
+	if keys("subjects.ds") != "1.json,2.json" || keys("subjects") != "" {
+		t.Errorf("expected the subjects to be moved, got %q and %q", keys("subjects.ds"), keys("subjects"))
+	}
+
+	// A newer record in the new collection isn't overwritten
+	write("subjects", "1.json", `{"uri":"/subjects/1","title":"Old science"}`)
+	if _, err := api.MigrateLayout(LegacyLayout, DefaultLayout, []int{2}, false); err == nil {
+		t.Errorf("expected MigrateLayout() to refuse to overwrite a different record")
+	}
+	c, _ := OpenCollection(api, "subjects.ds")
+	src, _ := ReadJSON(c, "1.json")
+	c.Close()
+	if strings.Contains(string(src), `"Science"`) == false || keys("subjects") != "1.json" {
+		t.Errorf("expected both records to be kept, got %s and %q", src, keys("subjects"))
+	}
+
+	// A legacy collection linked to the new one keeps its records
+	api = New("http://localhost:8089", "", "", t.TempDir())
+	write("repository-2/accessions.ds", "5.json", `{"uri":"/repositories/2/accessions/5"}`)
+	os.MkdirAll(path.Join(api.Dataset, "repositories", "2"), 0775)
+	if err := os.Symlink(path.Join(api.Dataset, "repository-2", "accessions.ds"), path.Join(api.Dataset, "repositories", "2", "accessions")); err != nil {
+		t.Fatalf("Can't make symlink, %s", err)
+	}
+	moves, err = api.MigrateLayout(LegacyLayout, DefaultLayout, []int{2}, false)
+	if err != nil {
+		t.Fatalf("MigrateLayout() %s", err)
+	}
+	if len(moves) != 1 || moves[0].To != "repository-2/accessions.ds" || keys("repository-2/accessions.ds") != "5.json" {
+		t.Errorf("expected the linked accessions to be kept, got %s, %q", stringify(moves), keys("repository-2/accessions.ds"))
+	}
+	if _, err := os.Lstat(path.Join(api.Dataset, "repositories", "2", "accessions")); os.IsNotExist(err) == false {
+		t.Errorf("expected the symlink to be removed, %v", err)
+	}
+
+	// The new name linked to the legacy collection is replaced by it
+	api = New("http://localhost:8089", "", "", t.TempDir())
+	write("subjects", "3.json", `{"uri":"/subjects/3"}`)
+	if err := os.Symlink(path.Join(api.Dataset, "subjects"), path.Join(api.Dataset, "subjects.ds")); err != nil {
+		t.Fatalf("Can't make symlink, %s", err)
+	}
+	if _, err := api.MigrateLayout(LegacyLayout, DefaultLayout, nil, false); err != nil {
+		t.Fatalf("MigrateLayout() %s", err)
+	}
+	if info, err := os.Lstat(path.Join(api.Dataset, "subjects.ds")); err != nil || info.IsDir() == false || keys("subjects.ds") != "3.json" {
+		t.Errorf("expected subjects.ds to be the collection, got %v, %q", err, keys("subjects.ds"))
+	}
+}
+
+func TestExportPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if r.URL.Path == "/subjects" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"not found"}`)
+			return
+		}
+		if page == "2" {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprintf(w, `{"error":"proxy error"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"first_page":1,"last_page":3,"this_page":%s,"total":5,"results":[`+
+			`{"uri":"/agents/people/%s1","title":"Good"},`+
+			`{"uri":"/agents/people/%s2","names":"not a list"}]}`, page, page, page)
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	api.ExportErrorLog = path.Join(t.TempDir(), "errors.jsonl")
+	ctx := context.Background()
+
+	err := api.ExportAgents(ctx, "people", false)
+	report := new(ExportReport)
+	if errors.As(err, &report) == false {
+		t.Fatalf("expected an *ExportReport, got %v", err)
+	}
+	if len(report.Failures) != 3 {
+		t.Fatalf("expected 3 failures, got %d", len(report.Failures))
+	}
+	statuses := map[string]int{}
+	for _, failure := range report.Failures {
+		statuses[failure.URI] = failure.Status
+	}
+	if _, ok := statuses["/agents/people/12"]; ok == false {
+		t.Errorf("expected /agents/people/12 to fail to decode, got %v", statuses)
+	}
+	if statuses["/agents/people?page=2&page_size=100"] != http.StatusBadGateway {
+		t.Errorf("expected page 2 to fail with %d, got %v", http.StatusBadGateway, statuses)
+	}
+	if report.Summary() != "3 records couldn't be exported, agents.ds/people 3" {
+		t.Errorf("unexpected summary %q", report.Summary())
+	}
+	if err := api.SaveExportReport(report); err != nil {
+		t.Fatalf("SaveExportReport() %s", err)
+	}
+	if err := api.SaveExportReport(report); err != nil {
+		t.Fatalf("SaveExportReport() %s", err)
+	}
+	src, err := ioutil.ReadFile(api.ExportErrorLog)
+	if err != nil {
+		t.Fatalf("Can't read %s, %s", api.ExportErrorLog, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(src)), "\n")
+	failure := new(ExportFailure)
+	if len(lines) != 3 || json.Unmarshal([]byte(lines[0]), failure) != nil || failure.Collection != "agents.ds/people" {
+		t.Errorf("expected the report once as 3 JSON lines, got %q", src)
+	}
+	if exportErrorKey(report.Failures[0]) != "agents_people_12.json" {
+		t.Errorf("unexpected errors.ds key %q", exportErrorKey(report.Failures[0]))
+	}
+
+	api.ExportPolicy = ExportFailFast
+	err = api.ExportAgents(ctx, "people", false)
+	if err == nil || errors.As(err, &report) == true || strings.Contains(err.Error(), "/agents/people/12") == false {
+		t.Errorf("expected fail-fast to stop at /agents/people/12, got %v", err)
+	}
+
+	// A failure listing the first page is recorded with ExportContinue
+	api.ExportPolicy = ExportContinue
+	err = api.ExportSubjects(ctx, false)
+	report = new(ExportReport)
+	if errors.As(err, &report) == false || len(report.Failures) != 1 || report.Failures[0].URI != "/subjects?page=1&page_size=100" || report.Failures[0].Status != http.StatusNotFound {
+		t.Fatalf("expected the first page failure in an *ExportReport, got %v", err)
+	}
+	api.ExportPolicy = ExportFailFast
+	err = api.ExportSubjects(ctx, false)
+	if err == nil || errors.As(err, &report) == true {
+		t.Errorf("expected fail-fast to return the first page error, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	uris := URIMap{
+		"/repositories/2":           "/repositories/5",
+		"/subjects/1":               "/subjects/10",
+		"/agents/people/3":          "/agents/people/30",
+		"/vocabularies/1/terms/4":   "/vocabularies/1/terms/40",
+		"/repositories/2/resources": "/repositories/5/resources",
+	}
+	rec := map[string]interface{}{}
+	src := `{"title":"Letters","repository":{"ref":"/repositories/2"},
+		"subjects":[{"ref":"/subjects/1","_resolved":{"title":"Science"}},{"ref":"/subjects/2"}],
+		"linked_agents":[{"role":"creator","ref":"/agents/people/3"}],
+		"related_resources":[{"ref":"/repositories/2/resources/9"}],
+		"terms":[{"uri":"/vocabularies/1/terms/4","term":"Science"},{"uri":"/vocabularies/1/terms/6","term":"Art"}]}`
+	if err := json.Unmarshal([]byte(src), &rec); err != nil {
+		t.Fatalf("%s", err)
+	}
+	unresolved := uris.Remap(rec)
+	if strings.Join(unresolved, " ") != "/repositories/2/resources/9 /subjects/2" {
+		t.Errorf("unexpected unresolved refs %v", unresolved)
+	}
+	out, _ := json.Marshal(rec)
+	expected := `{"linked_agents":[{"ref":"/agents/people/30","role":"creator"}],"related_resources":[],"repository":{"ref":"/repositories/5"},"subjects":[{"ref":"/subjects/10"}],"terms":[{"term":"Science","uri":"/vocabularies/1/terms/40"},{"term":"Art"}],"title":"Letters"}`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	var posted map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repositories/5/accessions":
+			json.NewDecoder(r.Body).Decode(&posted)
+			fmt.Fprintf(w, `{"status":"Created","id":7,"lock_version":0,"uri":"/repositories/5/accessions/7"}`)
+		case r.URL.Path == "/repositories":
+			fmt.Fprintf(w, `[{"uri":"/repositories/5","repo_code":"CALTECH"}]`)
+		case r.URL.Path == "/vocabularies":
+			fmt.Fprintf(w, `[{"uri":"/vocabularies/1","ref_id":"global"}]`)
+		case r.URL.Path == "/vocabularies/1":
+			fmt.Fprintf(w, `{"uri":"/vocabularies/1","ref_id":"global"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+	imp := &importer{api: api, uris: uris, report: &ExportReport{imported: true}}
+
+	src = `{"uri":"/repositories/2/accessions/3","lock_version":4,"system_mtime":"2016-01-01T00:00:00Z","title":"Letters","repository":{"ref":"/repositories/2"}}`
+	rec = map[string]interface{}{}
+	json.Unmarshal([]byte(src), &rec)
+	accessions := &importSource{recordType: "accession", dir: "repository-2/accessions.ds", path: "/repositories/5/accessions"}
+	mapping, err := imp.importRecord(ctx, accessions, "/repositories/2/accessions/3", rec)
+	if err != nil {
+		t.Fatalf("importRecord() %s", err)
+	}
+	if mapping.From != "/repositories/2/accessions/3" || mapping.To != "/repositories/5/accessions/7" || mapping.Matched == true {
+		t.Errorf("unexpected mapping %+v", mapping)
+	}
+	for _, field := range []string{"uri", "lock_version", "system_mtime"} {
+		if _, ok := posted[field]; ok == true {
+			t.Errorf("expected %s to be removed before posting, got %v", field, posted)
+		}
+	}
+	if ref, _ := posted["repository"].(map[string]interface{}); ref == nil || ref["ref"] != "/repositories/5" {
+		t.Errorf("expected the repository ref to be rewritten, got %v", posted)
+	}
+	if uriMappingKey(mapping.From) != "repositories_2_accessions_3.json" {
+		t.Errorf("unexpected key %q", uriMappingKey(mapping.From))
+	}
+
+	repos := &importSource{recordType: "repository", path: "/repositories", match: func(rec map[string]interface{}) string {
+		return "/repositories/5"
+	}}
+	mapping, err = imp.importRecord(ctx, repos, "/repositories/2", map[string]interface{}{"repo_code": "CALTECH"})
+	if err != nil || mapping.Matched == false || mapping.To != "/repositories/5" {
+		t.Errorf("expected the existing repository to be used, got %+v, %v", mapping, err)
+	}
+
+	_, err = imp.importRecord(ctx, &importSource{recordType: "subject", path: "/subjects"}, "/subjects/3", map[string]interface{}{"title": "Art"})
+	apiErr := new(APIError)
+	if errors.As(err, &apiErr) == false || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 APIError, got %v", err)
+	}
+
+	if err := api.ImportArchivesSpace(ctx, false); err != nil {
+		t.Errorf("ImportArchivesSpace() %s", err)
+	}
+	report := &ExportReport{imported: true}
+	report.add("subjects.ds", "/subjects/3", err)
+	if report.Summary() != "1 records couldn't be imported, subjects.ds 1" {
+		t.Errorf("unexpected summary %q", report.Summary())
+	}
+
+	// Plain string fields holding a mapped URI, e.g. a subject's vocabulary
+	rec = map[string]interface{}{}
+	json.Unmarshal([]byte(`{"title":"Science","vocabulary":"/vocabularies/1","terms":[{"term":"Science","vocabulary":"/vocabularies/1"}]}`), &rec)
+	if unresolved := (URIMap{"/vocabularies/1": "/vocabularies/3"}).Remap(rec); len(unresolved) != 0 {
+		t.Errorf("unexpected unresolved refs %v", unresolved)
+	}
+	if out, _ := json.Marshal(rec); string(out) != `{"terms":[{"term":"Science","vocabulary":"/vocabularies/3"}],"title":"Science","vocabulary":"/vocabularies/3"}` {
+		t.Errorf("expected the vocabulary to be rewritten, got %s", out)
+	}
+}
+
+func TestImportArchivesSpace(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		posted []string
+		bodies = map[string]map[string]interface{}{}
+	)
+	ids := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST":
+			rec := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&rec)
+			ids[r.URL.Path]++
+			uri := fmt.Sprintf("%s/%d", r.URL.Path, 100+ids[r.URL.Path])
+			posted = append(posted, uri)
+			bodies[uri] = rec
+			fmt.Fprintf(w, `{"status":"Created","id":1,"lock_version":0,"uri":%q}`, uri)
+		case r.URL.Path == "/repositories":
+			fmt.Fprintf(w, `[{"uri":"/repositories/5","repo_code":"CALTECH"}]`)
+		case r.URL.Path == "/vocabularies":
+			fmt.Fprintf(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+	write := func(name, key, src string) {
+		c, err := CreateCollection(api, name)
+		if err != nil {
+			t.Fatalf("CreateCollection(%q) %s", name, err)
+		}
+		defer c.Close()
+		if err := WriteJSON(c, key, json.RawMessage(src)); err != nil {
+			t.Fatalf("WriteJSON(%q) %s", key, err)
+		}
+	}
+	write("repository.ds", "2.json", `{"uri":"/repositories/2","repo_code":"CALTECH"}`)
+	write("repository-2/top_containers.ds", "4.json", `{"uri":"/repositories/2/top_containers/4","indicator":"1"}`)
+	write("repository-2/resources.ds", "9.json", `{"uri":"/repositories/2/resources/9","title":"Papers"}`)
+	write("repository-2/archival_objects.ds", "11.json", `{"uri":"/repositories/2/archival_objects/11","title":"Folder",`+
+		`"resource":{"ref":"/repositories/2/resources/9"},"parent":{"ref":"/repositories/2/archival_objects/12"}}`)
+	write("repository-2/archival_objects.ds", "12.json", `{"uri":"/repositories/2/archival_objects/12","title":"Series",`+
+		`"resource":{"ref":"/repositories/2/resources/9"}}`)
+	write("repository-2/accessions.ds", "3.json", `{"uri":"/repositories/2/accessions/3","title":"Letters",`+
+		`"instances":[{"instance_type":"mixed_materials","sub_container":{"top_container":{"ref":"/repositories/2/top_containers/4"}}}]}`)
+
+	if err := api.ImportArchivesSpace(ctx, false); err != nil {
+		t.Fatalf("ImportArchivesSpace() %s", err)
+	}
+	expected := "/repositories/5/top_containers/101 /repositories/5/resources/101 /repositories/5/archival_objects/101 /repositories/5/archival_objects/102 /repositories/5/accessions/101"
+	if strings.Join(posted, " ") != expected {
+		t.Fatalf("expected %s, got %s", expected, strings.Join(posted, " "))
+	}
+	if series := bodies["/repositories/5/archival_objects/101"]; series["title"] != "Series" || stringify(series["resource"]) != `{"ref":"/repositories/5/resources/101"}` {
+		t.Errorf("expected the series first with its resource rewritten, got %s", stringify(series))
+	}
+	if folder := bodies["/repositories/5/archival_objects/102"]; stringify(folder["parent"]) != `{"ref":"/repositories/5/archival_objects/101"}` {
+		t.Errorf("expected the folder's parent to be rewritten, got %s", stringify(folder))
+	}
+	if src := stringify(bodies["/repositories/5/accessions/101"]["instances"]); strings.Contains(src, `"ref":"/repositories/5/top_containers/101"`) == false {
+		t.Errorf("expected the top container ref to be rewritten, got %s", src)
+	}
+}
+
+func TestSync(t *testing.T) {
+	// page writes results as one page, or as an array for an id_set request
+	page := func(w http.ResponseWriter, r *http.Request, results ...string) {
+		if len(r.URL.Query()["id_set[]"]) > 0 {
+			fmt.Fprintf(w, `[%s]`, strings.Join(results, ","))
+			return
+		}
+		fmt.Fprintf(w, `{"first_page":1,"last_page":1,"this_page":1,"total":%d,"results":[%s]}`, len(results), strings.Join(results, ","))
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories":
+			fmt.Fprintf(w, `[{"uri":"/repositories/2","repo_code":"CIT"}]`)
+		case "/vocabularies":
+			fmt.Fprintf(w, `[{"uri":"/vocabularies/1","ref_id":"global"}]`)
+		case "/vocabularies/1":
+			fmt.Fprintf(w, `{"uri":"/vocabularies/1","ref_id":"global"}`)
+		case "/subjects":
+			// the subjects are compared a page at a time
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprintf(w, `{"first_page":1,"last_page":2,"this_page":2,"total":2,"results":[%s]}`,
+					`{"uri":"/subjects/2","authority_id":"sh2","title":"Art","vocabulary":{"ref":"/vocabularies/1"},"lock_version":0}`)
+				return
+			}
+			fmt.Fprintf(w, `{"first_page":1,"last_page":2,"this_page":1,"total":2,"results":[%s]}`,
+				`{"uri":"/subjects/1","authority_id":"sh1","title":"Science","vocabulary":{"ref":"/vocabularies/1"},"lock_version":3}`)
+		case "/repositories/2/accessions":
+			page(w, r, `{"uri":"/repositories/2/accessions/1","id_0":"2016","id_1":"001","title":"Letters","lock_version":9,`+
+				`"repository":{"ref":"/repositories/2"},"subjects":[{"ref":"/subjects/2"}]}`)
+		default:
+			page(w, r)
+		}
+	}))
+	defer source.Close()
+
+	var (
+		mu          sync.Mutex
+		updated     map[string]interface{}
+		created     []string
+		lockVersion = 4
+	)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/subjects":
+			created = append(created, r.URL.Path)
+			fmt.Fprintf(w, `{"status":"Created","id":8,"lock_version":0,"uri":"/subjects/8"}`)
+		case r.Method == "POST" && r.URL.Path == "/repositories/5/accessions/3":
+			json.NewDecoder(r.Body).Decode(&updated)
+			fmt.Fprintf(w, `{"status":"Updated","id":3,"lock_version":5,"uri":"/repositories/5/accessions/3"}`)
+		case r.URL.Path == "/repositories/5/accessions/3":
+			fmt.Fprintf(w, `{"uri":"/repositories/5/accessions/3","id_0":"2016","id_1":"001","title":"Old letters","lock_version":%d}`, lockVersion)
+		case r.URL.Path == "/repositories":
+			fmt.Fprintf(w, `[{"uri":"/repositories/5","repo_code":"CIT"}]`)
+		case r.URL.Path == "/vocabularies":
+			fmt.Fprintf(w, `[{"uri":"/vocabularies/1","ref_id":"global"}]`)
+		case r.URL.Path == "/vocabularies/1":
+			fmt.Fprintf(w, `{"uri":"/vocabularies/1","ref_id":"global"}`)
+		case r.URL.Path == "/subjects":
+			page(w, r, `{"uri":"/subjects/7","authority_id":"sh1","title":"Science","vocabulary":{"ref":"/vocabularies/1"},"lock_version":0,"system_mtime":"2016-01-01T00:00:00Z",`+
+				`"_deleted":false,"_deleted_time":"2016-01-02T00:00:00Z"}`)
+		case r.URL.Path == "/repositories/5/accessions":
+			page(w, r, `{"uri":"/repositories/5/accessions/3","id_0":"2016","id_1":"001","title":"Old letters","lock_version":4,"repository":{"ref":"/repositories/5"}}`)
+		default:
+			page(w, r)
+		}
+	}))
+	defer target.Close()
+
+	api := New(source.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(source.URL)
+	api.Retry = nil
+	dest := New(target.URL, "", "", t.TempDir())
+	dest.BaseURL, _ = url.Parse(target.URL)
+	dest.Retry = nil
+	ctx := context.Background()
+
+	summarize := func(actions []*SyncAction) string {
+		parts := []string{}
+		for _, action := range actions {
+			parts = append(parts, fmt.Sprintf("%s %s %s->%s", action.Action, action.Key, action.Source, action.Target))
+		}
+		return strings.Join(parts, ", ")
+	}
+	plan, err := api.Sync(ctx, dest, true)
+	if err != nil {
+		t.Fatalf("Sync(dry run) %s", err)
+	}
+	expected := "unchanged sh1 /subjects/1->/subjects/7, create sh2 /subjects/2->, update 2016-001 /repositories/2/accessions/1->/repositories/5/accessions/3"
+	if summarize(plan) != expected {
+		t.Errorf("expected plan %q, got %q", expected, summarize(plan))
+	}
+	if len(created) > 0 || updated != nil {
+		t.Errorf("expected a dry run to change nothing, got %v %v", created, updated)
+	}
+	if len(plan) == 3 && (len(plan[2].Changes) != 2 || plan[2].Changes[1].Field != "title" || plan[2].Changes[1].To != "Letters") {
+		t.Errorf("unexpected accession changes %s", stringify(plan[2].Changes))
+	}
+
+	actions, err := api.Sync(ctx, dest, false)
+	if err != nil {
+		t.Fatalf("Sync() %s", err)
+	}
+	expected = "unchanged sh1 /subjects/1->/subjects/7, create sh2 /subjects/2->/subjects/8, update 2016-001 /repositories/2/accessions/1->/repositories/5/accessions/3"
+	if summarize(actions) != expected {
+		t.Errorf("expected %q, got %q", expected, summarize(actions))
+	}
+	if updated == nil {
+		t.Fatalf("expected the accession to be updated")
+	}
+	if fmt.Sprintf("%v", updated["lock_version"]) != "4" {
+		t.Errorf("expected the target's lock_version 4, got %v", updated["lock_version"])
+	}
+	if src := stringify(updated["subjects"]); src != `[{"ref":"/subjects/8"}]` {
+		t.Errorf("expected the subject ref to be rewritten, got %s", src)
+	}
+	if actions[2].LockVersion != "5" || len(actions[2].Unresolved) != 0 {
+		t.Errorf("unexpected update action %+v", actions[2])
+	}
+
+	// The accession is edited on the target after it is listed
+	mu.Lock()
+	updated, lockVersion = nil, 6
+	mu.Unlock()
+	actions, err = api.Sync(ctx, dest, false)
+	if err != nil {
+		t.Fatalf("Sync() %s", err)
+	}
+	if len(actions) != 3 || strings.Contains(actions[2].Error, "since it was compared") == false {
+		t.Fatalf("expected the stale update to fail, got %s", stringify(actions))
+	}
+	if updated != nil {
+		t.Errorf("expected the changed accession not to be overwritten, got %v", updated)
+	}
+
+	diffs := DiffRecords(map[string]interface{}{"title": "A", "dates": map[string]interface{}{"begin": "1900", "lock_version": 1.0}},
+		map[string]interface{}{"title": "A", "dates": map[string]interface{}{"begin": "1901", "lock_version": 2.0}}, SyncIgnoreFields)
+	if len(diffs) != 1 || diffs[0].Field != "dates.begin" {
+		t.Errorf("expected only dates.begin to differ, got %s", stringify(diffs))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := []byte(`{"uri":"/subjects/1","title":"Science","lock_version":1,"system_mtime":"2016-10-01T00:00:00Z","terms":[{"term":"Science"}]}`)
+	to := []byte(`{"uri":"/subjects/1","title":"Sciences","lock_version":2,"system_mtime":"2016-10-02T00:00:00Z","terms":[{"term":"Science"}],"authority_id":"sh1"}`)
+	diff, err := diffRecord("subjects.ds", "1.json", from, to, DiffIgnoreFields)
+	if err != nil {
+		t.Fatalf("diffRecord() %s", err)
+	}
+	if diff == nil || diff.Change != DiffModified || diff.URI != "/subjects/1" || len(diff.Fields) != 2 {
+		t.Fatalf("expected title and authority_id to be modified, got %s", stringify(diff))
+	}
+	if diff.Fields[0].Field != "authority_id" || diff.Fields[1].Field != "title" || diff.Fields[1].From != "Science" {
+		t.Errorf("unexpected fields %s", stringify(diff.Fields))
+	}
+
+	touched := []byte(`{"uri":"/subjects/1","title":"Science","lock_version":3,"system_mtime":"2016-10-03T00:00:00Z","terms":[{"term":"Science"}]}`)
+	if diff, err := diffRecord("subjects.ds", "1.json", from, touched, DiffIgnoreFields); err != nil || diff != nil {
+		t.Errorf("expected only ignored fields to change, got %s, %v", stringify(diff), err)
+	}
+	if diff, _ := diffRecord("subjects.ds", "2.json", nil, []byte(`{"uri":"/subjects/2"}`), DiffIgnoreFields); diff == nil || diff.Change != DiffAdded {
+		t.Errorf("expected an added record, got %s", stringify(diff))
+	}
+	deleted := []byte(`{"uri":"/subjects/1","_deleted":true}`)
+	if diff, _ := diffRecord("subjects.ds", "1.json", from, deleted, DiffIgnoreFields); diff == nil || diff.Change != DiffRemoved || diff.URI != "/subjects/1" {
+		t.Errorf("expected a removed record, got %s", stringify(diff))
+	}
+	// A record marked in both snapshots is unchanged, marked then live is added
+	if diff, err := diffRecord("subjects.ds", "1.json", deleted, deleted, DiffIgnoreFields); err != nil || diff != nil {
+		t.Errorf("expected a record deleted in both to be unchanged, got %s, %v", stringify(diff), err)
+	}
+	if diff, _ := diffRecord("subjects.ds", "1.json", deleted, from, DiffIgnoreFields); diff == nil || diff.Change != DiffAdded || diff.URI != "/subjects/1" {
+		t.Errorf("expected a restored record to be added, got %s", stringify(diff))
+	}
+	if _, err := diffRecord("subjects.ds", "3.json", []byte(`{`), to, DiffIgnoreFields); err == nil {
+		t.Errorf("expected an error decoding invalid JSON")
+	}
+
+	diffs := []*RecordDiff{diff, {Collection: "subjects.ds", Key: "2.json", URI: "/subjects/2", Change: DiffRemoved}}
+	buf := new(bytes.Buffer)
+	if err := WriteDiffJSONL(buf, diffs); err != nil {
+		t.Fatalf("WriteDiffJSONL() %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || strings.Contains(lines[0], `"field":"title"`) == false {
+		t.Errorf("unexpected JSON lines %s", buf.String())
+	}
+	buf.Reset()
+	if err := WriteDiffHTML(buf, "Nightly <changes>", diffs); err != nil {
+		t.Fatalf("WriteDiffHTML() %s", err)
+	}
+	page := buf.String()
+	for _, s := range []string{"<title>Nightly &lt;changes&gt;</title>", "0 added, 1 removed, 1 modified", "<td>authority_id</td>", "<td>/subjects/2</td>"} {
+		if strings.Contains(page, s) == false {
+			t.Errorf("expected %q in the report, got %s", s, page)
+		}
+	}
+
+	api := New("http://localhost:8089", "", "", t.TempDir())
+	names := api.ExportedCollections(t.TempDir())
+	if len(names) != 4+len(AgentTypes) || names[1] != "subjects.ds" {
+		t.Errorf("unexpected collections %v", names)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	in := strings.Join([]string{
+		`{"uri":"/repositories/2/accessions/1","title":"Letters"}`,
+		``,
+		`{"uri":"/repositories/2/accessions/2","title":"Diaries"}`,
+		`{"uri":"/repositories/2/accessions/3"}`,
+		`not json`,
+	}, "\n")
+	var (
+		mu     sync.Mutex
+		active int
+		most   int
+	)
+	fn := func(ctx context.Context, payload string) (string, error) {
+		mu.Lock()
+		active++
+		if active > most {
+			most = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		switch {
+		case strings.Contains(payload, "Letters"):
+			return `{"status":"Updated","id":1,"lock_version":2,"uri":"/repositories/2/accessions/1"}`, nil
+		case strings.Contains(payload, "Diaries"):
+			return "", &APIError{Method: "POST", URL: "/repositories/2/accessions/2", StatusCode: http.StatusConflict, Status: "409 Conflict"}
+		case strings.Contains(payload, "accessions/3"):
+			return `{"uri":"/repositories/2/accessions/3","title":"Photographs"}`, nil
+		}
+		return "", fmt.Errorf("can't decode payload")
+	}
+	out := new(bytes.Buffer)
+	failed, err := RunBatch(context.Background(), strings.NewReader(in), out, 2, fn)
+	if err != nil {
+		t.Fatalf("RunBatch() %s", err)
+	}
+	if failed != 2 {
+		t.Errorf("expected 2 failed lines, got %d", failed)
+	}
+	if most > 2 {
+		t.Errorf("expected at most 2 lines at once, got %d", most)
+	}
+	results := map[int]*BatchResult{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		result := new(BatchResult)
+		if err := json.Unmarshal([]byte(line), result); err != nil {
+			t.Fatalf("Can't decode %q, %s", line, err)
+		}
+		results[result.Line] = result
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %s", out.String())
+	}
+	if r := results[1]; r.Status != "Updated" || r.Response == nil || r.Response.LockVersion != "2" || r.URI != "/repositories/2/accessions/1" {
+		t.Errorf("unexpected result for line 1 %s", stringify(r))
+	}
+	if r := results[3]; r.Status != BatchError || r.URI != "/repositories/2/accessions/2" || strings.Contains(r.Error, "409") == false || string(r.Input) != `{"uri":"/repositories/2/accessions/2","title":"Diaries"}` {
+		t.Errorf("unexpected result for line 3 %s", stringify(r))
+	}
+	if r := results[4]; r.Status != BatchOK || strings.Contains(string(r.Output), "Photographs") == false {
+		t.Errorf("unexpected result for line 4 %s", stringify(r))
+	}
+	if r := results[5]; r.Status != BatchError || string(r.Input) != `"not json"` {
+		t.Errorf("unexpected result for line 5 %s", stringify(r))
+	}
+}
+
+func TestListIDs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("all_ids") != "true" {
+			t.Errorf("expected all_ids=true, got %s", r.URL.RawQuery)
+		}
+		switch r.URL.Path {
+		case "/repositories":
+			fmt.Fprintf(w, `[{"uri":"/repositories/2","repo_code":"CIT"},{"uri":"/repositories/3"}]`)
+		default:
+			fmt.Fprintf(w, `[1,5,9]`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+	ids, err := api.listIDs(ctx, "/repositories")
+	if err != nil || fmt.Sprintf("%v", ids) != "[2 3]" {
+		t.Errorf("expected repository ids [2 3], got %v, %v", ids, err)
+	}
+	ids, err = api.listIDs(ctx, "/repositories/2/accessions")
+	if err != nil || fmt.Sprintf("%v", ids) != "[1 5 9]" {
+		t.Errorf("expected accession ids [1 5 9], got %v, %v", ids, err)
+	}
+
+	names := map[string]bool{}
+	for _, rt := range jsRecordTypes {
+		if names[rt.name] == true || names[rt.plural] == true {
+			t.Errorf("%s is bound twice", rt.name)
+		}
+		names[rt.name], names[rt.plural] = true, true
+	}
+}
+
+func TestRedactPassword(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":{"username":["Username 'jane' is already in use"]}}`)
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+	secret := "s3cret-Pa55"
+
+	user := &User{Username: "jane", Name: "Jane Doe"}
+	_, err := api.CreateUser(ctx, user, secret)
+	if err == nil {
+		t.Fatalf("expected CreateUser() to fail")
+	}
+	if strings.Contains(err.Error(), secret) == true {
+		t.Errorf("expected the password to be redacted, got %s", err)
+	}
+	apiErr := new(APIError)
+	if errors.As(err, &apiErr) == false || apiErr.StatusCode != http.StatusBadRequest || strings.Contains(apiErr.URL, "password=REDACTED") == false {
+		t.Errorf("expected a 400 APIError with a redacted URL, got %v", err)
+	}
+	if strings.Contains(apiErr.String(), secret) == true {
+		t.Errorf("expected the password to be redacted, got %s", apiErr.String())
+	}
+
+	user.URI = "/users/3"
+	_, err = api.UpdateUser(ctx, user, secret)
+	if err == nil || strings.Contains(err.Error(), secret) == true {
+		t.Errorf("expected UpdateUser() to fail without the password, got %v", err)
+	}
+
+	if u := redactURL("http://localhost:8089/users?password=x&token=y&page=1"); u != "http://localhost:8089/users?page=1&password=REDACTED&token=REDACTED" {
+		t.Errorf("unexpected redacted URL %s", u)
+	}
+	if u := redactURL("http://localhost:8089/users/3"); u != "http://localhost:8089/users/3" {
+		t.Errorf("expected an unchanged URL, got %s", u)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/2/accessions/10":
+			fmt.Fprintf(w, `{"uri":"/repositories/2/accessions/10","title":"Papers of Jane Doe","lock_version":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"Accession not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	api := New(ts.URL, "", "", t.TempDir())
+	api.BaseURL, _ = url.Parse(ts.URL)
+	api.Retry = nil
+	ctx := context.Background()
+
+	src := []byte(`var accession = api.getAccession(2, 10);
+if (accession.title !== "Papers of Jane Doe") {
+	throw new Error("unexpected title " + accession.title);
+}
+os.exit(accession.lock_version + os.args().length);
+`)
+	code, err := api.RunScript(ctx, "get.js", src, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("RunScript() %s", err)
+	}
+	if code != 3 {
+		t.Errorf("expected exit code 3, got %d", code)
+	}
+
+	code, err = api.RunScript(ctx, "noargs.js", []byte(`os.exit(os.args().length);`), nil)
+	if err != nil || code != 0 {
+		t.Errorf("expected os.args() to be empty, got %d, %v", code, err)
+	}
+
+	code, err = api.RunScript(ctx, "end.js", []byte(`var n = 1 + 1;`), nil)
+	if err != nil || code != 0 {
+		t.Errorf("expected exit code 0 without an error, got %d, %v", code, err)
+	}
+
+	code, err = api.RunScript(ctx, "throw.js", []byte(`throw new Error("no accessions to update");`), nil)
+	if err == nil || code != 1 || strings.Contains(err.Error(), "no accessions to update") == false {
+		t.Errorf("expected the thrown error with exit code 1, got %d, %v", code, err)
+	}
+
+	code, err = api.RunScript(ctx, "missing.js", []byte(`api.getAccession(2, 99);`), nil)
+	if err == nil || code != 1 || strings.Contains(err.Error(), "ArchivesSpaceError") == false {
+		t.Errorf("expected the API error to be thrown, got %d, %v", code, err)
+	}
+
+	code, err = api.RunScript(ctx, "caught.js", []byte(`try {
+	api.getAccession(2, 99);
+} catch (e) {
+	os.exit(4);
+}
+`), nil)
+	if err != nil || code != 4 {
+		t.Errorf("expected the API error to be caught by the script, got %d, %v", code, err)
+	}
+
+	code, err = api.RunScript(ctx, "exit.js", []byte(`try {
+	try {
+		os.exit(5);
+	} catch (e) {
+	}
+} catch (e) {
+}
+os.exit(6);
+`), nil)
+	if err != nil || code != 5 {
+		t.Errorf("expected os.exit() not to be caught by the script, got %d, %v", code, err)
+	}
+}
+
 // func TestResources(t *testing.T) {
 // 	// Get the environment variables needed for testing.
 // 	isSetup := checkConfig(t)
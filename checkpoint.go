@@ -0,0 +1,149 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ExportCheckpointKey is the key of the checkpoint manifest in
+	// ExportStateCollection
+	ExportCheckpointKey = "checkpoint.json"
+)
+
+// ExportCheckpoint is the manifest ExportArchivesSpace keeps in the dataset
+// so an interrupted export can be resumed. Completed lists the finished
+// stages, LastIDs holds the last record id written in order for each
+// collection being exported.
+type ExportCheckpoint struct {
+	Version   string         `json:"cait_version"`
+	Started   string         `json:"started"`
+	Updated   string         `json:"updated,omitempty"`
+	Finished  string         `json:"finished,omitempty"`
+	Completed []string       `json:"completed"`
+	LastIDs   map[string]int `json:"last_ids"`
+
+	// mu guards the fields above while collection pages are written
+	mu sync.Mutex
+}
+
+// NewExportCheckpoint returns the checkpoint for an export starting now
+func NewExportCheckpoint() *ExportCheckpoint {
+	return &ExportCheckpoint{
+		Version:   Version,
+		Started:   time.Now().UTC().Format(time.RFC3339),
+		Completed: []string{},
+		LastIDs:   map[string]int{},
+	}
+}
+
+// IsCompleted reports if stage finished in this export
+func (cp *ExportCheckpoint) IsCompleted(stage string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return containsString(cp.Completed, stage)
+}
+
+// Complete records stage as finished, its collection no longer needs a last id
+func (cp *ExportCheckpoint) Complete(stage string) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if containsString(cp.Completed, stage) == false {
+		cp.Completed = append(cp.Completed, stage)
+	}
+	delete(cp.LastIDs, stage)
+}
+
+// LastID returns the last id written in order to collection, zero if none
+func (cp *ExportCheckpoint) LastID(collection string) int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.LastIDs[collection]
+}
+
+// SetLastID records id as the last written in order to collection
+func (cp *ExportCheckpoint) SetLastID(collection string, id int) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.LastIDs == nil {
+		cp.LastIDs = map[string]int{}
+	}
+	cp.LastIDs[collection] = id
+}
+
+// resumePage returns the page holding the first id after lastID, ArchivesSpace
+// pages its lists in id order so the pages before it have been written.
+func resumePage(ids []int, lastID, pageSize int) int {
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	sorted := append([]int{}, ids...)
+	sort.Ints(sorted)
+	written := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i] > lastID
+	})
+	return (written / pageSize) + 1
+}
+
+// ReadExportCheckpoint returns the checkpoint saved by the last export,
+// nil if there isn't one.
+func (api *ArchivesSpaceAPI) ReadExportCheckpoint() (*ExportCheckpoint, error) {
+	c, err := CreateCollection(api, ExportStateCollection)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, ExportStateCollection, err)
+	}
+	defer c.Close()
+	if c.HasKey(ExportCheckpointKey) == false {
+		return nil, nil
+	}
+	src, err := ReadJSON(c, ExportCheckpointKey)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read export checkpoint, %w", err)
+	}
+	cp := new(ExportCheckpoint)
+	if err := json.Unmarshal(src, cp); err != nil {
+		return nil, fmt.Errorf("Can't decode export checkpoint, %w", err)
+	}
+	if cp.LastIDs == nil {
+		cp.LastIDs = map[string]int{}
+	}
+	return cp, nil
+}
+
+// WriteExportCheckpoint saves cp in the dataset
+func (api *ArchivesSpaceAPI) WriteExportCheckpoint(cp *ExportCheckpoint) error {
+	c, err := CreateCollection(api, ExportStateCollection)
+	if err != nil {
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, ExportStateCollection, err)
+	}
+	defer c.Close()
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Updated = time.Now().UTC().Format(time.RFC3339)
+	if err := WriteJSON(c, ExportCheckpointKey, cp); err != nil {
+		return fmt.Errorf("Can't write export checkpoint, %w", err)
+	}
+	return nil
+}
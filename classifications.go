@@ -0,0 +1,252 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CreateClassification creates a new classification (record group) in a Repository
+func (api *ArchivesSpaceAPI) CreateClassification(ctx context.Context, repoID int, obj *Classification) (*ResponseMsg, error) {
+	obj.JSONModelType = "classification"
+	obj.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/classifications", repoID), nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
+	obj.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetClassification retrieves a classification from a Repository
+func (api *ArchivesSpaceAPI) GetClassification(ctx context.Context, repoID, classificationID int) (*Classification, error) {
+	obj := new(Classification)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/classifications/%d", repoID, classificationID), nil)
+	if err := api.GetAPI(ctx, u, obj); err != nil {
+		return nil, fmt.Errorf("GetClassification() %s, error, %w", u, err)
+	}
+	obj.ID = URIToID(obj.URI)
+	return obj, nil
+}
+
+// UpdateClassification updates an existing classification
+func (api *ArchivesSpaceAPI) UpdateClassification(ctx context.Context, obj *Classification) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// DeleteClassification deletes a classification
+func (api *ArchivesSpaceAPI) DeleteClassification(ctx context.Context, obj *Classification) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// ListClassifications returns a list of classification ids in a Repository
+func (api *ArchivesSpaceAPI) ListClassifications(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/classifications`, repoID), q))
+}
+
+// CreateClassificationTerm creates a new classification term in a Repository,
+// the term must have a classification ref and may have a parent term ref.
+func (api *ArchivesSpaceAPI) CreateClassificationTerm(ctx context.Context, repoID int, obj *ClassificationTerm) (*ResponseMsg, error) {
+	obj.JSONModelType = "classification_term"
+	obj.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/classification_terms", repoID), nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
+	obj.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetClassificationTerm retrieves a classification term from a Repository
+func (api *ArchivesSpaceAPI) GetClassificationTerm(ctx context.Context, repoID, termID int) (*ClassificationTerm, error) {
+	obj := new(ClassificationTerm)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/classification_terms/%d", repoID, termID), nil)
+	if err := api.GetAPI(ctx, u, obj); err != nil {
+		return nil, fmt.Errorf("GetClassificationTerm() %s, error, %w", u, err)
+	}
+	obj.ID = URIToID(obj.URI)
+	return obj, nil
+}
+
+// UpdateClassificationTerm updates an existing classification term
+func (api *ArchivesSpaceAPI) UpdateClassificationTerm(ctx context.Context, obj *ClassificationTerm) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// DeleteClassificationTerm deletes a classification term
+func (api *ArchivesSpaceAPI) DeleteClassificationTerm(ctx context.Context, obj *ClassificationTerm) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// ListClassificationTerms returns a list of classification term ids in a Repository
+func (api *ArchivesSpaceAPI) ListClassificationTerms(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/classification_terms`, repoID), q))
+}
+
+// ClassificationMap holds the classifications and classification terms
+// of one or more repositories by URI so classification refs can be resolved
+// without calling the API
+type ClassificationMap struct {
+	Classifications map[string]*Classification
+	Terms           map[string]*ClassificationTerm
+}
+
+// NewClassificationMap returns an empty ClassificationMap
+func NewClassificationMap() *ClassificationMap {
+	return &ClassificationMap{
+		Classifications: make(map[string]*Classification),
+		Terms:           make(map[string]*ClassificationTerm),
+	}
+}
+
+// GetClassificationMap fetches the classifications and classification terms of a Repository
+func (api *ArchivesSpaceAPI) GetClassificationMap(ctx context.Context, repoID int) (*ClassificationMap, error) {
+	m := NewClassificationMap()
+	err := api.ClassificationList(repoID).Each(ctx, DefaultPageSize, func(obj *Classification) error {
+		m.Classifications[obj.URI] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can't get classifications, %w", err)
+	}
+	err = api.ClassificationTermList(repoID).Each(ctx, DefaultPageSize, func(obj *ClassificationTerm) error {
+		m.Terms[obj.URI] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can't get classification terms, %w", err)
+	}
+	return m, nil
+}
+
+// MakeClassificationMap reads a repository's exported classifications and
+// classification terms and builds a ClassificationMap. The collections are
+// named by the api's Layout.
+func (api *ArchivesSpaceAPI) MakeClassificationMap(repoID int) (*ClassificationMap, error) {
+	m := NewClassificationMap()
+	for _, dname := range []string{api.Collection("classification", repoID), api.Collection("classification_term", repoID)} {
+		c, err := OpenCollection(api, dname)
+		if err != nil {
+			return nil, fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, dname, err)
+		}
+		for _, key := range GetKeys(c) {
+			src, err := ReadJSON(c, key)
+			if err != nil {
+				c.Close()
+				return nil, fmt.Errorf("Can't read classification %s, %w", key, err)
+			}
+			if err := m.add(src); err != nil {
+				c.Close()
+				return nil, fmt.Errorf("Can't parse classification %s, %w", key, err)
+			}
+		}
+		c.Close()
+	}
+	return m, nil
+}
+
+// add decodes a classification or classification term and adds it to the map
+func (m *ClassificationMap) add(src []byte) error {
+	term := new(ClassificationTerm)
+	if err := json.Unmarshal(src, &term); err != nil {
+		return err
+	}
+	if term.JSONModelType == "classification_term" {
+		m.Terms[term.URI] = term
+		return nil
+	}
+	obj := new(Classification)
+	if err := json.Unmarshal(src, &obj); err != nil {
+		return err
+	}
+	m.Classifications[obj.URI] = obj
+	return nil
+}
+
+// NormalizedClassificationView returns a structure suitable for templating
+// a classification ref as a record group hierarchy in public web content.
+type NormalizedClassificationView struct {
+	URI        string   `json:"uri"`
+	Identifier string   `json:"identifier"`
+	Title      string   `json:"title"`
+	Hierarchy  []string `json:"hierarchy"`
+}
+
+// Resolve returns the normalized view of a classification or classification
+// term URI, Hierarchy lists the "identifier title" of each level starting
+// with the classification. Returns nil if the URI isn't in the map.
+func (m *ClassificationMap) Resolve(uri string) *NormalizedClassificationView {
+	var (
+		identifiers []string
+		hierarchy   []string
+	)
+	v := new(NormalizedClassificationView)
+	v.URI = uri
+	if term, ok := m.Terms[uri]; ok == true {
+		v.Title = term.Title
+	} else if obj, ok := m.Classifications[uri]; ok == true {
+		v.Title = obj.Title
+	}
+	seen := map[string]bool{}
+	for uri != "" && seen[uri] == false {
+		seen[uri] = true
+		if term, ok := m.Terms[uri]; ok == true {
+			identifiers = append([]string{term.Identifier}, identifiers...)
+			hierarchy = append([]string{strings.TrimSpace(term.Identifier + " " + term.Title)}, hierarchy...)
+			if uri = refString(term.Parent); uri == "" {
+				uri = refString(term.Classification)
+			}
+			continue
+		}
+		if obj, ok := m.Classifications[uri]; ok == true {
+			identifiers = append([]string{obj.Identifier}, identifiers...)
+			hierarchy = append([]string{strings.TrimSpace(obj.Identifier + " " + obj.Title)}, hierarchy...)
+		}
+		break
+	}
+	if len(hierarchy) == 0 {
+		return nil
+	}
+	v.Identifier = strings.Join(identifiers, "/")
+	v.Hierarchy = hierarchy
+	return v
+}
+
+// ResolveRefs adds a "_resolved" view to each classification ref
+// (e.g. accession.Classifications) found in the map
+func (m *ClassificationMap) ResolveRefs(refs []map[string]interface{}) {
+	for _, item := range refs {
+		if v := m.Resolve(refString(item)); v != nil {
+			item["_resolved"] = v
+		}
+	}
+}
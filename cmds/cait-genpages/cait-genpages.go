@@ -26,6 +26,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"strconv"
 	"text/template"
 
 	// Caltech Library packages
@@ -188,7 +189,7 @@ func processAgentsPeople(api *cait.ArchivesSpaceAPI, templateDir string, aHTMLTm
 	return cnt, nil
 }
 
-func processAccessions(api *cait.ArchivesSpaceAPI, templateDir string, aHTMLTmplName string, aIncTmplName string, accessionsDir string, agents []*cait.Agent, subjects map[string]*cait.Subject, digitalObjects map[string]*cait.DigitalObject) (int, error) {
+func processAccessions(api *cait.ArchivesSpaceAPI, templateDir string, aHTMLTmplName string, aIncTmplName string, accessionsDir string, agents []*cait.Agent, subjects map[string]*cait.Subject, digitalObjects map[string]*cait.DigitalObject, classifications *cait.ClassificationMap) (int, error) {
 	log.Printf("Reading templates from %s\n", templateDir)
 	aHTMLTmpl, aIncTmpl, err := loadTemplates(templateDir, aHTMLTmplName, aIncTmplName)
 	if err != nil {
@@ -218,7 +219,7 @@ func processAccessions(api *cait.ArchivesSpaceAPI, templateDir string, aHTMLTmpl
 		//        accession.RestrictionsApply, accession.UseRestrictions
 		if accession.Publish == true && accession.Suppressed == false && accession.RestrictionsApply == false {
 			// Create a normalized view of the accession to make it easier to work with
-			view, err := accession.NormalizeView(agents, subjects, digitalObjects)
+			view, err := accession.NormalizeView(agents, subjects, digitalObjects, classifications)
 			if err != nil {
 				return cnt, fmt.Errorf("Could not generate normalized view, %s", err)
 			}
@@ -353,12 +354,19 @@ func main() {
 	api := cait.New("", "", "", datasetDir)
 
 	//
-	// Setup directories relationships
+	// Setup directories relationships from the dataset layout
 	//
-	accessionsDir := path.Join("repositories", repoNo, "accessions")
-	digitalObjectDir := path.Join("repositories", repoNo, "digital_objects")
-	subjectDir := path.Join("subjects")
-	agentsPeopleDir := path.Join("agents", "people")
+	repoID, err := strconv.Atoi(repoNo)
+	if err != nil {
+		log.Fatalf("repo-no must be a repository number, %s", err)
+	}
+	accessionsDir := api.Collection("accession", repoID)
+	digitalObjectDir := api.Collection("digital_object", repoID)
+	digitalObjectComponentDir := api.Collection("digital_object_component", repoID)
+	classificationDir := api.Collection("classification", repoID)
+	classificationTermDir := api.Collection("classification_term", repoID)
+	subjectDir := api.Collection("subject")
+	agentsPeopleDir := api.Collection("agent", "people")
 
 	log.Printf("%s %s\n", appName, cait.Version)
 
@@ -366,35 +374,52 @@ func main() {
 	// Setup Maps and generate the accessions pages
 	//
 	log.Printf("Reading Subjects from %s\n", subjectDir)
-	subjectsMap, err := api.MakeSubjectMap(subjectDir)
+	subjectsMap, err := api.MakeSubjectMap()
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 	log.Printf("Mapped %d subjects\n", len(subjectsMap))
 
 	log.Printf("Reading Digital Objects from %s\n", digitalObjectDir)
-	digitalObjectsMap, err := api.MakeDigitalObjectMap(digitalObjectDir)
+	digitalObjectsMap, err := api.MakeDigitalObjectMap(repoID)
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 	log.Printf("Mapped %d Digital Objects\n", len(digitalObjectsMap))
+	cnt, err := api.AttachDigitalObjectComponents(digitalObjectsMap, repoID)
+	if err != nil {
+		// NOTE: older exports don't include digital object components
+		log.Printf("Can't attach Digital Object Components, %s", err)
+	} else {
+		log.Printf("Attached %d Digital Object Components from %s\n", cnt, digitalObjectComponentDir)
+	}
+
+	log.Printf("Reading Classifications from %s and %s\n", classificationDir, classificationTermDir)
+	classificationsMap, err := api.MakeClassificationMap(repoID)
+	if err != nil {
+		// NOTE: older exports don't include classifications, fall back to refs resolved on export
+		log.Printf("Can't map classifications, %s", err)
+		classificationsMap = nil
+	} else {
+		log.Printf("Mapped %d Classifications and %d Classification Terms\n", len(classificationsMap.Classifications), len(classificationsMap.Terms))
+	}
 
 	log.Printf("Reading Agents/People from %s\n", agentsPeopleDir)
-	agentsList, err := api.MakeAgentList(agentsPeopleDir)
+	agentsList, err := api.MakeAgentList("people")
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 	log.Printf("Mapped %d Agents/People\n", len(agentsList))
 
 	log.Printf("Processing Agents/People in %s\n", agentsPeopleDir)
-	cnt, err := processAgentsPeople(api, templateDir, "agents-people.html", "agents-people.include", agentsPeopleDir)
+	cnt, err = processAgentsPeople(api, templateDir, "agents-people.html", "agents-people.include", agentsPeopleDir)
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 	log.Printf("Processed %d Agents/Peoples\n", cnt)
 
 	log.Printf("Processing accessions in %s\n", datasetDir)
-	cnt, err = processAccessions(api, templateDir, "accession.html", "accession.include", accessionsDir, agentsList, subjectsMap, digitalObjectsMap)
+	cnt, err = processAccessions(api, templateDir, "accession.html", "accession.include", accessionsDir, agentsList, subjectsMap, digitalObjectsMap, classificationsMap)
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
@@ -20,14 +20,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
+	"os/exec"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	// Caltech Library Packages
 	"github.com/caltechlibrary/cait"
@@ -59,7 +67,20 @@ var (
 		"term",
 		"location",
 		"digital_object",
-		// "resource",
+		"digital_object_component",
+		"resource",
+		"archival_object",
+		"top_container",
+		"container_profile",
+		"event",
+		"classification",
+		"classification_term",
+		"user",
+		"group",
+		"job",
+		"enumeration",
+		"search",
+		"dataset",
 	}
 	actions = []string{
 		"create",
@@ -67,6 +88,18 @@ var (
 		"update",
 		"delete",
 		"export",
+		"tree",
+		"provision",
+		"submit",
+		"status",
+		"log",
+		"output",
+		"merge",
+		"batch",
+		"migrate",
+		"import",
+		"sync",
+		"diff",
 	}
 )
 
@@ -87,6 +120,9 @@ The command is tructure around an SUBJECT, ACTION and an optional PAYLOAD
 + OPTIONS addition flags based parameters appropriate apply to the SUBJECT,
     ACTION or PAYLOAD
 
+JavaScript curation scripts are run with "run SCRIPT [ARGS]", the script
+has api, os and dataset objects (see the examples directory).
+
 CONFIGURATION
 
 %s also relies on the shell environment for information about connecting
@@ -120,6 +156,84 @@ Or for a specific repository by ID with
 
     %s repository list '{"uri": "/repositories/2"}'
 
+The tree action returns a resource's finding aid hierarchy
+
+    %s resource tree '{"uri": "/repositories/2/resources/1"}'
+
+Student and staff accounts can be created or updated from a CSV roster
+with the columns username, name, email, password, repo_id and groups
+(group codes separated by semicolons)
+
+    %s -input roster.csv user provision
+
+Background jobs can be submitted and followed until they finish, here
+a report is run and its output saved in the reports directory
+
+    %s -wait -timeout 1h job submit '{"uri":"/repositories/2/jobs","job_type":"report_job","job":{"report_type":"repository_report","format":"csv"}}'
+    %s -wait -output reports job output '{"uri":"/repositories/2/jobs/5"}'
+
+Duplicate agents are merged into a target agent, use -dry-run first to
+see which records will be re-linked
+
+    %s -dry-run agent merge '{"target":{"ref":"/agents/people/1"},"victims":[{"ref":"/agents/people/2"}]}'
+
+Locations for a stack range are created in one batch, use -dry-run to
+list the titles of the locations that would be created
+
+    %s -dry-run -ranges "Range:1-10;Shelf:A-F" location batch '{"building":"Archives","floor":"2","room":"201"}'
+
+Records can be found with ArchivesSpace's search without exporting a
+repository first, "aq" takes an advanced query
+
+    %s search list '{"uri":"/repositories/2","q":"oral history","type":["accession"],"facet":["subjects"]}'
+
+A nightly export only needs to fetch what changed since the last run,
+records deleted in ArchivesSpace are removed from the dataset. -workers
+sets how many pages are fetched at once and -progress shows the rate and
+ETA for each collection
+
+    %s -incremental -workers 8 -progress archivesspace export
+
+Records that can't be exported are saved in errors.ds and the export
+carries on, -error-log also appends them to a JSONL file and -fail-fast
+stops at the first one
+
+    %s -error-log export-errors.jsonl archivesspace export
+
+If an export is interrupted -resume picks up where it stopped
+
+    %s -resume archivesspace export
+
+Datasets exported with the old layout (repositories/2/accessions,
+subjects, agents/people) are moved to the current layout with
+
+    %s -dry-run dataset migrate '{"repositories":[2]}'
+
+What changed between two exports is listed as JSON lines and an HTML report with
+
+    %s dataset diff '{"from":"snapshots/2016-10-01","to":"dataset","jsonl":"changes.jsonl","html":"changes.html"}'
+
+An exported dataset is recreated in another ArchivesSpace with import,
+the URIs it assigns are saved in import_uris.ds and running it again
+continues an interrupted import
+
+    CAIT_API_URL=http://localhost:8089 %s archivesspace import
+
+Subjects, agents and accessions are synced from one ArchivesSpace to
+another by authority id and accession identifier, -dry-run shows the plan.
+The target's password is read from CAIT_SYNC_PASSWORD or prompted for
+
+    %s -dry-run archivesspace sync '{"api_url":"https://staging.example.edu/api","username":"admin"}'
+
+Any SUBJECT ACTION can be run for each line of a JSON Lines file (or "-"
+for stdin) in one session, a result line is printed for each
+
+    %s -batch accessions.jsonl -workers 4 accession update
+
+Curation scripts written in JavaScript are run with
+
+    %s run examples/fix-agents-people.js 101 202
+
 Other SUBJECTS and ACTIONS work in a similar fashion.
 
 `
@@ -133,8 +247,59 @@ Other SUBJECTS and ACTIONS work in a similar fashion.
 	caitHtdocsIndex = `htdocs.bleve`
 	caitTemplates   = `templates`
 	showVerbose     bool
+	timeout         time.Duration
+	maxRetries      = 5
+	waitForJob      bool
+	jobPoll         = cait.DefaultJobPollInterval
+	jobOutput       = "."
+	validateEnums   bool
+	dryRun          bool
+	locationRanges  string
+	incremental     bool
+	markDeleted     bool
+	exportWorkers   = 4
+	showProgress    bool
+	resumeExport    bool
+	failFast        bool
+	exportErrorLog  string
+	batchInput      string
+
+	// batchSession is set while a batch runs so every line shares its login
+	batchSession bool
 )
 
+// login starts a session, during a batch the session already started is used
+func login(ctx context.Context, api *cait.ArchivesSpaceAPI) error {
+	if batchSession == true && api.IsAuth() == true {
+		return nil
+	}
+	return api.Login(ctx)
+}
+
+// runBatch runs cmd once for each JSON line read from fname ("-" for stdin)
+// in one session, writing a result line for each to stdout. It returns the
+// number of lines which failed.
+func runBatch(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command, fname string) (int, error) {
+	in := os.Stdin
+	if fname != "-" {
+		fp, err := os.Open(fname)
+		if err != nil {
+			return 0, fmt.Errorf("Can't open %s, %s", fname, err)
+		}
+		defer fp.Close()
+		in = fp
+	}
+	if err := api.Login(ctx); err != nil {
+		return 0, err
+	}
+	batchSession = true
+	return cait.RunBatch(ctx, in, os.Stdout, exportWorkers, func(ctx context.Context, payload string) (string, error) {
+		lineCmd := *cmd
+		lineCmd.Payload = payload
+		return runCmd(ctx, api, &lineCmd)
+	})
+}
+
 func containsElement(src []string, elem string) bool {
 	for _, item := range src {
 		if strings.Compare(item, elem) == 0 {
@@ -144,17 +309,26 @@ func containsElement(src []string, elem string) bool {
 	return false
 }
 
-func exportArchivesSpace(api *cait.ArchivesSpaceAPI) error {
+// renderProgress rewrites the progress line on stderr, a collection's
+// line is finished once all its records are done.
+func renderProgress(progress cait.ExportProgress) {
+	fmt.Fprintf(os.Stderr, "\r%s\033[K", progress)
+	if progress.Done >= progress.Total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func exportArchivesSpace(ctx context.Context, api *cait.ArchivesSpaceAPI) error {
 	log.Println("Logging into ", api.BaseURL)
 	log.Printf("Exporting to %s\n", api.Dataset)
-	err := api.Login()
+	err := api.Login(ctx)
 	if err != nil {
 		return fmt.Errorf("%s, error %s", api.BaseURL, err)
 	}
 	//log.Printf("export TOKEN=%s\n", api.AuthToken)
-	err = api.ExportArchivesSpace(showVerbose)
+	err = api.ExportArchivesSpace(ctx, showVerbose)
 	if err != nil {
-		return fmt.Errorf("Failed to export ArchivesSpace, %s", err)
+		return fmt.Errorf("Failed to export ArchivesSpace, %w", err)
 	}
 	return nil
 }
@@ -166,6 +340,14 @@ func parseCmd(args []string) (*command, error) {
 		return nil, fmt.Errorf("Commands have the form SUBJECT ACTION [OPTIONS] [PAYLOAD]")
 	}
 
+	// run takes a script and its arguments rather than an ACTION
+	if args[0] == "run" {
+		cmd.Subject = args[0]
+		cmd.Action = args[1]
+		cmd.Options = args[2:]
+		return cmd, nil
+	}
+
 	if containsElement(subjects, args[0]) == false {
 		return nil, fmt.Errorf("%s is not a subject (e.g. %s)", args[0], strings.Join(subjects, ", "))
 	}
@@ -181,19 +363,214 @@ func parseCmd(args []string) (*command, error) {
 	return cmd, nil
 }
 
-func runArchivesSpaceCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runArchivesSpaceCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	switch cmd.Action {
 	case "export":
-		return "", exportArchivesSpace(api)
+		return "", exportArchivesSpace(ctx, api)
+	case "import":
+		log.Printf("Importing %s into %s\n", api.Dataset, api.BaseURL)
+		if err := api.ImportArchivesSpace(ctx, showVerbose); err != nil {
+			return "", fmt.Errorf("Failed to import into ArchivesSpace, %w", err)
+		}
+		return "", nil
+	case "sync":
+		return runArchivesSpaceSync(ctx, api, cmd)
+	}
+	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+// promptPassword reads a password from the terminal without echoing it,
+// stdin must be a terminal
+func promptPassword(prompt string) (string, error) {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return "", fmt.Errorf("Can't prompt for a password, stdin isn't a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	stty := func(arg string) {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		cmd.Run()
+	}
+	stty("-echo")
+	defer func() {
+		stty("echo")
+		fmt.Fprintln(os.Stderr)
+	}()
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("Can't read the password, %s", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// runArchivesSpaceSync makes the ArchivesSpace in the payload (or the
+// CAIT_SYNC_API_URL and CAIT_SYNC_USERNAME environment) match api. The
+// password is read from CAIT_SYNC_PASSWORD or prompted for, it isn't
+// accepted in the payload so it stays out of the shell history and process
+// list. The actions are returned as JSON lines, unchanged records are only
+// listed with -verbose. With -dry-run they are the plan and nothing changes.
+func runArchivesSpaceSync(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	req := struct {
+		APIURL   string `json:"api_url"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{
+		APIURL:   os.Getenv("CAIT_SYNC_API_URL"),
+		Username: os.Getenv("CAIT_SYNC_USERNAME"),
+	}
+	if cmd.Payload != "" {
+		if err := json.Unmarshal([]byte(cmd.Payload), &req); err != nil {
+			return "", fmt.Errorf(`Could not decode %s, error: %s, e.g. {"api_url":"http://localhost:8089","username":"admin"}`, cmd.Payload, err)
+		}
+	}
+	if req.Password != "" {
+		return "", fmt.Errorf("The password can't be set in the payload, set CAIT_SYNC_PASSWORD or enter it when prompted")
+	}
+	if req.APIURL == "" {
+		return "", fmt.Errorf("The ArchivesSpace to sync to is missing, set CAIT_SYNC_API_URL or api_url in the payload")
+	}
+	u, err := url.Parse(req.APIURL)
+	if err != nil {
+		return "", fmt.Errorf("Can't parse %q, %s", req.APIURL, err)
+	}
+	req.Password = os.Getenv("CAIT_SYNC_PASSWORD")
+	if req.Password == "" {
+		req.Password, err = promptPassword(fmt.Sprintf("Password for %s at %s: ", req.Username, req.APIURL))
+		if err != nil {
+			return "", fmt.Errorf("%s, set CAIT_SYNC_PASSWORD", err)
+		}
+	}
+	target := cait.New(req.APIURL, req.Username, req.Password, api.Dataset)
+	target.BaseURL, target.Username, target.Password, target.AuthToken = u, req.Username, req.Password, ""
+	target.Retry.MaxRetries = maxRetries
+	target.Verbose = showVerbose
+	if err := target.Login(ctx); err != nil {
+		return "", fmt.Errorf("Can't login to %s, %s", req.APIURL, err)
+	}
+	actions, err := api.Sync(ctx, target, dryRun)
+	lines := []string{}
+	for _, action := range actions {
+		if action.Action == cait.SyncUnchanged && showVerbose == false {
+			continue
+		}
+		src, _ := json.Marshal(action)
+		lines = append(lines, string(src))
+	}
+	if err != nil {
+		return strings.Join(lines, "\n"), fmt.Errorf("Failed to sync with %s, %w", req.APIURL, err)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// runDatasetCmd works on the exported dataset without contacting ArchivesSpace,
+// migrate moves collections from the legacy layout to cait.DefaultLayout.
+// The payload may list the repositories to migrate, e.g. {"repositories":[2]},
+// otherwise those in the exported repository collection are used.
+// diff compares the dataset "from" (e.g. an earlier export) with "to", which
+// defaults to CAIT_DATASET, writing JSON lines to "jsonl" (or returning them)
+// and an HTML report to "html" when set.
+func runDatasetCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	req := struct {
+		Repositories []int    `json:"repositories"`
+		From         string   `json:"from"`
+		To           string   `json:"to"`
+		Collections  []string `json:"collections"`
+		JSONL        string   `json:"jsonl"`
+		HTML         string   `json:"html"`
+	}{}
+	if cmd.Payload != "" {
+		if err := json.Unmarshal([]byte(cmd.Payload), &req); err != nil {
+			return "", fmt.Errorf("Problem unmashalling JSON dataset request, %s", err)
+		}
+	}
+	switch cmd.Action {
+	case "migrate":
+		if len(req.Repositories) == 0 {
+			c, err := cait.OpenCollection(api, api.Collection("repository"))
+			if err != nil {
+				return "", fmt.Errorf("Can't find the exported repositories, list them in the payload, %s", err)
+			}
+			for _, key := range cait.GetKeys(c) {
+				if id, err := strconv.Atoi(strings.TrimSuffix(key, ".json")); err == nil {
+					req.Repositories = append(req.Repositories, id)
+				}
+			}
+			c.Close()
+		}
+		moves, err := api.MigrateLayout(cait.LegacyLayout, cait.DefaultLayout, req.Repositories, dryRun)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.MarshalIndent(moves, "", "  ")
+		return string(src), err
+	case "diff":
+		return runDatasetDiff(api, req.From, req.To, req.Collections, req.JSONL, req.HTML)
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runRepoCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+// runScriptCmd runs the JavaScript file cmd.Action with the arguments in
+// cmd.Options, a script ending with os.exit(code) exits cait with code.
+func runScriptCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	src, err := ioutil.ReadFile(cmd.Action)
+	if err != nil {
+		return "", fmt.Errorf("Can't read %s, %s", cmd.Action, err)
+	}
+	code, err := api.RunScript(ctx, cmd.Action, src, cmd.Options)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+	return "", nil
+}
+
+// runDatasetDiff compares two exported datasets, see runDatasetCmd
+func runDatasetDiff(api *cait.ArchivesSpaceAPI, from, to string, collections []string, jsonl, html string) (string, error) {
+	if from == "" {
+		return "", fmt.Errorf(`The dataset to compare is missing, e.g. {"from":"snapshots/2016-10-01","to":"dataset"}`)
+	}
+	if to == "" {
+		to = api.Dataset
+	}
+	diffs, err := api.DiffDatasets(from, to, collections)
+	if err != nil {
+		return "", fmt.Errorf("Can't compare %s and %s, %w", from, to, err)
+	}
+	if html != "" {
+		fp, err := os.Create(html)
+		if err != nil {
+			return "", fmt.Errorf("Can't create %s, %s", html, err)
+		}
+		defer fp.Close()
+		if err := cait.WriteDiffHTML(fp, fmt.Sprintf("Changes from %s to %s", from, to), diffs); err != nil {
+			return "", err
+		}
+	}
+	if jsonl != "" {
+		fp, err := os.Create(jsonl)
+		if err != nil {
+			return "", fmt.Errorf("Can't create %s, %s", jsonl, err)
+		}
+		defer fp.Close()
+		if err := cait.WriteDiffJSONL(fp, diffs); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d records changed", len(diffs)), nil
+	}
+	buf := new(bytes.Buffer)
+	if err := cait.WriteDiffJSONL(buf, diffs); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func runRepoCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	repoID := 0
@@ -207,7 +584,7 @@ func runRepoCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	}
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateRepository(repo)
+		response, err := api.CreateRepository(ctx, repo)
 		if err != nil {
 			return "", err
 		}
@@ -221,7 +598,7 @@ func runRepoCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), nil
 	case "list":
 		if repoID == 0 {
-			repos, err := api.ListRepositories()
+			repos, err := api.ListRepositories(ctx)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q}`, err)
 			}
@@ -231,7 +608,7 @@ func runRepoCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		repo, err := api.GetRepository(repoID)
+		repo, err := api.GetRepository(ctx, repoID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -241,40 +618,66 @@ func runRepoCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateRepository(repo)
+		responseMsg, err := api.UpdateRepository(ctx, repo)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		repo, err := api.GetRepository(repoID)
+		repo, err := api.GetRepository(ctx, repoID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteRepository(repo)
+		responseMsg, err := api.DeleteRepository(ctx, repo)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportRepository(
+		err := api.ExportRepository(ctx,
 			repoID,
 			fmt.Sprintf("%d.json", repoID),
 		)
 		if err != nil {
-			return "", fmt.Errorf("Exporting repositories, %s", err)
+			return "", fmt.Errorf("Exporting repositories, %w", err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runAgentCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+// runAgentMerge merges the victim agents of a merge request payload into its target,
+// with -dry-run the records that would be re-linked are listed instead.
+func runAgentMerge(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	req := new(cait.MergeRequest)
+	if err := json.Unmarshal([]byte(cmd.Payload), &req); err != nil {
+		return "", fmt.Errorf(`Could not decode %s, error: %s, e.g. {"target":{"ref":"/agents/people/1"},"victims":[{"ref":"/agents/people/2"}]}`, cmd.Payload, err)
+	}
+	if dryRun == true {
+		preview, err := api.PreviewAgentMerge(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.MarshalIndent(preview, "", "    ")
+		return string(src), err
+	}
+	responseMsg, err := api.MergeAgents(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	src, err := json.Marshal(responseMsg)
+	return string(src), err
+}
+
+func runAgentCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
+	if cmd.Action == "merge" {
+		return runAgentMerge(ctx, api, cmd)
+	}
 	//Agent Type Payload as JSON encoded objects
 	agent := new(cait.Agent)
 	if cmd.Payload != "" {
@@ -293,7 +696,7 @@ func runAgentCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	aType := p[2]
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateAgent(aType, agent)
+		response, err := api.CreateAgent(ctx, aType, agent)
 		if err != nil {
 			return "", err
 		}
@@ -307,7 +710,7 @@ func runAgentCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), nil
 	case "list":
 		if agentID == 0 {
-			agents, err := api.ListAgents(aType)
+			agents, err := api.ListAgents(ctx, aType)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q}`, err)
 			}
@@ -317,7 +720,7 @@ func runAgentCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		agent, err := api.GetAgent(aType, agentID)
+		agent, err := api.GetAgent(ctx, aType, agentID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -327,35 +730,35 @@ func runAgentCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateAgent(agent)
+		responseMsg, err := api.UpdateAgent(ctx, agent)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		agent, err := api.GetAgent(aType, agentID)
+		agent, err := api.GetAgent(ctx, aType, agentID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteAgent(agent)
+		responseMsg, err := api.DeleteAgent(ctx, agent)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportAgents(aType, showVerbose)
+		err := api.ExportAgents(ctx, aType, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting /agents/%s, %s", aType, err)
+			return "", fmt.Errorf("Exporting /agents/%s, %w", aType, err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runAccessionCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runAccessionCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	// Repo ID is passed as a JSON object
@@ -373,7 +776,7 @@ func runAccessionCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	}
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateAccession(repoID, accession)
+		response, err := api.CreateAccession(ctx, repoID, accession)
 		if err != nil {
 			return "", err
 		}
@@ -387,7 +790,7 @@ func runAccessionCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), nil
 	case "list":
 		if accessionID == 0 {
-			accessions, err := api.ListAccessions(repoID)
+			accessions, err := api.ListAccessions(ctx, repoID)
 			if err != nil {
 				return "", fmt.Errorf(`{"uri": "/repositories/%d/accessions","error": %q}`, repoID, err)
 			}
@@ -397,7 +800,7 @@ func runAccessionCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		accession, err := api.GetAccession(repoID, accessionID)
+		accession, err := api.GetAccession(ctx, repoID, accessionID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -407,35 +810,35 @@ func runAccessionCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateAccession(accession)
+		responseMsg, err := api.UpdateAccession(ctx, accession)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		accession, err := api.GetAccession(repoID, accessionID)
+		accession, err := api.GetAccession(ctx, repoID, accessionID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteAccession(accession)
+		responseMsg, err := api.DeleteAccession(ctx, accession)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportAccessions(repoID, showVerbose)
+		err := api.ExportAccessions(ctx, repoID, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting repositories/%d/accessions, %s", repoID, err)
+			return "", fmt.Errorf("Exporting repositories/%d/accessions, %w", repoID, err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runSubjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runSubjectCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	subject := new(cait.Subject)
@@ -448,7 +851,7 @@ func runSubjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	subjectID := cait.URIToID(subject.URI)
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateSubject(subject)
+		response, err := api.CreateSubject(ctx, subject)
 		if err != nil {
 			return "", err
 		}
@@ -462,7 +865,7 @@ func runSubjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), nil
 	case "list":
 		if subjectID == 0 {
-			subjects, err := api.ListSubjects()
+			subjects, err := api.ListSubjects(ctx)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q}`, err)
 			}
@@ -472,7 +875,7 @@ func runSubjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		subject, err := api.GetSubject(subjectID)
+		subject, err := api.GetSubject(ctx, subjectID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -482,37 +885,74 @@ func runSubjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateSubject(subject)
+		responseMsg, err := api.UpdateSubject(ctx, subject)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		subject, err := api.GetSubject(subjectID)
+		subject, err := api.GetSubject(ctx, subjectID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteSubject(subject)
+		responseMsg, err := api.DeleteSubject(ctx, subject)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportSubjects(showVerbose)
+		err := api.ExportSubjects(ctx, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting /subjects, %s", err)
+			return "", fmt.Errorf("Exporting /subjects, %w", err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runLocationCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+// runLocationBatch creates locations from a building/floor/room payload and
+// coordinate ranges, a payload with record_uris updates those locations instead.
+func runLocationBatch(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if strings.Contains(cmd.Payload, `"record_uris"`) {
+		update := new(cait.LocationBatchUpdate)
+		if err := json.Unmarshal([]byte(cmd.Payload), &update); err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+		responseMsg, err := api.UpdateLocationBatch(ctx, update)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	}
+	batch := new(cait.LocationBatch)
+	if cmd.Payload != "" {
+		if err := json.Unmarshal([]byte(cmd.Payload), &batch); err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	if locationRanges != "" {
+		if err := batch.SetCoordinateRanges(strings.Split(locationRanges, ";")...); err != nil {
+			return "", err
+		}
+	}
+	results, err := api.CreateLocationBatch(ctx, batch, dryRun)
+	if err != nil {
+		return "", err
+	}
+	src, err := json.MarshalIndent(results, "", "    ")
+	return string(src), err
+}
+
+func runLocationCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
+	if cmd.Action == "batch" {
+		return runLocationBatch(ctx, api, cmd)
+	}
 	location := new(cait.Location)
 	if cmd.Payload != "" {
 		err := json.Unmarshal([]byte(cmd.Payload), &location)
@@ -523,7 +963,7 @@ func runLocationCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	locationID := cait.URIToID(location.URI)
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateLocation(location)
+		response, err := api.CreateLocation(ctx, location)
 		if err != nil {
 			return "", err
 		}
@@ -537,7 +977,7 @@ func runLocationCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), nil
 	case "list":
 		if locationID == 0 {
-			locations, err := api.ListLocations()
+			locations, err := api.ListLocations(ctx)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q}`, err)
 			}
@@ -547,7 +987,7 @@ func runLocationCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		location, err := api.GetLocation(locationID)
+		location, err := api.GetLocation(ctx, locationID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -557,35 +997,35 @@ func runLocationCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateLocation(location)
+		responseMsg, err := api.UpdateLocation(ctx, location)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		location, err := api.GetLocation(locationID)
+		location, err := api.GetLocation(ctx, locationID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteLocation(location)
+		responseMsg, err := api.DeleteLocation(ctx, location)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportLocations(showVerbose)
+		err := api.ExportLocations(ctx, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting /locations, %s", err)
+			return "", fmt.Errorf("Exporting /locations, %w", err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runVocabularyCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runVocabularyCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	vocabulary := new(cait.Vocabulary)
@@ -598,7 +1038,7 @@ func runVocabularyCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error)
 	vocabularyID := cait.URIToID(vocabulary.URI)
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateVocabulary(vocabulary)
+		response, err := api.CreateVocabulary(ctx, vocabulary)
 		if err != nil {
 			return "", err
 		}
@@ -613,7 +1053,7 @@ func runVocabularyCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error)
 	case "list":
 		if vocabularyID == 0 {
 			var ids []int
-			ids, err := api.ListVocabularies()
+			ids, err := api.ListVocabularies(ctx)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q}`, err)
 			}
@@ -623,7 +1063,7 @@ func runVocabularyCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error)
 			}
 			return string(src), nil
 		}
-		vocabulary, err := api.GetVocabulary(vocabularyID)
+		vocabulary, err := api.GetVocabulary(ctx, vocabularyID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -633,35 +1073,35 @@ func runVocabularyCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error)
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateVocabulary(vocabulary)
+		responseMsg, err := api.UpdateVocabulary(ctx, vocabulary)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		vocabulary, err := api.GetVocabulary(vocabularyID)
+		vocabulary, err := api.GetVocabulary(ctx, vocabularyID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteVocabulary(vocabulary)
+		responseMsg, err := api.DeleteVocabulary(ctx, vocabulary)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportVocabularies(showVerbose)
+		err := api.ExportVocabularies(ctx, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting /vocabularies, %s", err)
+			return "", fmt.Errorf("Exporting /vocabularies, %w", err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runTermCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runTermCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	term := new(cait.Term)
@@ -678,7 +1118,7 @@ func runTermCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	}
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateTerm(vocabularyID, term)
+		response, err := api.CreateTerm(ctx, vocabularyID, term)
 		if err != nil {
 			return "", err
 		}
@@ -694,7 +1134,7 @@ func runTermCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		//FIXME: calculate the vocabulary ID
 		if termID == 0 {
 			var ids []int
-			ids, err := api.ListTermIDs(vocabularyID)
+			ids, err := api.ListTermIDs(ctx, vocabularyID)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q}`, err)
 			}
@@ -704,7 +1144,7 @@ func runTermCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		term, err := api.GetTerm(vocabularyID, termID)
+		term, err := api.GetTerm(ctx, vocabularyID, termID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -714,7 +1154,7 @@ func runTermCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateTerm(term)
+		responseMsg, err := api.UpdateTerm(ctx, term)
 		if err != nil {
 			return "", err
 		}
@@ -722,28 +1162,28 @@ func runTermCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), err
 	case "delete":
 		//FIXME: calculate the vocabulary ID
-		term, err := api.GetTerm(vocabularyID, termID)
+		term, err := api.GetTerm(ctx, vocabularyID, termID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteTerm(term)
+		responseMsg, err := api.DeleteTerm(ctx, term)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportTerms(showVerbose)
+		err := api.ExportTerms(ctx, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting /terms, %s", err)
+			return "", fmt.Errorf("Exporting /terms, %w", err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runDigitalObjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runDigitalObjectCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	obj := new(cait.DigitalObject)
@@ -760,7 +1200,7 @@ func runDigitalObjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, erro
 	}
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateDigitalObject(repoID, obj)
+		response, err := api.CreateDigitalObject(ctx, repoID, obj)
 		if err != nil {
 			return "", fmt.Errorf("Create digital_object fialed %s, %s", obj.URI, err)
 		}
@@ -774,7 +1214,7 @@ func runDigitalObjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, erro
 		return string(src), nil
 	case "list":
 		if objID == 0 {
-			objs, err := api.ListDigitalObjects(repoID)
+			objs, err := api.ListDigitalObjects(ctx, repoID)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/digital_objects"}`, err, repoID)
 			}
@@ -784,7 +1224,7 @@ func runDigitalObjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, erro
 			}
 			return string(src), nil
 		}
-		obj, err := api.GetDigitalObject(repoID, objID)
+		obj, err := api.GetDigitalObject(ctx, repoID, objID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -794,35 +1234,124 @@ func runDigitalObjectCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, erro
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateDigitalObject(obj)
+		responseMsg, err := api.UpdateDigitalObject(ctx, obj)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		obj, err := api.GetDigitalObject(repoID, objID)
+		obj, err := api.GetDigitalObject(ctx, repoID, objID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteDigitalObject(obj)
+		responseMsg, err := api.DeleteDigitalObject(ctx, obj)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
+	case "tree":
+		tree, err := api.GetDigitalObjectTree(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(tree)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
 	case "export":
-		err := api.ExportDigitalObjects(repoID, showVerbose)
+		err := api.ExportDigitalObjects(ctx, repoID, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting repositories/%d/digital_objects, %s", repoID, err)
+			return "", fmt.Errorf("Exporting repositories/%d/digital_objects, %w", repoID, err)
 		}
 		return `{"status": "ok"}`, nil
 	}
 	return "", fmt.Errorf("runDigitalObjectCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runResourceCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	if err := api.Login(); err != nil {
+func runDigitalObjectComponentCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	obj := new(cait.DigitalObjectComponent)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	objID := cait.URIToID(obj.URI)
+	repoID := cait.URIToRepoID(obj.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/digital_object_components"} or {"uri":"/repositories/2/digital_object_components/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateDigitalObjectComponent(ctx, repoID, obj)
+		if err != nil {
+			return "", fmt.Errorf("Create digital object component failed %s, %s", obj.URI, err)
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create digital object component status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", fmt.Errorf("Create digital object component response %s, %s", obj.URI, err)
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListDigitalObjectComponents(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/digital_object_components"}`, err, repoID)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetDigitalObjectComponent(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateDigitalObjectComponent(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetDigitalObjectComponent(ctx, repoID, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteDigitalObjectComponent(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "export":
+		err := api.ExportDigitalObjectComponents(ctx, repoID, showVerbose)
+		if err != nil {
+			return "", fmt.Errorf("Exporting repositories/%d/digital_object_components, %w", repoID, err)
+		}
+		return `{"status": "ok"}`, nil
+	}
+	return "", fmt.Errorf("runDigitalObjectComponentCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runResourceCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
 		return "", err
 	}
 	obj := new(cait.Resource)
@@ -839,7 +1368,7 @@ func runResourceCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 	}
 	switch cmd.Action {
 	case "create":
-		response, err := api.CreateResource(repoID, obj)
+		response, err := api.CreateResource(ctx, repoID, obj)
 		if err != nil {
 			return "", fmt.Errorf("Create resource fialed %s, %s", obj.URI, err)
 		}
@@ -853,7 +1382,7 @@ func runResourceCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		return string(src), nil
 	case "list":
 		if objID == 0 {
-			objs, err := api.ListResources(repoID)
+			objs, err := api.ListResources(ctx, repoID)
 			if err != nil {
 				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/resources"}`, err, repoID)
 			}
@@ -863,7 +1392,7 @@ func runResourceCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 			}
 			return string(src), nil
 		}
-		obj, err := api.GetResource(repoID, objID)
+		obj, err := api.GetResource(ctx, repoID, objID)
 		if err != nil {
 			return "", fmt.Errorf(`{"error": %q}`, err)
 		}
@@ -873,82 +1402,1017 @@ func runResourceCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
 		}
 		return string(src), nil
 	case "update":
-		responseMsg, err := api.UpdateResource(obj)
+		responseMsg, err := api.UpdateResource(ctx, obj)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "delete":
-		obj, err := api.GetResource(repoID, objID)
+		obj, err := api.GetResource(ctx, repoID, objID)
 		if err != nil {
 			return "", err
 		}
-		responseMsg, err := api.DeleteResource(obj)
+		responseMsg, err := api.DeleteResource(ctx, obj)
 		if err != nil {
 			return "", err
 		}
 		src, err := json.Marshal(responseMsg)
 		return string(src), err
 	case "export":
-		err := api.ExportResources(repoID, showVerbose)
+		err := api.ExportResources(ctx, repoID, showVerbose)
 		if err != nil {
-			return "", fmt.Errorf("Exporting repositories/%d/resources, %s", repoID, err)
+			return "", fmt.Errorf("Exporting repositories/%d/resources, %w", repoID, err)
 		}
 		return `{"status": "ok"}`, nil
+	case "tree":
+		tree, err := api.GetResourceTree(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(tree)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
 	}
 	return "", fmt.Errorf("runResourceCMd() action %s not implemented for %s", cmd.Action, cmd.Subject)
 }
 
-func runCmd(api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
-	switch cmd.Subject {
-	case "archivesspace":
-		return runArchivesSpaceCmd(api, cmd)
-	case "repository":
-		return runRepoCmd(api, cmd)
-	case "agent":
-		return runAgentCmd(api, cmd)
-	case "accession":
-		return runAccessionCmd(api, cmd)
-	case "subject":
-		return runSubjectCmd(api, cmd)
-	case "location":
-		return runLocationCmd(api, cmd)
-	case "vocabulary":
-		return runVocabularyCmd(api, cmd)
-	case "term":
-		return runTermCmd(api, cmd)
-	case "digital_object":
-		return runDigitalObjectCmd(api, cmd)
-		// case "resource":
-		// 	return runResourceCmd(api, cmd)
+func runArchivalObjectCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf("%s %s not implemented", cmd.Subject, cmd.Action)
-}
-
-func (c *command) String() string {
-	src, err := json.Marshal(c)
-	if err != nil {
-		return fmt.Sprintf("%s", err)
+	obj := new(cait.ArchivalObject)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
 	}
-	return string(src)
-}
-
-func init() {
-	// Standard Options
-	flag.BoolVar(&showHelp, "h", false, "Display help")
-	flag.BoolVar(&showHelp, "help", false, "Display help")
-	flag.BoolVar(&showVersion, "v", false, "Display version")
-	flag.BoolVar(&showVersion, "version", false, "Display version")
-	flag.BoolVar(&showLicense, "l", false, "Display license")
-	flag.BoolVar(&showLicense, "license", false, "Display license")
-
-	// App Options
-	flag.StringVar(&payload, "i", "", "Use this filepath for the payload")
-	flag.StringVar(&payload, "input", "", "Use this filepath for the payload")
-	flag.BoolVar(&showVerbose, "verbose", false, "more verbose logging")
-}
-
+	objID := cait.URIToID(obj.URI)
+	repoID := cait.URIToRepoID(obj.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/archival_objects"} or {"uri":"/repositories/2/archival_objects/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateArchivalObject(ctx, repoID, obj)
+		if err != nil {
+			return "", fmt.Errorf("Create archival_object failed %s, %s", obj.URI, err)
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create archival_object status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", fmt.Errorf("Create archival_object response %s, %s", obj.URI, err)
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListArchivalObjects(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/archival_objects"}`, err, repoID)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetArchivalObject(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateArchivalObject(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetArchivalObject(ctx, repoID, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteArchivalObject(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "export":
+		err := api.ExportArchivalObjects(ctx, repoID, showVerbose)
+		if err != nil {
+			return "", fmt.Errorf("Exporting repositories/%d/archival_objects, %w", repoID, err)
+		}
+		return `{"status": "ok"}`, nil
+	case "tree":
+		if objID == 0 {
+			return "", fmt.Errorf(`tree requires an archival object uri, e.g. {"uri":"/repositories/2/archival_objects/3"}`)
+		}
+		objs, err := api.ListArchivalObjectChildren(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(objs)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	}
+	return "", fmt.Errorf("runArchivalObjectCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runTopContainerCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	obj := new(cait.TopContainer)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	objID := cait.URIToID(obj.URI)
+	repoID := cait.URIToRepoID(obj.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/top_containers"} or {"uri":"/repositories/2/top_containers/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateTopContainer(ctx, repoID, obj)
+		if err != nil {
+			return "", fmt.Errorf("Create top_container failed %s, %s", obj.URI, err)
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create top_container status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", fmt.Errorf("Create top_container response %s, %s", obj.URI, err)
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListTopContainers(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/top_containers"}`, err, repoID)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetTopContainer(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateTopContainer(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetTopContainer(ctx, repoID, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteTopContainer(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "export":
+		err := api.ExportTopContainers(ctx, repoID, showVerbose)
+		if err != nil {
+			return "", fmt.Errorf("Exporting repositories/%d/top_containers, %w", repoID, err)
+		}
+		return `{"status": "ok"}`, nil
+	}
+	return "", fmt.Errorf("runTopContainerCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runContainerProfileCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	obj := new(cait.ContainerProfile)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	objID := cait.URIToID(obj.URI)
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateContainerProfile(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create container_profile status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", err
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListContainerProfiles(ctx)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q}`, err)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetContainerProfile(ctx, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateContainerProfile(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetContainerProfile(ctx, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteContainerProfile(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	}
+	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runSearchCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	payload := struct {
+		URI string `json:"uri"`
+		cait.SearchOptions
+	}{}
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &payload)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	// NOTE: a uri of /repositories/2 searches that repository, without one all repositories are searched
+	repoID := cait.URIToRepoID(payload.URI)
+	switch cmd.Action {
+	case "list":
+		results, err := api.Search(ctx, repoID, &payload.SearchOptions)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(results)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	}
+	return "", fmt.Errorf("runSearchCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runEventCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	obj := new(cait.Event)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	objID := cait.URIToID(obj.URI)
+	repoID := cait.URIToRepoID(obj.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/events"} or {"uri":"/repositories/2/events/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateEvent(ctx, repoID, obj)
+		if err != nil {
+			return "", fmt.Errorf("Create event failed %s, %s", obj.URI, err)
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create event status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", fmt.Errorf("Create event response %s, %s", obj.URI, err)
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListEvents(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/events"}`, err, repoID)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetEvent(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateEvent(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetEvent(ctx, repoID, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteEvent(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "export":
+		err := api.ExportEvents(ctx, repoID, showVerbose)
+		if err != nil {
+			return "", fmt.Errorf("Exporting repositories/%d/events, %w", repoID, err)
+		}
+		return `{"status": "ok"}`, nil
+	}
+	return "", fmt.Errorf("runEventCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runClassificationCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	obj := new(cait.Classification)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	objID := cait.URIToID(obj.URI)
+	repoID := cait.URIToRepoID(obj.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/classifications"} or {"uri":"/repositories/2/classifications/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateClassification(ctx, repoID, obj)
+		if err != nil {
+			return "", fmt.Errorf("Create classification failed %s, %s", obj.URI, err)
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create classification status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", fmt.Errorf("Create classification response %s, %s", obj.URI, err)
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListClassifications(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/classifications"}`, err, repoID)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetClassification(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateClassification(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetClassification(ctx, repoID, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteClassification(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "tree":
+		tree, err := api.GetClassificationTree(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(tree)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "export":
+		err := api.ExportClassifications(ctx, repoID, showVerbose)
+		if err != nil {
+			return "", fmt.Errorf("Exporting repositories/%d/classifications, %w", repoID, err)
+		}
+		return `{"status": "ok"}`, nil
+	}
+	return "", fmt.Errorf("runClassificationCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runClassificationTermCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	obj := new(cait.ClassificationTerm)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	objID := cait.URIToID(obj.URI)
+	repoID := cait.URIToRepoID(obj.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/classification_terms"} or {"uri":"/repositories/2/classification_terms/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateClassificationTerm(ctx, repoID, obj)
+		if err != nil {
+			return "", fmt.Errorf("Create classification term failed %s, %s", obj.URI, err)
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create classification term status %s, %s", obj.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", fmt.Errorf("Create classification term response %s, %s", obj.URI, err)
+		}
+		return string(src), nil
+	case "list":
+		if objID == 0 {
+			objs, err := api.ListClassificationTerms(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/classification_terms"}`, err, repoID)
+			}
+			src, err := json.Marshal(objs)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		obj, err := api.GetClassificationTerm(ctx, repoID, objID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateClassificationTerm(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		obj, err := api.GetClassificationTerm(ctx, repoID, objID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteClassificationTerm(ctx, obj)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "export":
+		err := api.ExportClassificationTerms(ctx, repoID, showVerbose)
+		if err != nil {
+			return "", fmt.Errorf("Exporting repositories/%d/classification_terms, %w", repoID, err)
+		}
+		return `{"status": "ok"}`, nil
+	}
+	return "", fmt.Errorf("runClassificationTermCmd() action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runUserCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	if cmd.Action == "provision" {
+		return provisionUsers(ctx, api, cmd)
+	}
+	// NOTE: the password isn't part of the user record, it is passed along with it
+	obj := new(struct {
+		cait.User
+		Password string `json:"password,omitempty"`
+	})
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	user := &obj.User
+	userID := cait.URIToID(user.URI)
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateUser(ctx, user, obj.Password)
+		if err != nil {
+			return "", err
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create user status %s, %s", user.Username, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", err
+		}
+		return string(src), nil
+	case "list":
+		if userID == 0 && user.Username == "" {
+			users, err := api.ListUsers(ctx)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q}`, err)
+			}
+			src, err := json.Marshal(users)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		var err error
+		if userID == 0 {
+			user, err = api.GetUserByUsername(ctx, user.Username)
+		} else {
+			user, err = api.GetUser(ctx, userID)
+		}
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(user)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateUser(ctx, user, obj.Password)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		user, err := api.GetUser(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteUser(ctx, user)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	}
+	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+// provisionUsers creates or updates the accounts listed in a CSV roster
+// (the payload) returning a JSON array with one result per account
+func provisionUsers(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	entries, err := cait.ReadRoster(strings.NewReader(cmd.Payload))
+	if err != nil {
+		return "", err
+	}
+	type result struct {
+		Username string            `json:"username"`
+		Response *cait.ResponseMsg `json:"response,omitempty"`
+		Error    string            `json:"error,omitempty"`
+	}
+	results := []*result{}
+	failed := 0
+	for _, entry := range entries {
+		r := &result{Username: entry.Username}
+		r.Response, err = api.ProvisionUser(ctx, entry)
+		if err != nil {
+			r.Error = err.Error()
+			failed++
+		}
+		if showVerbose == true {
+			log.Printf("provisioned %s", entry.Username)
+		}
+		results = append(results, r)
+	}
+	src, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if failed > 0 {
+		return "", fmt.Errorf("%s\n%d of %d accounts failed to provision", src, failed, len(entries))
+	}
+	return string(src), nil
+}
+
+func runGroupCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	group := new(cait.Group)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &group)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	groupID := cait.URIToID(group.URI)
+	repoID := cait.URIToRepoID(group.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/groups"} or {"uri":"/repositories/2/groups/3"}`)
+	}
+	switch cmd.Action {
+	case "create":
+		response, err := api.CreateGroup(ctx, repoID, group)
+		if err != nil {
+			return "", err
+		}
+		if response.Status != "Created" {
+			return "", fmt.Errorf("Create group status %s, %s", group.URI, response)
+		}
+		src, err := json.Marshal(response)
+		if err != nil {
+			return "", err
+		}
+		return string(src), nil
+	case "list":
+		if groupID == 0 {
+			groups, err := api.ListGroups(ctx, repoID)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q, "uri": "/repositories/%d/groups"}`, err, repoID)
+			}
+			src, err := json.Marshal(groups)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		group, err := api.GetGroup(ctx, repoID, groupID)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(group)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	case "update":
+		responseMsg, err := api.UpdateGroup(ctx, group)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "delete":
+		group, err := api.GetGroup(ctx, repoID, groupID)
+		if err != nil {
+			return "", err
+		}
+		responseMsg, err := api.DeleteGroup(ctx, group)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	}
+	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runJobCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	// NOTE: files to upload with an import job are passed along with the job
+	obj := new(struct {
+		cait.Job
+		Files []string `json:"files,omitempty"`
+	})
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &obj)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	job := &obj.Job
+	jobID := cait.URIToID(job.URI)
+	repoID := cait.URIToRepoID(job.URI)
+	if repoID == 0 {
+		return "", fmt.Errorf(`Can't determine repository ID from uri, e.g. {"uri":"/repositories/2/jobs"} or {"uri":"/repositories/2/jobs/3"}`)
+	}
+	if jobID == 0 && cmd.Action != "submit" {
+		return "", fmt.Errorf(`%s requires a job uri, e.g. {"uri":"/repositories/2/jobs/3"}`, cmd.Action)
+	}
+	var err error
+	switch cmd.Action {
+	case "submit":
+		var response *cait.ResponseMsg
+		if len(obj.Files) > 0 {
+			response, err = api.SubmitJobWithFiles(ctx, repoID, job, obj.Files)
+		} else {
+			response, err = api.SubmitJob(ctx, repoID, job)
+		}
+		if err != nil {
+			return "", err
+		}
+		if waitForJob == false {
+			src, err := json.Marshal(response)
+			return string(src), err
+		}
+		return jobStatus(ctx, api, repoID, response.ID)
+	case "status":
+		return jobStatus(ctx, api, repoID, jobID)
+	case "log":
+		if waitForJob == true {
+			job, err := api.StreamJobLog(ctx, repoID, jobID, os.Stdout, jobPoll)
+			if err != nil {
+				return "", err
+			}
+			if job.Status != "completed" {
+				return "", fmt.Errorf("job %s %s", job.URI, job.Status)
+			}
+			return "", nil
+		}
+		src, err := api.GetJobLog(ctx, repoID, jobID, 0)
+		return string(src), err
+	case "output":
+		if waitForJob == true {
+			if _, err = api.WaitForJob(ctx, repoID, jobID, jobPoll); err != nil {
+				return "", err
+			}
+		}
+		fileIDs, err := api.ListJobOutputFiles(ctx, repoID, jobID)
+		if err != nil {
+			return "", err
+		}
+		fnames := []string{}
+		for _, fileID := range fileIDs {
+			fname := path.Join(jobOutput, fmt.Sprintf("job-%d-%d", jobID, fileID))
+			fp, err := os.Create(fname)
+			if err != nil {
+				return "", err
+			}
+			err = api.GetJobOutputFile(ctx, repoID, jobID, fileID, fp)
+			fp.Close()
+			if err != nil {
+				return "", err
+			}
+			fnames = append(fnames, fname)
+		}
+		src, err := json.Marshal(fnames)
+		return string(src), err
+	case "delete":
+		response, err := api.CancelJob(ctx, repoID, jobID)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(response)
+		return string(src), err
+	}
+	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+// jobStatus returns a job as JSON, waiting for it to finish if -wait was given,
+// an unsuccessful job is returned as an error
+func jobStatus(ctx context.Context, api *cait.ArchivesSpaceAPI, repoID, jobID int) (string, error) {
+	var (
+		job *cait.Job
+		err error
+	)
+	if waitForJob == true {
+		job, err = api.WaitForJob(ctx, repoID, jobID, jobPoll)
+	} else {
+		job, err = api.GetJob(ctx, repoID, jobID)
+	}
+	if err != nil {
+		return "", err
+	}
+	src, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	if job.IsFinished() == true && job.Status != "completed" {
+		return "", fmt.Errorf("%s", src)
+	}
+	return string(src), nil
+}
+
+func runEnumerationCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	if err := login(ctx, api); err != nil {
+		return "", err
+	}
+	enum := new(cait.Enumeration)
+	if cmd.Payload != "" {
+		err := json.Unmarshal([]byte(cmd.Payload), &enum)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode %s, error: %s", cmd.Payload, err)
+		}
+	}
+	enumID := cait.URIToID(enum.URI)
+	switch cmd.Action {
+	case "create":
+		// Adds the payload's values to an existing enumeration
+		if enumID == 0 {
+			return "", fmt.Errorf(`Adding values requires an enumeration uri, e.g. {"uri":"/config/enumerations/14","values":["reel"]}`)
+		}
+		responseMsg, err := api.AddEnumerationValues(ctx, enumID, enum.Values...)
+		if err != nil {
+			return "", err
+		}
+		src, err := json.Marshal(responseMsg)
+		return string(src), err
+	case "list":
+		if enumID == 0 && enum.Name == "" {
+			enums, err := api.ListEnumerations(ctx)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": %q}`, err)
+			}
+			src, err := json.Marshal(enums)
+			if err != nil {
+				return "", fmt.Errorf(`{"error": "Cannot JSON encode %s %s"}`, cmd.Payload, err)
+			}
+			return string(src), nil
+		}
+		var err error
+		if enumID == 0 {
+			enum, err = api.GetEnumerationByName(ctx, enum.Name)
+		} else {
+			enum, err = api.GetEnumeration(ctx, enumID)
+		}
+		if err != nil {
+			return "", fmt.Errorf(`{"error": %q}`, err)
+		}
+		src, err := json.Marshal(enum)
+		if err != nil {
+			return "", fmt.Errorf(`{"error": "Cannot find %s %s"}`, cmd.Payload, err)
+		}
+		return string(src), nil
+	}
+	return "", fmt.Errorf("action %s not implemented for %s", cmd.Action, cmd.Subject)
+}
+
+func runCmd(ctx context.Context, api *cait.ArchivesSpaceAPI, cmd *command) (string, error) {
+	switch cmd.Subject {
+	case "archivesspace":
+		return runArchivesSpaceCmd(ctx, api, cmd)
+	case "repository":
+		return runRepoCmd(ctx, api, cmd)
+	case "agent":
+		return runAgentCmd(ctx, api, cmd)
+	case "accession":
+		return runAccessionCmd(ctx, api, cmd)
+	case "subject":
+		return runSubjectCmd(ctx, api, cmd)
+	case "location":
+		return runLocationCmd(ctx, api, cmd)
+	case "vocabulary":
+		return runVocabularyCmd(ctx, api, cmd)
+	case "term":
+		return runTermCmd(ctx, api, cmd)
+	case "digital_object":
+		return runDigitalObjectCmd(ctx, api, cmd)
+	case "resource":
+		return runResourceCmd(ctx, api, cmd)
+	case "archival_object":
+		return runArchivalObjectCmd(ctx, api, cmd)
+	case "top_container":
+		return runTopContainerCmd(ctx, api, cmd)
+	case "container_profile":
+		return runContainerProfileCmd(ctx, api, cmd)
+	case "digital_object_component":
+		return runDigitalObjectComponentCmd(ctx, api, cmd)
+	case "event":
+		return runEventCmd(ctx, api, cmd)
+	case "search":
+		return runSearchCmd(ctx, api, cmd)
+	case "classification":
+		return runClassificationCmd(ctx, api, cmd)
+	case "classification_term":
+		return runClassificationTermCmd(ctx, api, cmd)
+	case "user":
+		return runUserCmd(ctx, api, cmd)
+	case "group":
+		return runGroupCmd(ctx, api, cmd)
+	case "job":
+		return runJobCmd(ctx, api, cmd)
+	case "enumeration":
+		return runEnumerationCmd(ctx, api, cmd)
+	case "dataset":
+		return runDatasetCmd(ctx, api, cmd)
+	case "run":
+		return runScriptCmd(ctx, api, cmd)
+	}
+	return "", fmt.Errorf("%s %s not implemented", cmd.Subject, cmd.Action)
+}
+
+func (c *command) String() string {
+	src, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("%s", err)
+	}
+	return string(src)
+}
+
+func init() {
+	// Standard Options
+	flag.BoolVar(&showHelp, "h", false, "Display help")
+	flag.BoolVar(&showHelp, "help", false, "Display help")
+	flag.BoolVar(&showVersion, "v", false, "Display version")
+	flag.BoolVar(&showVersion, "version", false, "Display version")
+	flag.BoolVar(&showLicense, "l", false, "Display license")
+	flag.BoolVar(&showLicense, "license", false, "Display license")
+
+	// App Options
+	flag.StringVar(&payload, "i", "", "Use this filepath for the payload")
+	flag.StringVar(&payload, "input", "", "Use this filepath for the payload")
+	flag.BoolVar(&showVerbose, "verbose", false, "more verbose logging")
+	flag.IntVar(&maxRetries, "retries", maxRetries, "number of times to retry a failed request (0 disables retries)")
+	flag.DurationVar(&timeout, "timeout", 0, "cancel the command after this duration (e.g. 30s, 2h), zero means no timeout")
+	flag.BoolVar(&waitForJob, "wait", false, "wait for a job to finish before reporting its status, log or output")
+	flag.DurationVar(&jobPoll, "poll", jobPoll, "how often to check a job's status when waiting")
+	flag.StringVar(&jobOutput, "output", jobOutput, "directory to save job output files in")
+	flag.BoolVar(&validateEnums, "validate", false, "check controlled values against ArchivesSpace's enumerations before create and update")
+	flag.BoolVar(&dryRun, "dry-run", false, "show what a merge, location batch, sync or dataset migration would change without changing anything")
+	flag.StringVar(&locationRanges, "ranges", "", "coordinate ranges for a location batch, e.g. \"Range:1-10;Shelf:A-F\"")
+	flag.BoolVar(&incremental, "incremental", false, "only export records modified since the last export and remove deleted records")
+	flag.IntVar(&exportWorkers, "workers", exportWorkers, fmt.Sprintf("number of pages an export fetches, or batch lines run, at once (1 to %d)", cait.MaxExportWorkers))
+	flag.BoolVar(&showProgress, "progress", false, "show each collection's export progress (done, total, rate and ETA)")
+	flag.BoolVar(&resumeExport, "resume", false, "resume an unfinished export from its checkpoint, skipping completed stages")
+	flag.BoolVar(&failFast, "fail-fast", false, "stop an export or import at the first record that fails instead of reporting it and continuing")
+	flag.StringVar(&exportErrorLog, "error-log", "", "append records that couldn't be exported to this JSONL file (they are also saved in errors.ds)")
+	flag.StringVar(&batchInput, "batch", "", "run SUBJECT ACTION for each JSON line of this file (\"-\" for stdin) in one session, printing a result line for each")
+	flag.BoolVar(&markDeleted, "mark-deleted", false, "mark deleted records with \"_deleted\" instead of removing them in an incremental export")
+}
+
 func main() {
 	appName := path.Base(os.Args[0])
 	flag.Parse()
@@ -958,7 +2422,7 @@ func main() {
 	cfg.LicenseText = fmt.Sprintf(cait.LicenseText, appName, cait.Version)
 	cfg.UsageText = fmt.Sprintf(usage, appName)
 	cfg.DescriptionText = fmt.Sprintf(description, appName, strings.Join(subjects, ", "), strings.Join(actions, ", "), appName)
-	cfg.ExampleText = fmt.Sprintf(examples, appName, appName, appName)
+	cfg.ExampleText = fmt.Sprintf(examples, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName)
 	cfg.OptionText = "OPTIONS\n\n"
 
 	if showHelp == true {
@@ -1015,9 +2479,49 @@ func main() {
 		log.SetOutput(os.Stderr)
 	}
 
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	api := cait.New(caitAPIURL, caitUsername, caitPassword, caitDataset)
-	src, err := runCmd(api, cmd)
+	api.Retry.MaxRetries = maxRetries
+	api.Verbose = showVerbose
+	api.ValidateEnumerations = validateEnums
+	api.Incremental = incremental
+	api.MarkDeleted = markDeleted
+	api.ExportWorkers = exportWorkers
+	api.Resume = resumeExport
+	api.ExportErrorLog = exportErrorLog
+	if failFast == true {
+		api.ExportPolicy = cait.ExportFailFast
+	}
+	if showProgress == true {
+		api.ExportProgress = renderProgress
+	}
+	if batchInput != "" {
+		failed, err := runBatch(ctx, api, cmd, batchInput)
+		if err != nil {
+			log.Printf("%s", err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			log.Printf("%d lines failed", failed)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	src, err := runCmd(ctx, api, cmd)
 	if err != nil {
+		// Records that couldn't be exported are saved so the rest of the export is usable
+		report := new(cait.ExportReport)
+		if errors.As(err, &report) == true {
+			if err := api.SaveExportReport(report); err != nil {
+				log.Printf("%s", err)
+			}
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,225 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// InstanceContainer is an Instance resolved into its top container,
+// sub-container, container profile and current location
+type InstanceContainer struct {
+	InstanceType      string             `json:"instance_type,omitempty"`
+	TopContainer      *TopContainer      `json:"top_container,omitempty"`
+	SubContainer      *SubContainer      `json:"sub_container,omitempty"`
+	ContainerProfile  *ContainerProfile  `json:"container_profile,omitempty"`
+	ContainerLocation *ContainerLocation `json:"container_location,omitempty"`
+	Location          *Location          `json:"location,omitempty"`
+}
+
+// CreateTopContainer creates a new top container in a Repository
+func (api *ArchivesSpaceAPI) CreateTopContainer(ctx context.Context, repoID int, obj *TopContainer) (*ResponseMsg, error) {
+	obj.JSONModelType = "top_container"
+	obj.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/top_containers", repoID), nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
+	obj.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetTopContainer retrieves a top container from a Repository
+func (api *ArchivesSpaceAPI) GetTopContainer(ctx context.Context, repoID, objID int) (*TopContainer, error) {
+	obj := new(TopContainer)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/top_containers/%d", repoID, objID), nil)
+	if err := api.GetAPI(ctx, u, obj); err != nil {
+		return nil, fmt.Errorf("GetTopContainer() %s, error, %w", u, err)
+	}
+	obj.ID = URIToID(obj.URI)
+	return obj, nil
+}
+
+// UpdateTopContainer updates an existing top container
+func (api *ArchivesSpaceAPI) UpdateTopContainer(ctx context.Context, obj *TopContainer) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// DeleteTopContainer deletes a top container
+func (api *ArchivesSpaceAPI) DeleteTopContainer(ctx context.Context, obj *TopContainer) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// ListTopContainers returns a list of top container ids in a Repository
+func (api *ArchivesSpaceAPI) ListTopContainers(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/top_containers`, repoID), q))
+}
+
+// CreateContainerProfile creates a new container profile
+func (api *ArchivesSpaceAPI) CreateContainerProfile(ctx context.Context, obj *ContainerProfile) (*ResponseMsg, error) {
+	obj.JSONModelType = "container_profile"
+	obj.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL("/container_profiles", nil), obj)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	obj.URI = responseMsg.URI
+	obj.ID = responseMsg.ID
+	obj.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetContainerProfile retrieves a container profile
+func (api *ArchivesSpaceAPI) GetContainerProfile(ctx context.Context, objID int) (*ContainerProfile, error) {
+	obj := new(ContainerProfile)
+	u := api.CallURL(fmt.Sprintf("/container_profiles/%d", objID), nil)
+	if err := api.GetAPI(ctx, u, obj); err != nil {
+		return nil, fmt.Errorf("GetContainerProfile() %s, error, %w", u, err)
+	}
+	obj.ID = URIToID(obj.URI)
+	return obj, nil
+}
+
+// UpdateContainerProfile updates an existing container profile
+func (api *ArchivesSpaceAPI) UpdateContainerProfile(ctx context.Context, obj *ContainerProfile) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// DeleteContainerProfile deletes a container profile
+func (api *ArchivesSpaceAPI) DeleteContainerProfile(ctx context.Context, obj *ContainerProfile) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(obj.URI, nil), obj)
+}
+
+// ListContainerProfiles returns a list of container profile ids
+func (api *ArchivesSpaceAPI) ListContainerProfiles(ctx context.Context) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(`/container_profiles`, q))
+}
+
+// refString returns the "ref" value of a JSONModel reference, e.g. {"ref": "/locations/1"}
+func refString(m map[string]interface{}) string {
+	if ref, ok := m["ref"].(string); ok == true {
+		return ref
+	}
+	return ""
+}
+
+// CurrentLocation returns the container location with a status of "current",
+// or nil if the top container has no current location
+func (obj *TopContainer) CurrentLocation() *ContainerLocation {
+	for _, loc := range obj.ContainerLocations {
+		if loc.Status == "current" {
+			return loc
+		}
+	}
+	return nil
+}
+
+// ResolveInstances resolves each instance's sub-container into its top container,
+// container profile and current location. Records are fetched once per call
+// even if they are shared by several instances. Instances without a
+// sub-container (e.g. digital object instances) are returned with only
+// InstanceType set.
+func (api *ArchivesSpaceAPI) ResolveInstances(ctx context.Context, instances []*Instance) ([]*InstanceContainer, error) {
+	topContainers := map[string]*TopContainer{}
+	profiles := map[string]*ContainerProfile{}
+	locations := map[string]*Location{}
+	var results []*InstanceContainer
+	for _, instance := range instances {
+		ic := &InstanceContainer{
+			InstanceType: instance.InstanceType,
+			SubContainer: instance.SubContainer,
+		}
+		results = append(results, ic)
+		if instance.SubContainer == nil {
+			continue
+		}
+		ref := refString(instance.SubContainer.TopContainer)
+		if ref == "" {
+			continue
+		}
+		topContainer, ok := topContainers[ref]
+		if ok == false {
+			var err error
+			topContainer, err = api.GetTopContainer(ctx, URIToRepoID(ref), URIToID(ref))
+			if err != nil {
+				return nil, fmt.Errorf("Can't resolve top container %s, %w", ref, err)
+			}
+			topContainers[ref] = topContainer
+		}
+		ic.TopContainer = topContainer
+
+		if ref := refString(topContainer.ContainerProfile); ref != "" {
+			profile, ok := profiles[ref]
+			if ok == false {
+				var err error
+				profile, err = api.GetContainerProfile(ctx, URIToID(ref))
+				if err != nil {
+					return nil, fmt.Errorf("Can't resolve container profile %s, %w", ref, err)
+				}
+				profiles[ref] = profile
+			}
+			ic.ContainerProfile = profile
+		}
+
+		ic.ContainerLocation = topContainer.CurrentLocation()
+		if ic.ContainerLocation != nil && ic.ContainerLocation.Ref != "" {
+			ref := ic.ContainerLocation.Ref
+			location, ok := locations[ref]
+			if ok == false {
+				var err error
+				location, err = api.GetLocation(ctx, URIToID(ref))
+				if err != nil {
+					return nil, fmt.Errorf("Can't resolve location %s, %w", ref, err)
+				}
+				locations[ref] = location
+			}
+			ic.Location = location
+		}
+	}
+	return results, nil
+}
+
+// ResolveAccessionInstances resolves the instances of an accession, see ResolveInstances
+func (api *ArchivesSpaceAPI) ResolveAccessionInstances(ctx context.Context, accession *Accession) ([]*InstanceContainer, error) {
+	// Accession.Instances are generic maps, round trip them through JSON to get []*Instance
+	src, err := json.Marshal(accession.Instances)
+	if err != nil {
+		return nil, err
+	}
+	var instances []*Instance
+	if err := json.Unmarshal(src, &instances); err != nil {
+		return nil, fmt.Errorf("Can't decode instances of %s, %w", accession.URI, err)
+	}
+	return api.ResolveInstances(ctx, instances)
+}
+
+// ResolveResourceInstances resolves the instances of a resource, see ResolveInstances
+func (api *ArchivesSpaceAPI) ResolveResourceInstances(ctx context.Context, resource *Resource) ([]*InstanceContainer, error) {
+	return api.ResolveInstances(ctx, resource.Instances)
+}
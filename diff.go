@@ -0,0 +1,350 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"reflect"
+	"sort"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/dataset"
+)
+
+const (
+	// DiffAdded is a record only in the newer collection
+	DiffAdded = "added"
+	// DiffRemoved is a record only in the older collection, or marked deleted in the newer one
+	DiffRemoved = "removed"
+	// DiffModified is a record whose fields differ
+	DiffModified = "modified"
+)
+
+// DiffIgnoreFields change whenever a record is saved so aren't reported by DiffCollections
+var DiffIgnoreFields = []string{
+	"lock_version",
+	"system_mtime",
+	"user_mtime",
+}
+
+// RecordDiff describes how a record changed between two collections,
+// Fields lists the changes of a modified record.
+type RecordDiff struct {
+	Collection string       `json:"collection"`
+	Key        string       `json:"key"`
+	URI        string       `json:"uri"`
+	Change     string       `json:"change"`
+	Fields     []*FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff is a field whose value differs between two versions of a record,
+// Field is a dotted path for fields of nested objects (e.g. "dates.begin")
+type FieldDiff struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+// DiffRecords returns the fields that differ between the JSON objects from and
+// to, sorted by field. The ignore fields are skipped at any depth.
+func DiffRecords(from, to map[string]interface{}, ignore []string) []*FieldDiff {
+	skip := map[string]bool{}
+	for _, field := range ignore {
+		skip[field] = true
+	}
+	diffs := []*FieldDiff{}
+	diffObjects("", from, to, skip, &diffs)
+	return diffs
+}
+
+// diffObjects appends the differences between from and to to diffs
+func diffObjects(prefix string, from, to map[string]interface{}, skip map[string]bool, diffs *[]*FieldDiff) {
+	keys := []string{}
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; ok == false {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if skip[k] == true {
+			continue
+		}
+		a, b := withoutFields(from[k], skip), withoutFields(to[k], skip)
+		am, aIsObj := a.(map[string]interface{})
+		bm, bIsObj := b.(map[string]interface{})
+		if aIsObj == true && bIsObj == true {
+			diffObjects(prefix+k+".", am, bm, skip, diffs)
+			continue
+		}
+		if reflect.DeepEqual(a, b) == false {
+			*diffs = append(*diffs, &FieldDiff{Field: prefix + k, From: a, To: b})
+		}
+	}
+}
+
+// withoutFields returns a copy of the JSON value v without the skip fields
+func withoutFields(v interface{}, skip map[string]bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, child := range val {
+			if skip[k] == false {
+				m[k] = withoutFields(child, skip)
+			}
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(val))
+		for i, child := range val {
+			list[i] = withoutFields(child, skip)
+		}
+		return list
+	}
+	return v
+}
+
+// diffRecord compares the JSON of a record in two collections, from or to
+// is nil when the record isn't in that collection. A record marked deleted
+// (see IsDeleted) is treated as absent. nil is returned when the record is
+// unchanged.
+func diffRecord(collection, key string, from, to []byte, ignore []string) (*RecordDiff, error) {
+	if from != nil && IsDeleted(from) == true {
+		from = nil
+	}
+	if to != nil && IsDeleted(to) == true {
+		to = nil
+	}
+	if from == nil && to == nil {
+		return nil, nil
+	}
+	diff := &RecordDiff{Collection: collection, Key: key}
+	a, b := map[string]interface{}{}, map[string]interface{}{}
+	if from != nil {
+		if err := json.Unmarshal(from, &a); err != nil {
+			return nil, fmt.Errorf("Can't decode %s/%s, %w", collection, key, err)
+		}
+	}
+	if to != nil {
+		if err := json.Unmarshal(to, &b); err != nil {
+			return nil, fmt.Errorf("Can't decode %s/%s, %w", collection, key, err)
+		}
+	}
+	switch {
+	case from == nil:
+		diff.Change = DiffAdded
+		diff.URI, _ = b["uri"].(string)
+	case to == nil:
+		diff.Change = DiffRemoved
+		diff.URI, _ = a["uri"].(string)
+	default:
+		diff.Fields = DiffRecords(a, b, ignore)
+		if len(diff.Fields) == 0 {
+			return nil, nil
+		}
+		diff.Change = DiffModified
+		diff.URI, _ = b["uri"].(string)
+	}
+	if diff.URI == "" {
+		diff.URI = key
+	}
+	return diff, nil
+}
+
+// readKeys returns the keys of c, none when c is nil
+func readKeys(c *dataset.Collection) []string {
+	if c == nil {
+		return nil
+	}
+	return GetKeys(c)
+}
+
+// DiffCollections compares the records of two copies of the collection
+// named collection, e.g. the subjects of two nightly exports. from or to
+// may be nil when the collection is missing from that export. The changes
+// are sorted by key, the ignore fields aren't compared.
+func DiffCollections(collection string, from, to *dataset.Collection, ignore []string) ([]*RecordDiff, error) {
+	inFrom, inTo := map[string]bool{}, map[string]bool{}
+	keys := []string{}
+	for _, key := range readKeys(from) {
+		inFrom[key] = true
+		keys = append(keys, key)
+	}
+	for _, key := range readKeys(to) {
+		inTo[key] = true
+		if inFrom[key] == false {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	diffs := []*RecordDiff{}
+	for _, key := range keys {
+		var a, b []byte
+		var err error
+		if inFrom[key] == true {
+			if a, err = ReadJSON(from, key); err != nil {
+				return nil, fmt.Errorf("Can't read %s/%s, %w", collection, key, err)
+			}
+		}
+		if inTo[key] == true {
+			if b, err = ReadJSON(to, key); err != nil {
+				return nil, fmt.Errorf("Can't read %s/%s, %w", collection, key, err)
+			}
+		}
+		diff, err := diffRecord(collection, key, a, b, ignore)
+		if err != nil {
+			return nil, err
+		}
+		if diff != nil {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// ExportedCollections lists the collections an export writes to the
+// dataset at root, using the repositories and vocabularies found there
+func (api *ArchivesSpaceAPI) ExportedCollections(root string) []string {
+	names := []string{
+		api.Collection("repository"),
+		api.Collection("subject"),
+		api.Collection("vocabulary"),
+		api.Collection("location"),
+	}
+	for _, agentType := range AgentTypes {
+		names = append(names, api.Collection("agent", agentType))
+	}
+	vocIDs, _ := datasetIDs(root, api.Collection("vocabulary"))
+	for _, id := range vocIDs {
+		names = append(names, api.Collection("term", id))
+	}
+	repoIDs, _ := datasetIDs(root, api.Collection("repository"))
+	for _, id := range repoIDs {
+		for _, recordType := range []string{"accession", "digital_object", "digital_object_component", "resource", "archival_object", "top_container", "event", "classification", "classification_term"} {
+			names = append(names, api.Collection(recordType, id))
+		}
+	}
+	return names
+}
+
+// DiffDatasets compares collections in the datasets at from and to (e.g. two
+// export dates) ignoring DiffIgnoreFields. When collections is empty those
+// ExportedCollections finds in either dataset are compared.
+func (api *ArchivesSpaceAPI) DiffDatasets(from, to string, collections []string) ([]*RecordDiff, error) {
+	if len(collections) == 0 {
+		seen := map[string]bool{}
+		for _, name := range append(api.ExportedCollections(from), api.ExportedCollections(to)...) {
+			if seen[name] == false {
+				seen[name] = true
+				collections = append(collections, name)
+			}
+		}
+	}
+	diffs := []*RecordDiff{}
+	for _, name := range collections {
+		a, err := dataset.Open(fmt.Sprintf("%s/%s", from, name))
+		if err != nil {
+			a = nil
+		}
+		b, err := dataset.Open(fmt.Sprintf("%s/%s", to, name))
+		if err != nil {
+			b = nil
+		}
+		if a == nil && b == nil {
+			continue
+		}
+		list, err := DiffCollections(name, a, b, DiffIgnoreFields)
+		if a != nil {
+			a.Close()
+		}
+		if b != nil {
+			b.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, list...)
+	}
+	return diffs, nil
+}
+
+// WriteDiffJSONL writes one JSON line per record diff
+func WriteDiffJSONL(w io.Writer, diffs []*RecordDiff) error {
+	enc := json.NewEncoder(w)
+	for _, diff := range diffs {
+		if err := enc.Encode(diff); err != nil {
+			return fmt.Errorf("Can't write diff of %s, %w", diff.URI, err)
+		}
+	}
+	return nil
+}
+
+// diffReport is the HTML page written by WriteDiffHTML
+var diffReport = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"json": stringify,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Title }}</title></head>
+<body>
+<h1>{{ .Title }}</h1>
+<p>{{ .Added }} added, {{ .Removed }} removed, {{ .Modified }} modified</p>
+<table>
+<tr><th>Change</th><th>Collection</th><th>URI</th><th>Field</th><th>From</th><th>To</th></tr>
+{{- range .Diffs }}
+{{- $diff := . }}
+{{- if .Fields }}{{ range .Fields }}
+<tr class="{{ $diff.Change }}"><td>{{ $diff.Change }}</td><td>{{ $diff.Collection }}</td><td>{{ $diff.URI }}</td><td>{{ .Field }}</td><td><pre>{{ json .From }}</pre></td><td><pre>{{ json .To }}</pre></td></tr>
+{{- end }}{{ else }}
+<tr class="{{ .Change }}"><td>{{ .Change }}</td><td>{{ .Collection }}</td><td>{{ .URI }}</td><td></td><td></td><td></td></tr>
+{{- end }}
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// WriteDiffHTML writes the record diffs as an HTML report titled title
+func WriteDiffHTML(w io.Writer, title string, diffs []*RecordDiff) error {
+	page := struct {
+		Title                    string
+		Added, Removed, Modified int
+		Diffs                    []*RecordDiff
+	}{Title: title, Diffs: diffs}
+	for _, diff := range diffs {
+		switch diff.Change {
+		case DiffAdded:
+			page.Added++
+		case DiffRemoved:
+			page.Removed++
+		case DiffModified:
+			page.Modified++
+		}
+	}
+	if err := diffReport.Execute(w, page); err != nil {
+		return fmt.Errorf("Can't write the diff report, %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnumerationTable holds the values of each enumeration by name
+// (e.g. extent_extent_type, date_type) for validating records locally
+type EnumerationTable struct {
+	values map[string]map[string]bool
+}
+
+// ValidationError lists the fields of a record holding values that
+// are not in their enumeration
+type ValidationError struct {
+	URI        string              `json:"uri,omitempty"`
+	Validation map[string][]string `json:"error"`
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	fields := []string{}
+	for field := range e.Validation {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	msgs := []string{}
+	for _, field := range fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", field, strings.Join(e.Validation[field], ", ")))
+	}
+	return fmt.Sprintf("invalid record %s, %s", e.URI, strings.Join(msgs, "; "))
+}
+
+// ListEnumerations returns all the enumerations with their values
+func (api *ArchivesSpaceAPI) ListEnumerations(ctx context.Context) ([]*Enumeration, error) {
+	var enums []*Enumeration
+	u := api.CallURL("/config/enumerations", nil)
+	if err := api.GetAPI(ctx, u, &enums); err != nil {
+		return nil, fmt.Errorf("ListEnumerations() %s, error, %w", u, err)
+	}
+	for _, enum := range enums {
+		enum.ID = URIToID(enum.URI)
+	}
+	return enums, nil
+}
+
+// GetEnumeration returns an enumeration with its values
+func (api *ArchivesSpaceAPI) GetEnumeration(ctx context.Context, enumID int) (*Enumeration, error) {
+	enum := new(Enumeration)
+	u := api.CallURL(fmt.Sprintf("/config/enumerations/%d", enumID), nil)
+	if err := api.GetAPI(ctx, u, enum); err != nil {
+		return nil, fmt.Errorf("GetEnumeration() %s, error, %w", u, err)
+	}
+	enum.ID = URIToID(enum.URI)
+	return enum, nil
+}
+
+// GetEnumerationByName returns the enumeration called name (e.g. extent_extent_type)
+func (api *ArchivesSpaceAPI) GetEnumerationByName(ctx context.Context, name string) (*Enumeration, error) {
+	enums, err := api.ListEnumerations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, enum := range enums {
+		if enum.Name == name {
+			return enum, nil
+		}
+	}
+	return nil, fmt.Errorf("Can't find enumeration %q", name)
+}
+
+// AddEnumerationValues adds values to an editable enumeration
+func (api *ArchivesSpaceAPI) AddEnumerationValues(ctx context.Context, enumID int, values ...string) (*ResponseMsg, error) {
+	enum, err := api.GetEnumeration(ctx, enumID)
+	if err != nil {
+		return nil, err
+	}
+	if enum.Editable == false {
+		return nil, fmt.Errorf("Enumeration %s is not editable", enum.Name)
+	}
+	for _, value := range values {
+		if containsString(enum.Values, value) == false {
+			enum.Values = append(enum.Values, value)
+		}
+	}
+	// NOTE: ArchivesSpace updates the enumeration from values, the enumeration_values are read only
+	enum.EnumerationValues = nil
+	responseMsg, err := api.UpdateAPI(ctx, api.CallURL(enum.URI, nil), enum)
+	if err == nil {
+		api.ResetEnumerations()
+	}
+	return responseMsg, err
+}
+
+// GetEnumerationValue returns an enumeration value
+func (api *ArchivesSpaceAPI) GetEnumerationValue(ctx context.Context, valueID int) (*EnumerationValue, error) {
+	value := new(EnumerationValue)
+	u := api.CallURL(fmt.Sprintf("/config/enumeration_values/%d", valueID), nil)
+	if err := api.GetAPI(ctx, u, value); err != nil {
+		return nil, fmt.Errorf("GetEnumerationValue() %s, error, %w", u, err)
+	}
+	value.ID = URIToID(value.URI)
+	return value, nil
+}
+
+// PositionEnumerationValue moves an enumeration value to position (starting at 0)
+func (api *ArchivesSpaceAPI) PositionEnumerationValue(ctx context.Context, valueID, position int) (*ResponseMsg, error) {
+	q := url.Values{}
+	q.Set("position", strconv.Itoa(position))
+	return api.UpdateAPI(ctx, api.CallURL(fmt.Sprintf("/config/enumeration_values/%d/position", valueID), q), nil)
+}
+
+// SuppressEnumerationValue suppresses (or when suppressed is false restores) an
+// enumeration value so it is no longer offered for new records
+func (api *ArchivesSpaceAPI) SuppressEnumerationValue(ctx context.Context, valueID int, suppressed bool) (*ResponseMsg, error) {
+	q := url.Values{}
+	q.Set("suppressed", strconv.FormatBool(suppressed))
+	responseMsg, err := api.UpdateAPI(ctx, api.CallURL(fmt.Sprintf("/config/enumeration_values/%d/suppressed", valueID), q), nil)
+	if err == nil {
+		api.ResetEnumerations()
+	}
+	return responseMsg, err
+}
+
+// MigrateEnumerationValue merges the value from into the value to, records
+// using from are updated to to and from is removed from the enumeration
+func (api *ArchivesSpaceAPI) MigrateEnumerationValue(ctx context.Context, enumID int, from, to string) (*ResponseMsg, error) {
+	migration := &EnumerationMigration{
+		JSONModelType: "enumeration_migration",
+		EnumURI:       fmt.Sprintf("/config/enumerations/%d", enumID),
+		From:          from,
+		To:            to,
+	}
+	responseMsg, err := api.UpdateAPI(ctx, api.CallURL("/config/enumerations/migration", nil), migration)
+	if err == nil {
+		api.ResetEnumerations()
+	}
+	return responseMsg, err
+}
+
+// NewEnumerationTable returns a table of the unsuppressed values of enums
+func NewEnumerationTable(enums []*Enumeration) *EnumerationTable {
+	t := &EnumerationTable{values: map[string]map[string]bool{}}
+	for _, enum := range enums {
+		m := map[string]bool{}
+		if len(enum.EnumerationValues) > 0 {
+			for _, value := range enum.EnumerationValues {
+				if value.Suppressed == false {
+					m[value.Value] = true
+				}
+			}
+		} else {
+			for _, value := range enum.Values {
+				m[value] = true
+			}
+		}
+		t.values[enum.Name] = m
+	}
+	return t
+}
+
+// EnumerationTable returns the cached enumeration table fetching
+// the enumerations from ArchivesSpace the first time it is called
+func (api *ArchivesSpaceAPI) EnumerationTable(ctx context.Context) (*EnumerationTable, error) {
+	api.mu.RLock()
+	t := api.enums
+	api.mu.RUnlock()
+	if t != nil {
+		return t, nil
+	}
+	enums, err := api.ListEnumerations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t = NewEnumerationTable(enums)
+	api.mu.Lock()
+	api.enums = t
+	api.mu.Unlock()
+	return t, nil
+}
+
+// ResetEnumerations clears the cached enumeration table
+func (api *ArchivesSpaceAPI) ResetEnumerations() {
+	api.mu.Lock()
+	api.enums = nil
+	api.mu.Unlock()
+}
+
+// Has returns true if value is an unsuppressed value of the enumeration name.
+// Enumerations missing from the table are not checked.
+func (t *EnumerationTable) Has(name, value string) bool {
+	m, ok := t.values[name]
+	if ok == false {
+		return true
+	}
+	return m[value]
+}
+
+// check records an error for field if value isn't empty and isn't in enumeration name
+func (t *EnumerationTable) check(errs map[string][]string, field, name, value string) {
+	if value != "" && t.Has(name, value) == false {
+		errs[field] = append(errs[field], fmt.Sprintf("%q is not a value of %s", value, name))
+	}
+}
+
+func (t *EnumerationTable) checkExtents(errs map[string][]string, extents []*Extent) {
+	for i, extent := range extents {
+		t.check(errs, fmt.Sprintf("extents/%d/portion", i), "extent_portion", extent.Portion)
+		t.check(errs, fmt.Sprintf("extents/%d/extent_type", i), "extent_extent_type", extent.ExtentType)
+	}
+}
+
+func (t *EnumerationTable) checkDates(errs map[string][]string, dates []*Date) {
+	for i, date := range dates {
+		t.check(errs, fmt.Sprintf("dates/%d/date_type", i), "date_type", date.DateType)
+		t.check(errs, fmt.Sprintf("dates/%d/label", i), "date_label", date.Label)
+		t.check(errs, fmt.Sprintf("dates/%d/certainty", i), "date_certainty", date.Certainty)
+		t.check(errs, fmt.Sprintf("dates/%d/era", i), "date_era", date.Era)
+		t.check(errs, fmt.Sprintf("dates/%d/calendar", i), "date_calendar", date.Calendar)
+	}
+}
+
+func (t *EnumerationTable) checkInstances(errs map[string][]string, instances []*Instance) {
+	for i, instance := range instances {
+		t.check(errs, fmt.Sprintf("instances/%d/instance_type", i), "instance_instance_type", instance.InstanceType)
+	}
+}
+
+// Validate checks the controlled values of a record (e.g. *Accession,
+// *Resource, *ArchivalObject, *DigitalObject, *Event, *TopContainer)
+// returning a *ValidationError if any are not in their enumeration.
+// Other types are not checked.
+func (t *EnumerationTable) Validate(obj interface{}) error {
+	errs := map[string][]string{}
+	uri := ""
+	switch rec := obj.(type) {
+	case *Accession:
+		uri = rec.URI
+		t.check(errs, "resource_type", "accession_resource_type", rec.ResourceType)
+		t.check(errs, "acquisition_type", "accession_acquisition_type", rec.AcquisitionType)
+		t.checkExtents(errs, rec.Extents)
+		t.checkDates(errs, rec.Dates)
+	case *Resource:
+		uri = rec.URI
+		t.check(errs, "resource_type", "resource_resource_type", rec.ResourceType)
+		t.check(errs, "level", "archival_record_level", rec.Level)
+		t.check(errs, "finding_aid_status", "resource_finding_aid_status", rec.FindingAidStatus)
+		t.checkExtents(errs, rec.Extents)
+		t.checkDates(errs, rec.Dates)
+		t.checkInstances(errs, rec.Instances)
+	case *ArchivalObject:
+		uri = rec.URI
+		t.check(errs, "level", "archival_record_level", rec.Level)
+		t.checkExtents(errs, rec.Extents)
+		t.checkInstances(errs, rec.Instances)
+	case *DigitalObject:
+		uri = rec.URI
+		t.check(errs, "digital_object_type", "digital_object_digital_object_type", rec.DigitalObjectType)
+		t.check(errs, "level", "digital_object_level", rec.Level)
+		t.checkExtents(errs, rec.Extents)
+		t.checkDates(errs, rec.Dates)
+	case *Event:
+		uri = rec.URI
+		t.check(errs, "event_type", "event_event_type", rec.EventType)
+		t.check(errs, "outcome", "event_outcome", rec.Outcome)
+		if rec.Date != nil {
+			t.checkDates(errs, []*Date{rec.Date})
+		}
+	case *TopContainer:
+		uri = rec.URI
+		t.check(errs, "type", "container_type", rec.Type)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{URI: uri, Validation: errs}
+	}
+	return nil
+}
+
+// validate checks obj against the cached enumerations when ValidateEnumerations is true
+func (api *ArchivesSpaceAPI) validate(ctx context.Context, obj interface{}) error {
+	if api.ValidateEnumerations == false || obj == nil {
+		return nil
+	}
+	switch obj.(type) {
+	case *Accession, *Resource, *ArchivalObject, *DigitalObject, *Event, *TopContainer:
+	default:
+		return nil
+	}
+	t, err := api.EnumerationTable(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't validate record, %w", err)
+	}
+	return t.Validate(obj)
+}
@@ -0,0 +1,138 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// credentialParams are query parameters whose values are never reported in
+// errors or logs, e.g. the password CreateUser and UpdateUser send
+var credentialParams = []string{"password", "token", "session"}
+
+// redactURL returns u with the values of its credential query parameters
+// replaced by "REDACTED"
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		// Don't risk reporting a URL that can't be redacted
+		return strings.SplitN(u, "?", 2)[0]
+	}
+	q := parsed.Query()
+	redacted := false
+	for _, param := range credentialParams {
+		if _, ok := q[param]; ok == true {
+			q.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if redacted == false {
+		return u
+	}
+	parsed.RawQuery = q.Encode()
+	return parsed.String()
+}
+
+// APIError is returned when ArchivesSpace responds with a non-2xx HTTP status.
+// It is wrapped by the errors returned from the cait API methods so use
+// errors.As to recover it, e.g.
+//
+//	var apiErr *cait.APIError
+//	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+//	    // lock_version conflict, fetch the record and try again
+//	}
+type APIError struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Status     string `json:"status"`
+	// Message holds the error when ArchivesSpace returns it as a string
+	Message string `json:"message,omitempty"`
+	// Validation holds the field level errors from a ResponseMsg.Error map,
+	// e.g. {"title": ["Property is required but was missing"]}
+	Validation map[string][]string `json:"validation,omitempty"`
+	// Response is the decoded response body, nil if it wasn't JSON
+	Response *ResponseMsg `json:"response,omitempty"`
+}
+
+// newAPIError builds an APIError from a response and its body
+func newAPIError(method string, u string, res *http.Response, content []byte) *APIError {
+	apiErr := &APIError{
+		Method:     method,
+		URL:        redactURL(u),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+	}
+	msg := new(ResponseMsg)
+	if err := json.Unmarshal(content, msg); err != nil {
+		apiErr.Message = strings.TrimSpace(string(content))
+		return apiErr
+	}
+	apiErr.Response = msg
+	switch e := msg.Error.(type) {
+	case string:
+		apiErr.Message = e
+	case map[string]interface{}:
+		apiErr.Validation = make(map[string][]string)
+		for field, val := range e {
+			switch v := val.(type) {
+			case []interface{}:
+				for _, item := range v {
+					apiErr.Validation[field] = append(apiErr.Validation[field], fmt.Sprintf("%v", item))
+				}
+			default:
+				apiErr.Validation[field] = append(apiErr.Validation[field], fmt.Sprintf("%v", v))
+			}
+		}
+	case nil:
+	default:
+		apiErr.Message = fmt.Sprintf("%v", e)
+	}
+	return apiErr
+}
+
+// Error returns a description of the failed request
+func (e *APIError) Error() string {
+	s := fmt.Sprintf("ArchivesSpace API error %s, %s %s", e.Status, e.Method, e.URL)
+	if e.Message != "" {
+		s = fmt.Sprintf("%s, %s", s, e.Message)
+	}
+	if len(e.Validation) > 0 {
+		fields := make([]string, 0, len(e.Validation))
+		for field := range e.Validation {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			s = fmt.Sprintf("%s, %s: %s", s, field, strings.Join(e.Validation[field], "; "))
+		}
+	}
+	return s
+}
+
+// String return an APIError as a JSON formatted string
+func (e *APIError) String() string {
+	return stringify(e)
+}
@@ -0,0 +1,96 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// CreateEvent creates a new event in a Repository
+func (api *ArchivesSpaceAPI) CreateEvent(ctx context.Context, repoID int, event *Event) (*ResponseMsg, error) {
+	event.JSONModelType = "event"
+	event.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/events", repoID), nil), event)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	event.URI = responseMsg.URI
+	event.ID = responseMsg.ID
+	event.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetEvent retrieves an event from a Repository
+func (api *ArchivesSpaceAPI) GetEvent(ctx context.Context, repoID, eventID int) (*Event, error) {
+	event := new(Event)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/events/%d", repoID, eventID), nil)
+	if err := api.GetAPI(ctx, u, event); err != nil {
+		return nil, fmt.Errorf("GetEvent() %s, error, %w", u, err)
+	}
+	event.ID = URIToID(event.URI)
+	return event, nil
+}
+
+// UpdateEvent updates an existing event
+func (api *ArchivesSpaceAPI) UpdateEvent(ctx context.Context, event *Event) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(event.URI, nil), event)
+}
+
+// DeleteEvent deletes an event
+func (api *ArchivesSpaceAPI) DeleteEvent(ctx context.Context, event *Event) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(event.URI, nil), event)
+}
+
+// ListEvents returns a list of event ids in a Repository
+func (api *ArchivesSpaceAPI) ListEvents(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/events`, repoID), q))
+}
+
+// ResolveLinkedEvents returns the events referenced by a record's linked_events
+// (e.g. [{"ref": "/repositories/2/events/1"}]) in the order they are listed
+func (api *ArchivesSpaceAPI) ResolveLinkedEvents(ctx context.Context, linkedEvents []map[string]interface{}) ([]*Event, error) {
+	var events []*Event
+	for _, item := range linkedEvents {
+		ref := refString(item)
+		if ref == "" {
+			continue
+		}
+		event, err := api.GetEvent(ctx, URIToRepoID(ref), URIToID(ref))
+		if err != nil {
+			return nil, fmt.Errorf("Can't resolve event %s, %w", ref, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// GetAccessionEvents returns the events linked to an accession
+func (api *ArchivesSpaceAPI) GetAccessionEvents(ctx context.Context, accession *Accession) ([]*Event, error) {
+	return api.ResolveLinkedEvents(ctx, accession.LinkedEvents)
+}
+
+// GetResourceEvents returns the events linked to a resource
+func (api *ArchivesSpaceAPI) GetResourceEvents(ctx context.Context, resource *Resource) ([]*Event, error) {
+	return api.ResolveLinkedEvents(ctx, resource.LinkedEvents)
+}
@@ -1,36 +1,26 @@
 //
-// accessions-agent-roles.xlsx.js will crawls the accessions data
-// and build an Excel xlsx workbook of accession title, accession ids, agent name(s), agent role(s)
+// accessions-agent-roles.js crawls the accessions data and write
+// CSV of accession title, accession ids, agent name(s), agent role(s)
+// that can be opened as an Excel workbook, e.g.
+//
+//     cait run examples/accessions-agent-roles.js > accessions-agents-roles.csv
 //
 (function () {
     "use strict";
 
-    var workbookName = "accessions-agents-roles.xlsx",
-        rowCount = 0,
-        totalAccessionIDs = 0,
-        res = {},
-        workbook = {},
+    var res = {},
         accessionIDs = [];
 
-    // Setup empty workbook
-    workbook = {
-        "Accession Agents Roles": [
-            [
-                "Accession URI",
-                "Accession Title",
-                "Creator Agents URI",
-                "Creator Agents Names",
-                "Subject Agents URI",
-                "Subject Agents Names",
-                "Completed"
-            ]
-        ]
-    };
-
+    // quote a CSV field
+    function csv(s) {
+        if (s === undefined || s === null) {
+            s = "";
+        }
+        return '"' + String(s).replace(/"/g, '""') + '"';
+    }
 
-    function appendRow(wk, uri, title, ca_uri, ca_names, sa_uri, sa_names) {
-            var table = wk["Accession Agents Roles"];
-            table.push([uri, title, ca_uri, ca_names, sa_uri, sa_names, ""]);
+    function appendRow(uri, title, ca_uri, ca_names, sa_uri, sa_names) {
+            console.log([uri, title, ca_uri, ca_names, sa_uri, sa_names, ""].map(csv).join(","));
     }
 
     function show(obj) {
@@ -47,7 +37,7 @@
             sa_names = [];
 
         accession = api.getAccession(2, id);
-        uri = ["", "repostiories", 2, "accessions", id].join("/");
+        uri = ["", "repositories", 2, "accessions", id].join("/");
         title = accession.title;
         //show(accession);// DEBUG
         if (accession.linked_agents !== undefined) {
@@ -63,7 +53,8 @@
         }
         ca_uri.forEach(function (uri) {
             var parts = uri.split("/"),
-                id = 0;
+                id = 0,
+                agent = {};
             id = parts[(parts.length - 1)];
             agent = api.getAgent("people", id);
             if (agent.display_name !== undefined && agent.display_name.sort_name !== undefined) {
@@ -72,7 +63,8 @@
         })
         sa_uri.forEach(function (uri) {
             var parts = uri.split("/"),
-                id = 0;
+                id = 0,
+                agent = {};
             id = parts[(parts.length - 1)];
             agent = api.getAgent("people", id);
             if (agent.display_name !== undefined && agent.display_name.sort_name !== undefined) {
@@ -80,16 +72,10 @@
             }
         })
         if (ca_uri.length > 0 || sa_uri.length > 0) {
-            rowCount++;
-            appendRow(workbook, uri, title, ca_uri.join("; "), ca_names.join("; "), sa_uri.join("; "), sa_names.join("; "));
-        }
-        if ((i % 100) === 1) {
-            console.log("Processed", i, "/", totalAccessionIDs, "accessions,", rowCount, "roles found");
-            xlsx.write(workbookName, workbook);
+            appendRow(uri, title, ca_uri.join("; "), ca_names.join("; "), sa_uri.join("; "), sa_names.join("; "));
         }
     }
 
-    console.log("authenitcating", os.getEnv("CAIT_API_URL"));
     res = api.login();
     if (res.isAuth !== true) {
         console.log("Login failed");
@@ -97,12 +83,8 @@
     }
 
 
-    console.log("getting accession ids");
     accessionIDs = api.listAccessions(2);
-    totalAccessionIDs = accessionIDs.length;
-    console.log("Processed", 0, "/", totalAccessionIDs, "accessions,", rowCount, "roles found");
+    console.log(["Accession URI", "Accession Title", "Creator Agents URI", "Creator Agents Names",
+        "Subject Agents URI", "Subject Agents Names", "Completed"].map(csv).join(","));
     accessionIDs.forEach(addAccession);
-    console.log("Processed", accessionIDs.length, "/", totalAccessionIDs, "accessions,", rowCount, "roles found");
-    console.log("Writing out accessions-agent-roles.xlsx")
-    xlsx.write(workbookName, workbook);
 }());
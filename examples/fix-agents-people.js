@@ -12,7 +12,7 @@
 // It sets the publish property to true.
 //
 // Assumptions: we know the agents/peoples ID value and they will be provided via command line args
-// E.g. ./bin/caits -js examples/caitjs/fix-agents-peoples.js 101 202 333
+// E.g. cait run examples/fix-agents-people.js 101 202 333
 //
 
 // splitName takes a nameObject split's the primary_name at the comma into primary_name, rest_of_name properties
@@ -70,7 +70,7 @@ function fixAgentPerson(id) {
 
     args = os.args();
     if (args.length === 0) {
-        console.log("USAGE: caitjs fix-agent-peoples.js AGENT_ID");
+        console.log("USAGE: cait run fix-agents-people.js AGENT_ID");
         os.exit(1);
     }
     api.login();
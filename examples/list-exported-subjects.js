@@ -0,0 +1,23 @@
+//
+// List the titles of the subjects in the exported dataset (CAIT_DATASET)
+// without contacting ArchivesSpace. This script demonstrates.
+//
+// + dataset.collection(type)
+// + dataset.keys(collection)
+// + dataset.read(collection, key)
+//
+// E.g. cait run examples/list-exported-subjects.js
+//
+(function () {
+    "use strict";
+    var collection = dataset.collection("subject"),
+        keys = dataset.keys(collection);
+
+    console.log("Found", keys.length, "subjects in", os.getEnv("CAIT_DATASET") + "/" + collection);
+    keys.forEach(function (key) {
+        var subject = dataset.read(collection, key);
+        if (subject._deleted !== true) {
+            console.log(subject.uri, subject.title);
+        }
+    });
+}());
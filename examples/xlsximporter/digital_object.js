@@ -1,6 +1,12 @@
 /**
  * This is an example JavaScript file for importing Digital Objects from a
- * Excel spreadsheet with the following columns.
+ * Excel spreadsheet. Save the sheet as CSV and load it into a collection
+ * in CAIT_DATASET with the dataset command, one JSON object per row keyed
+ * by column name, then run
+ *
+ *     cait run examples/xlsximporter/digital_object.js COLLECTION
+ *
+ * The spreadsheet has the following columns.
  * + Digital Object ID
  * + Title (map to title)
  * + Series
@@ -11,7 +17,7 @@
  * + Oral History Text by Item ID::Search Text
  *
  * The Mapping follows these rules:
- * + Digital Object ID maps to URI with for /repositories/2/digital_obejcts/ID
+ * + Digital Object ID must be set, the created objects get new ids
  * + Title maps to title
  * + "Series" maps to subject of type function
  * + "Keywords" maps to subject of type topical
@@ -52,12 +58,9 @@ var now = new Date(),
     columnNames = [ cA, cB, cC, cD, cE, cF, cG, cH ],
     // Auth and API vars
     apiUsername = os.getEnv("CAIT_USERNAME"),
-    // Local data locations
-    dataDir = os.getEnv("CAIT_DATASETS"),
-    Subjects = {},
-    // You could start with object IDs at 4, but this may need to be changed
-    // if you have other Digital Objects already ingested.
-    ObjectIDOffset = 4;
+    // The collection holding the spreadsheet rows
+    rowsCollection = os.args()[0],
+    Subjects = {};
 
 //
 // Polyfills
@@ -224,7 +227,11 @@ function makeDigitalObjectID(onlineURL) {
 //
 sequenceNo = 0;
 // Make sure the environment varaibles are all set.
-["CAIT_API_URL", "CAIT_USERNAME", "CAIT_PASSWORD", "CAIT_DATASETS"].forEach(function (envvar) {
+if (rowsCollection === undefined) {
+    console.log("USAGE: cait run digital_object.js COLLECTION");
+    os.exit(1);
+}
+["CAIT_API_URL", "CAIT_USERNAME", "CAIT_PASSWORD", "CAIT_DATASET"].forEach(function (envvar) {
     var s = os.getEnv(envvar);
     if (s == "") {
         console.log("Missing", envvar);
@@ -238,7 +245,6 @@ if (response.error !== undefined) {
     os.exit(1);
 }
 console.log("Authenticated");
-console.log("Saving results to", dataDir);
 
 Subjects = getSubjects();
 
@@ -251,11 +257,11 @@ function callback(row) {
         obj = {};
 
     // If we are missing a value for our digital object id, then we have an error
-    if (row[cA] === undefined || row[cB] === "") {
-        return {path: "", object: "", error: "Missing " + cA}
+    if (row[cA] === undefined || row[cA] === "") {
+        return {object: "", error: "Missing " + cA}
     }
     if (row[cB] === undefined || row[cB].trim() === "") {
-        return {path: "", object: "", error: "Missing " + cB}
+        return {object: "", error: "Missing " + cB}
     }
 
     // Normalize the row fields, trim the strings
@@ -268,12 +274,8 @@ function callback(row) {
         }
     });
 
-    // Our spreadsheet uses row number as ID, but we have some existing
-    // Digital Objects so we're going to offset our new object IDs
-    objectID = parseInt(row[cA], 10) + ObjectIDOffset;
     obj = {
         digital_object_id: makeDigitalObjectID(row[cF]),
-        uri: "/repositories/2/digital_objects/" + objectID,
         title: row[cB],
         publish: true,
         subjects: [],
@@ -337,6 +339,23 @@ function callback(row) {
     if (subject != "") {
         obj.subjects.push({ref: subject});
     }
-    s = [dataDir, obj.uri, '.json'].join("");
-    return {path: s, object: obj, error: ""};
+    return {object: obj, error: ""};
 }
+
+//
+// Create a Digital Object for each row
+//
+dataset.keys(rowsCollection).forEach(function (key) {
+    var result = callback(dataset.read(rowsCollection, key));
+
+    if (result.error !== "") {
+        console.log("Skipping row", key, result.error);
+        return;
+    }
+    try {
+        response = api.createDigitalObject(repoID, result.object);
+        console.log("Created", response.uri, result.object.title);
+    } catch (err) {
+        console.log("Can't create row", key, err);
+    }
+});
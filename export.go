@@ -1,4 +1,3 @@
-//
 // Package cait is a collection of structures and functions
 // for interacting with ArchivesSpace's REST API
 //
@@ -16,375 +15,816 @@
 // 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
 //
 // THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
-//
 package cait
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"path"
+	"sync"
+	"time"
 )
 
 // ExportRepository for specific id to a JSON file.
-func (api *ArchivesSpaceAPI) ExportRepository(id int, fname string) error {
-	dir := "repository.ds"
+func (api *ArchivesSpaceAPI) ExportRepository(ctx context.Context, id int, fname string) error {
+	dir := api.Collection("repository")
 	c, err := CreateCollection(api, dir)
 	if err != nil {
-		return fmt.Errorf("Can't open collection %s/%s, %s", api.Dataset, dir, err)
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, dir, err)
 	}
 	defer c.Close()
 
-	data, err := api.GetRepository(id)
+	data, err := api.GetRepository(ctx, id)
 	if err != nil {
-		return fmt.Errorf("Can't get repository %d data, %s", id, err)
+		return fmt.Errorf("Can't get repository %d data, %w", id, err)
 	}
 	err = WriteJSON(c, fname, data)
 	if err != nil {
-		return fmt.Errorf("Can't write repository %d data, %s", id, err)
+		return fmt.Errorf("Can't write repository %d data, %w", id, err)
 	}
 	return nil
 }
 
 // ExportRepositories exports all repositories record to a JSON file by ID.
-func (api *ArchivesSpaceAPI) ExportRepositories(verbose bool) error {
-	ids, err := api.ListRepositoryIDs()
+func (api *ArchivesSpaceAPI) ExportRepositories(ctx context.Context, verbose bool) error {
+	ids, err := api.ListRepositoryIDs(ctx)
 	if err != nil {
-		return fmt.Errorf("Can't get list of repository ids, %s", err)
+		return fmt.Errorf("Can't get list of repository ids, %w", err)
 	}
+	report := new(ExportReport)
 	for i, id := range ids {
 		fname := fmt.Sprintf("%d.json", id)
-		err = api.ExportRepository(id, fname)
+		err = api.ExportRepository(ctx, id, fname)
 		if err != nil {
-			return fmt.Errorf("Can't export repository %d data, %s", id, err)
+			uri := fmt.Sprintf("/repositories/%d", id)
+			if err := api.exportFailed(report, api.Collection("repository"), uri, err); err != nil {
+				return fmt.Errorf("Can't export repository %d data, %w", id, err)
+			}
+			continue
 		}
 		if verbose == true && i > 0 && (i%100) == 0 {
 			log.Printf("%d repository definitions exported\n", i)
 		}
 	}
-	return nil
+	return report.asError()
 }
 
-// ExportAgents exports all agent records of a given type to JSON files by id.
-func (api *ArchivesSpaceAPI) ExportAgents(agentType string, verbose bool) error {
-	dir := path.Join("agents.ds", agentType)
-	c, err := CreateCollection(api, dir)
+// exportRecord is a fetched record ready to be written to a collection
+type exportRecord struct {
+	id    int
+	uri   string
+	mtime string
+	data  interface{}
+}
+
+// resultURI returns the uri of a JSON record, "" if it can't be decoded
+func resultURI(src []byte) string {
+	rec := struct {
+		URI string `json:"uri"`
+	}{}
+	json.Unmarshal(src, &rec)
+	return rec.URI
+}
+
+// exportSource describes a paged collection written by exportRecords
+type exportSource struct {
+	// dir is the collection name relative to api.Dataset
+	dir string
+	// label names the records in progress messages
+	label string
+	// path is the ArchivesSpace list path the pages are fetched from
+	path string
+	// decode returns the record for one JSON result from a page
+	decode func(src []byte) (*exportRecord, error)
+	// ids lists the record ids in ArchivesSpace, used to find deletions
+	ids func() ([]int, error)
+	// checkpoint is the ExportArchivesSpace checkpoint to resume from and
+	// update, nil when the collection is exported on its own
+	checkpoint *ExportCheckpoint
+}
+
+// exportRecords writes the records from src into its collection. The
+// first page is fetched to learn the total, the remaining pages are
+// fetched by up to api.ExportWorkers go routines. With the ExportContinue
+// policy records and pages that fail are collected in the returned
+// *ExportReport while the rest are written, ExportFailFast stops at the
+// first failure. When api.Incremental is set only records modified since the
+// saved high-water mark are fetched and records no longer in
+// ArchivesSpace are removed.
+func (api *ArchivesSpaceAPI) exportRecords(ctx context.Context, src *exportSource, verbose bool) error {
+	c, err := CreateCollection(api, src.dir)
 	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, src.dir, err)
 	}
 	defer c.Close()
-	ids, err := api.ListAgents(agentType)
-	if err != nil {
-		log.Fatalf("Can't get agent ids for %s, %s", agentType, err)
-	}
-	for i, id := range ids {
-		data, err := api.GetAgent(agentType, id)
-		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
-		}
-		fname := fmt.Sprintf("%d.json", id)
-		err = WriteJSON(c, fname, &data)
+
+	state := &ExportState{Collection: src.dir}
+	if api.Incremental == true {
+		state, err = api.ReadExportState(src.dir)
 		if err != nil {
-			return fmt.Errorf("Can't write %s/%s/%d.json, %s", dir, agentType, id, err)
-		}
-		if verbose == true && i > 0 && (i%100) == 0 {
-			log.Printf("%d agents/%s exported\n", i, agentType)
+			return err
 		}
 	}
-	return nil
-}
-
-// ExportAccessions exports all accessions by id to JSON files.
-func (api *ArchivesSpaceAPI) ExportAccessions(repoID int, verbose bool) error {
-	dir := fmt.Sprintf("repository-%d/accessions.ds", repoID)
-	c, err := CreateCollection(api, dir)
-	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
+	since, mark := state.Since(), state.SystemMTime
+	if verbose == true && since.IsZero() == false {
+		log.Printf("Exporting %s modified since %s\n", src.dir, state.SystemMTime)
 	}
-	defer c.Close()
+	report := new(ExportReport)
+	progress := &ExportProgress{Collection: src.dir, Started: time.Now().UTC()}
+	written := 0
 
-	ids, err := api.ListAccessions(repoID)
-	if err != nil {
-		return fmt.Errorf("Can't list accession ids from repository %d, %s", repoID, err)
-	}
-	if verbose == true {
-		log.Printf("Exporting %s\n", dir)
+	// A full export resumes after the last id checkpointed in order
+	startPage, cp := 1, src.checkpoint
+	if cp != nil && since.IsZero() == true {
+		if lastID := cp.LastID(src.dir); lastID > 0 {
+			ids, err := src.ids()
+			if err != nil {
+				return fmt.Errorf("Can't list ids for %s, %w", src.dir, err)
+			}
+			startPage = resumePage(ids, lastID, DefaultPageSize)
+			progress.Done = (startPage - 1) * DefaultPageSize
+			progress.Resumed = progress.Done
+			if verbose == true {
+				log.Printf("Resuming %s after id %d at page %d\n", src.dir, lastID, startPage)
+			}
+		}
 	}
-	for i, id := range ids {
-		data, err := api.GetAccession(repoID, id)
+	// pageLastIDs holds the last id of pages written out of order
+	nextPage, pageLastIDs := startPage, map[int]int{}
+
+	// mu guards c, state, report, progress, written, nextPage and pageLastIDs,
+	// reportMu keeps progress reports in sequence without holding mu
+	var mu, reportMu sync.Mutex
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return api.ExportPolicy == ExportFailFast && len(report.Failures) > 0
+	}
+	pageURI := func(page int) string {
+		return src.path + "?" + modifiedSinceQuery(page, DefaultPageSize, since).Encode()
+	}
+	// fetch writes the records of page, a failure listing the page is
+	// returned for the caller to record
+	fetch := func(page int) (*PageInfo, error) {
+		q := modifiedSinceQuery(page, DefaultPageSize, since)
+		rp, err := api.ListPageAPI(ctx, api.CallURL(src.path, q))
 		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
+			return nil, err
 		}
-		fname := fmt.Sprintf("%d.json", id)
-		err = WriteJSON(c, fname, &data)
-		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, id, err)
+		mu.Lock()
+		if progress.Total == 0 {
+			progress.Total = rp.Total
 		}
-		if verbose == true && i > 0 && (i%100) == 0 {
-			log.Printf("%d accessions exported from repository no. %d\n", i, repoID)
+		lastID := 0
+		for _, result := range rp.Results {
+			progress.Done++
+			uri := resultURI(result)
+			lastID = URIToID(uri)
+			rec, err := src.decode(result)
+			if err != nil {
+				report.add(src.dir, uri, fmt.Errorf("Can't decode %s, %w", uri, err))
+				progress.Failed++
+				continue
+			}
+			fname := fmt.Sprintf("%d.json", rec.id)
+			if err := WriteJSON(c, fname, rec.data); err != nil {
+				report.add(src.dir, rec.uri, fmt.Errorf("Can't write %s/%s, %w", src.dir, fname, err))
+				progress.Failed++
+				continue
+			}
+			state.Observe(rec.mtime)
+			written++
 		}
-	}
-	return nil
-}
+		if cp != nil && since.IsZero() == true && lastID > 0 {
+			pageLastIDs[page] = lastID
+			checkpointed := false
+			for id, ok := pageLastIDs[nextPage]; ok == true; id, ok = pageLastIDs[nextPage] {
+				cp.SetLastID(src.dir, id)
+				delete(pageLastIDs, nextPage)
+				nextPage++
+				checkpointed = true
+			}
+			if checkpointed == true {
+				if err := api.WriteExportCheckpoint(cp); err != nil {
+					report.add(src.dir, "", err)
+				}
+			}
+		}
+		snapshot := *progress
+		mu.Unlock()
 
-// ExportSubjects exports all subjects by id to JSON files.
-func (api *ArchivesSpaceAPI) ExportSubjects(verbose bool) error {
-	dir := "subjects.ds"
-	c, err := CreateCollection(api, dir)
-	if err != nil {
-		return fmt.Errorf("Can't open collection %s/%s, %s", api.Dataset, dir, err)
+		reportMu.Lock()
+		defer reportMu.Unlock()
+		api.reportProgress(&snapshot, src.label, verbose)
+		return &rp.PageInfo, nil
 	}
-	defer c.Close()
 
-	ids, err := api.ListSubjects()
+	info, err := fetch(startPage)
 	if err != nil {
-		return fmt.Errorf("Can't list subject ids, %s", err)
+		if err := api.exportFailed(report, src.dir, pageURI(startPage), err); err != nil {
+			return fmt.Errorf("Can't export %s, %w", src.dir, err)
+		}
+		// Without the first page the page count is unknown, the
+		// remaining pages are fetched by the next export
+		info = &PageInfo{ThisPage: startPage, LastPage: startPage}
+	}
+	pages := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < api.exportWorkers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range pages {
+				if stopped() == true {
+					continue
+				}
+				if _, err := fetch(page); err != nil {
+					// a failure stops ExportFailFast, see stopped()
+					mu.Lock()
+					report.add(src.dir, pageURI(page), err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for page := info.ThisPage + 1; page <= info.LastPage && ctx.Err() == nil && stopped() == false; page++ {
+		pages <- page
+	}
+	close(pages)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return fmt.Errorf("Can't export %s, %w", src.dir, ctx.Err())
+	}
+	if stopped() == true {
+		failure := report.Failures[0]
+		return fmt.Errorf("Can't export %s %s, %w", src.dir, failure.URI, failure.err)
 	}
-	for i, id := range ids {
-		data, err := api.GetSubject(id)
+
+	state.Written, state.Deleted = written, 0
+	if api.Incremental == true {
+		ids, err := src.ids()
 		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
+			return fmt.Errorf("Can't list ids for %s, %w", src.dir, err)
 		}
-		fname := fmt.Sprintf("%d.json", id)
-		err = WriteJSON(c, fname, &data)
+		state.Deleted, err = api.removeVanished(c, ids)
 		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, id, err)
+			return fmt.Errorf("Can't remove deleted records from %s, %w", src.dir, err)
 		}
-		if verbose == true && i > 0 && (i%100) == 0 {
-			log.Printf("%d subjects exported\n", i)
+		if verbose == true {
+			log.Printf("%d %s updated, %d removed\n", state.Written, src.label, state.Deleted)
 		}
 	}
-	return nil
+	if len(report.Failures) > 0 {
+		// Keep the old high-water mark so failed records are fetched again
+		state.SystemMTime = mark
+	}
+	state.Exported = progress.Started.Format(time.RFC3339)
+	if err := api.WriteExportState(state); err != nil {
+		return err
+	}
+	return report.asError()
+}
+
+// ExportAgents exports all agent records of a given type to JSON files by id.
+func (api *ArchivesSpaceAPI) ExportAgents(ctx context.Context, agentType string, verbose bool) error {
+	return api.exportAgents(ctx, agentType, nil, verbose)
+}
+
+// exportAgents is ExportAgents resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportAgents(ctx context.Context, agentType string, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("agent", agentType)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "agents/" + agentType,
+		path:       fmt.Sprintf(`/agents/%s`, agentType),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Agent)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListAgents(ctx, agentType)
+		},
+	}, verbose)
+}
+
+// ExportAccessions exports all accessions by id to JSON files, classification
+// refs are resolved to their record group hierarchy.
+func (api *ArchivesSpaceAPI) ExportAccessions(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportAccessions(ctx, repoID, nil, verbose)
+}
+
+// exportAccessions is ExportAccessions resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportAccessions(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("accession", repoID)
+	classifications, err := api.GetClassificationMap(ctx, repoID)
+	if err != nil {
+		return fmt.Errorf("Can't resolve classifications for %s, %w", dir, err)
+	}
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      fmt.Sprintf("accessions from repository no. %d", repoID),
+		path:       fmt.Sprintf(`/repositories/%d/accessions`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Accession)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			classifications.ResolveRefs(data.Classifications)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListAccessions(ctx, repoID)
+		},
+	}, verbose)
+}
+
+// ExportSubjects exports all subjects by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportSubjects(ctx context.Context, verbose bool) error {
+	return api.exportSubjects(ctx, nil, verbose)
+}
+
+// exportSubjects is ExportSubjects resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportSubjects(ctx context.Context, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("subject")
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "subjects",
+		path:       `/subjects`,
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Subject)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListSubjects(ctx)
+		},
+	}, verbose)
 }
 
 // ExportVocabularies exports all the vocabularies by ids to JSON files.
-func (api *ArchivesSpaceAPI) ExportVocabularies(verbose bool) error {
-	dir := "vocabularies.ds"
+func (api *ArchivesSpaceAPI) ExportVocabularies(ctx context.Context, verbose bool) error {
+	dir := api.Collection("vocabulary")
 	c, err := CreateCollection(api, dir)
 	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
+		return fmt.Errorf("Can't open collection %s, %w", api.Dataset, err)
 	}
 	defer c.Close()
-	ids, err := api.ListVocabularies()
+	ids, err := api.ListVocabularies(ctx)
 	if err != nil {
-		return fmt.Errorf("Can't list vocabulary ids, %s", err)
+		return fmt.Errorf("Can't list vocabulary ids, %w", err)
 	}
+	report := new(ExportReport)
 	for i, id := range ids {
-		data, err := api.GetVocabulary(id)
+		uri := fmt.Sprintf("/vocabularies/%d", id)
+		data, err := api.GetVocabulary(ctx, id)
 		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
+			if err := api.exportFailed(report, dir, uri, err); err != nil {
+				return fmt.Errorf("Can't get %s/%d, %w", dir, id, err)
+			}
+			continue
 		}
 		fname := fmt.Sprintf("%d.json", id)
 		err = WriteJSON(c, fname, &data)
 		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, id, err)
+			if err := api.exportFailed(report, dir, uri, err); err != nil {
+				return fmt.Errorf("Can't write %s/%d.json, %w", dir, id, err)
+			}
+			continue
 		}
 		if verbose == true && i > 0 && (i%100) == 0 {
 			log.Printf("%d vocabulary terms exported\n", i)
 		}
 	}
-	return nil
+	return report.asError()
 }
 
-func (api *ArchivesSpaceAPI) collectTerms(vocID int, verbose bool) error {
-	dir := path.Join(fmt.Sprintf("vocabulary-%d", vocID), "terms.ds")
+func (api *ArchivesSpaceAPI) collectTerms(ctx context.Context, vocID int, verbose bool) error {
+	dir := api.Collection("term", vocID)
 	c, err := CreateCollection(api, dir)
 	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
+		return fmt.Errorf("Can't open collection %s, %w", api.Dataset, err)
 	}
 	defer c.Close()
 
-	terms, err := api.ListTerms(vocID)
+	terms, err := api.ListTerms(ctx, vocID)
 	if err != nil {
-		return fmt.Errorf("Can't list term ids for %s, %s", dir, err)
+		return fmt.Errorf("Can't list term ids for %s, %w", dir, err)
 	}
+	report := new(ExportReport)
 	for i, term := range terms {
 		fname := fmt.Sprintf("%d.json", term.ID)
 		err = WriteJSON(c, fname, &term)
 		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, term.ID, err)
+			if err := api.exportFailed(report, dir, term.URI, err); err != nil {
+				return fmt.Errorf("Can't write %s/%d.json, %w", dir, term.ID, err)
+			}
+			continue
 		}
 		if verbose == true && i > 0 && (i%100) == 0 {
 			log.Printf("%d Vocabulary terms exported\n", i)
 		}
 	}
-	return nil
+	return report.asError()
 }
 
 // ExportTerms export all terms by voc id, term id to JSON files.
-func (api *ArchivesSpaceAPI) ExportTerms(verbose bool) error {
-	vocIDs, err := api.ListVocabularies()
+func (api *ArchivesSpaceAPI) ExportTerms(ctx context.Context, verbose bool) error {
+	vocIDs, err := api.ListVocabularies(ctx)
 	if err != nil {
-		return fmt.Errorf("Can't list vocabulary ids, %s", err)
+		return fmt.Errorf("Can't list vocabulary ids, %w", err)
 	}
 
+	report := new(ExportReport)
 	for i, vocID := range vocIDs {
-		err = api.collectTerms(vocID, verbose)
+		err = api.collectTerms(ctx, vocID, verbose)
 		if err != nil {
-			return err
+			if err := api.exportFailed(report, api.Collection("term", vocID), fmt.Sprintf("/vocabularies/%d/terms", vocID), err); err != nil {
+				return err
+			}
 		}
 		if verbose == true && i > 0 && (i%100) == 0 {
 			log.Printf("%d Vocabulary terms exported\n", i)
 		}
 	}
-	return nil
+	return report.asError()
 }
 
 // ExportLocations export all locations by id to JSON files.
-func (api *ArchivesSpaceAPI) ExportLocations(verbose bool) error {
-	dir := "locations.ds"
-	c, err := CreateCollection(api, dir)
-	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
-	}
-	defer c.Close()
+func (api *ArchivesSpaceAPI) ExportLocations(ctx context.Context, verbose bool) error {
+	return api.exportLocations(ctx, nil, verbose)
+}
 
-	ids, err := api.ListLocations()
-	if err != nil {
-		return fmt.Errorf("Can't list location ids, %s", err)
-	}
-	for i, id := range ids {
-		data, err := api.GetLocation(id)
-		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
-		}
-		fname := fmt.Sprintf("%d.json", id)
-		err = WriteJSON(c, fname, &data)
-		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, id, err)
-		}
-		if verbose == true && i > 0 && (i%100) == 0 {
-			log.Printf("%d locations exported\n", i)
-		}
-	}
-	return nil
+// exportLocations is ExportLocations resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportLocations(ctx context.Context, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("location")
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "locations",
+		path:       `/locations`,
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Location)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListLocations(ctx)
+		},
+	}, verbose)
 }
 
 // ExportDigitalObjects export all digital objects by id to JSON files.
-func (api *ArchivesSpaceAPI) ExportDigitalObjects(repoID int, verbose bool) error {
-	dir := path.Join(fmt.Sprintf("repository-%d", repoID), "digital_objects.ds")
-	c, err := CreateCollection(api, dir)
-	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
-	}
-	defer c.Close()
+func (api *ArchivesSpaceAPI) ExportDigitalObjects(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportDigitalObjects(ctx, repoID, nil, verbose)
+}
 
-	ids, err := api.ListDigitalObjects(repoID)
-	if err != nil {
-		return fmt.Errorf("Can't list digital_object ids, %s", err)
-	}
-	for i, id := range ids {
-		data, err := api.GetDigitalObject(repoID, id)
-		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
-		}
-		fname := fmt.Sprintf("%d.json", id)
-		err = WriteJSON(c, fname, &data)
-		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, id, err)
-		}
-		if verbose == true && i > 0 && (i%100) == 0 {
-			log.Printf("%d digital objects exported\n", i)
-		}
-	}
-	return nil
+// exportDigitalObjects is ExportDigitalObjects resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportDigitalObjects(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("digital_object", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "digital objects",
+		path:       fmt.Sprintf(`/repositories/%d/digital_objects`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(DigitalObject)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListDigitalObjects(ctx, repoID)
+		},
+	}, verbose)
 }
 
-// ExportResources export all resources by id to JSON files.
-func (api *ArchivesSpaceAPI) ExportResources(repoID int, verbose bool) error {
-	dir := path.Join(fmt.Sprintf("repository-%d", repoID), "resources.ds")
-	c, err := CreateCollection(api, dir)
-	if err != nil {
-		return fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
-	}
-	defer c.Close()
+// ExportResources export all resources by id to JSON files, classification
+// refs are resolved to their record group hierarchy.
+func (api *ArchivesSpaceAPI) ExportResources(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportResources(ctx, repoID, nil, verbose)
+}
 
-	ids, err := api.ListResources(repoID)
+// exportResources is ExportResources resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportResources(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("resource", repoID)
+	classifications, err := api.GetClassificationMap(ctx, repoID)
 	if err != nil {
-		return fmt.Errorf("Can't list resource ids, %s", err)
-	}
-	for i, id := range ids {
-		data, err := api.GetResource(repoID, id)
-		if err != nil {
-			return fmt.Errorf("Can't get %s/%d, %s", dir, id, err)
-		}
-		fname := fmt.Sprintf("%d.json", id)
-		err = WriteJSON(c, fname, &data)
-		if err != nil {
-			return fmt.Errorf("Can't write %s/%d.json, %s", dir, id, err)
-		}
-		if verbose == true && i > 0 && (i%100) == 0 {
-			log.Printf("%d resources exported\n", i)
-		}
-	}
-	return nil
+		return fmt.Errorf("Can't resolve classifications for %s, %w", dir, err)
+	}
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "resources",
+		path:       fmt.Sprintf(`/repositories/%d/resources`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Resource)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			classifications.ResolveRefs(data.Classifications)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListResources(ctx, repoID)
+		},
+	}, verbose)
 }
 
-// ExportArchivesSpace exports all content currently support by the Golang API implementation
-func (api *ArchivesSpaceAPI) ExportArchivesSpace(verbose bool) error {
-	var err error
+// ExportArchivalObjects export all archival objects by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportArchivalObjects(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportArchivalObjects(ctx, repoID, nil, verbose)
+}
 
-	log.Println("Exporting repositories")
-	err = api.ExportRepositories(verbose)
-	if err != nil {
-		return fmt.Errorf("Can't export repositories, %s", err)
-	}
+// exportArchivalObjects is ExportArchivalObjects resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportArchivalObjects(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("archival_object", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "archival objects",
+		path:       fmt.Sprintf(`/repositories/%d/archival_objects`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(ArchivalObject)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListArchivalObjects(ctx, repoID)
+		},
+	}, verbose)
+}
 
-	log.Printf("Exporting subjects\n")
-	err = api.ExportSubjects(verbose)
-	if err != nil {
-		return fmt.Errorf("Can't export subjects, %s", err)
-	}
+// ExportTopContainers export all top containers by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportTopContainers(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportTopContainers(ctx, repoID, nil, verbose)
+}
 
-	log.Printf("Exporting vocabularies\n")
-	err = api.ExportVocabularies(verbose)
-	if err != nil {
-		return fmt.Errorf("Can't export vocabularies, %s", err)
-	}
+// exportTopContainers is ExportTopContainers resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportTopContainers(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("top_container", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "top containers",
+		path:       fmt.Sprintf(`/repositories/%d/top_containers`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(TopContainer)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListTopContainers(ctx, repoID)
+		},
+	}, verbose)
+}
 
-	log.Printf("Exporting terms")
-	err = api.ExportTerms(verbose)
-	if err != nil {
-		return fmt.Errorf("Can't export terms, %s", err)
+// ExportEvents export all events by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportEvents(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportEvents(ctx, repoID, nil, verbose)
+}
+
+// exportEvents is ExportEvents resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportEvents(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("event", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "events",
+		path:       fmt.Sprintf(`/repositories/%d/events`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Event)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListEvents(ctx, repoID)
+		},
+	}, verbose)
+}
+
+// ExportClassifications export all classifications by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportClassifications(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportClassifications(ctx, repoID, nil, verbose)
+}
+
+// exportClassifications is ExportClassifications resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportClassifications(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("classification", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "classifications",
+		path:       fmt.Sprintf(`/repositories/%d/classifications`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(Classification)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListClassifications(ctx, repoID)
+		},
+	}, verbose)
+}
+
+// ExportClassificationTerms export all classification terms by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportClassificationTerms(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportClassificationTerms(ctx, repoID, nil, verbose)
+}
+
+// exportClassificationTerms is ExportClassificationTerms resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportClassificationTerms(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("classification_term", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "classification terms",
+		path:       fmt.Sprintf(`/repositories/%d/classification_terms`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(ClassificationTerm)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListClassificationTerms(ctx, repoID)
+		},
+	}, verbose)
+}
+
+// ExportDigitalObjectComponents export all digital object components by id to JSON files.
+func (api *ArchivesSpaceAPI) ExportDigitalObjectComponents(ctx context.Context, repoID int, verbose bool) error {
+	return api.exportDigitalObjectComponents(ctx, repoID, nil, verbose)
+}
+
+// exportDigitalObjectComponents is ExportDigitalObjectComponents resuming after the ids saved in cp, when cp isn't nil
+func (api *ArchivesSpaceAPI) exportDigitalObjectComponents(ctx context.Context, repoID int, cp *ExportCheckpoint, verbose bool) error {
+	dir := api.Collection("digital_object_component", repoID)
+	return api.exportRecords(ctx, &exportSource{
+		checkpoint: cp,
+		dir:        dir,
+		label:      "digital object components",
+		path:       fmt.Sprintf(`/repositories/%d/digital_object_components`, repoID),
+		decode: func(src []byte) (*exportRecord, error) {
+			data := new(DigitalObjectComponent)
+			if err := json.Unmarshal(src, data); err != nil {
+				return nil, err
+			}
+			data.ID = URIToID(data.URI)
+			return &exportRecord{id: data.ID, uri: data.URI, mtime: data.SystemMTime, data: data}, nil
+		},
+		ids: func() ([]int, error) {
+			return api.ListDigitalObjectComponents(ctx, repoID)
+		},
+	}, verbose)
+}
+
+// exportStage is one step of ExportArchivesSpace, name is the collection
+// (or group of collections) the stage writes.
+type exportStage struct {
+	name string
+	run  func() error
+}
+
+// exportStages returns the steps of ExportArchivesSpace in the order
+// they are run, paged collections resume from cp.
+func (api *ArchivesSpaceAPI) exportStages(ctx context.Context, cp *ExportCheckpoint, verbose bool) ([]*exportStage, error) {
+	stages := []*exportStage{
+		{"repositories", func() error { return api.ExportRepositories(ctx, verbose) }},
+		{api.Collection("subject"), func() error { return api.exportSubjects(ctx, cp, verbose) }},
+		{api.Collection("vocabulary"), func() error { return api.ExportVocabularies(ctx, verbose) }},
+		{"terms", func() error { return api.ExportTerms(ctx, verbose) }},
+		{api.Collection("location"), func() error { return api.exportLocations(ctx, cp, verbose) }},
+	}
+	for _, agentType := range AgentTypes {
+		agentType := agentType
+		stages = append(stages, &exportStage{api.Collection("agent", agentType), func() error {
+			return api.exportAgents(ctx, agentType, cp, verbose)
+		}})
 	}
 
-	log.Printf("Exporting locations")
-	err = api.ExportLocations(verbose)
+	ids, err := api.ListRepositoryIDs(ctx)
 	if err != nil {
-		return fmt.Errorf("Can't export locations, %s", err)
+		return nil, fmt.Errorf("Can't get a list of repository ids, %w", err)
 	}
+	for _, id := range ids {
+		repoID := id
+		stages = append(stages,
+			&exportStage{api.Collection("digital_object", repoID), func() error { return api.exportDigitalObjects(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("digital_object_component", repoID), func() error { return api.exportDigitalObjectComponents(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("resource", repoID), func() error { return api.exportResources(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("archival_object", repoID), func() error { return api.exportArchivalObjects(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("top_container", repoID), func() error { return api.exportTopContainers(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("event", repoID), func() error { return api.exportEvents(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("classification", repoID), func() error { return api.exportClassifications(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("classification_term", repoID), func() error { return api.exportClassificationTerms(ctx, repoID, cp, verbose) }},
+			&exportStage{api.Collection("accession", repoID), func() error { return api.exportAccessions(ctx, repoID, cp, verbose) }},
+		)
+	}
+	return stages, nil
+}
 
-	for _, agentType := range []string{"people", "corporate_entities", "families", "software"} {
-		log.Printf("Exporting agents.ds/%s\n", agentType)
-		err = api.ExportAgents(agentType, verbose)
+// ExportArchivesSpace exports all content currently support by the Golang API implementation.
+// With the ExportContinue policy records and stages that fail don't stop the
+// export, they are saved by SaveExportReport and returned together as an
+// *ExportReport once every stage has run. Progress is kept
+// in a checkpoint in the dataset, with api.Resume set an unfinished export
+// skips the stages it completed and continues partial collections.
+func (api *ArchivesSpaceAPI) ExportArchivesSpace(ctx context.Context, verbose bool) error {
+	cp, resumed := NewExportCheckpoint(), false
+	if api.Resume == true {
+		saved, err := api.ReadExportCheckpoint()
 		if err != nil {
-			return fmt.Errorf("Can't export agents, %s", err)
+			return err
+		}
+		if saved != nil && saved.Finished == "" {
+			cp, resumed = saved, true
+			log.Printf("Resuming export started %s, %d stages completed\n", cp.Started, len(cp.Completed))
 		}
 	}
-
-	ids, err := api.ListRepositoryIDs()
+	stages, err := api.exportStages(ctx, cp, verbose)
 	if err != nil {
-		return fmt.Errorf("Can't get a list of repository ids, %s", err)
+		return err
 	}
-	for _, id := range ids {
-		log.Printf("Exporting repositories/%d/digital_objects.ds\n", id)
-		err = api.ExportDigitalObjects(id, verbose)
-		if err != nil {
-			return fmt.Errorf("Can't export repositories/%d/digital_objects.ds, %s", id, err)
+	if err := api.WriteExportCheckpoint(cp); err != nil {
+		return err
+	}
+	if resumed == false {
+		if err := api.clearExportErrors(); err != nil {
+			return err
 		}
-		log.Printf("Exporting repositories/%d/resources\n", id)
-		err = api.ExportResources(id, verbose)
-		if err != nil {
-			return fmt.Errorf("Can't export repositories/%d/accessions.ds, %s", id, err)
+	}
+
+	report := new(ExportReport)
+	for _, stage := range stages {
+		if cp.IsCompleted(stage.name) == true {
+			log.Printf("Skipping %s, already exported\n", stage.name)
+			continue
 		}
-		log.Printf("Exporting repositories/%d/accessions.ds\n", id)
-		err = api.ExportAccessions(id, verbose)
-		if err != nil {
-			return fmt.Errorf("Can't export repositories/%d/accessions, %s", id, err)
+		log.Printf("Exporting %s\n", stage.name)
+		if err := stage.run(); err != nil {
+			if err := api.exportFailed(report, stage.name, "", err); err != nil {
+				return fmt.Errorf("Can't export %s, %w", stage.name, err)
+			}
+			continue
+		}
+		cp.Complete(stage.name)
+		if err := api.WriteExportCheckpoint(cp); err != nil {
+			return err
+		}
+	}
+	if len(report.Failures) == 0 {
+		cp.Finished = time.Now().UTC().Format(time.RFC3339)
+		if err := api.WriteExportCheckpoint(cp); err != nil {
+			return err
 		}
 	}
-	log.Printf("Export complete")
+	if verbose == true {
+		retries, relogins := api.RetryStats()
+		log.Printf("Export complete, %d retries, %d re-logins", retries, relogins)
+	} else {
+		log.Printf("Export complete")
+	}
 
 	//FIXME: Add other types as we start to use them
-	//FIXME: E.g. Resources, Extents, Instances, Group, Users
+	//FIXME: E.g. Extents, Instances, Group, Users
+	if len(report.Failures) > 0 {
+		log.Print(report.Summary())
+		if err := api.SaveExportReport(report); err != nil {
+			return err
+		}
+		return report
+	}
 	return nil
 }
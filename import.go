@@ -0,0 +1,476 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/dataset"
+)
+
+const (
+	// ImportURIsCollection holds the URIMapping of each imported record
+	ImportURIsCollection = "import_uris.ds"
+)
+
+// importSkipFields are assigned by the target ArchivesSpace when a record is created
+var importSkipFields = []string{
+	"id",
+	"uri",
+	"lock_version",
+	"create_time",
+	"system_mtime",
+	"user_mtime",
+	"created_by",
+	"last_modified_by",
+	"_deleted",
+	"_deleted_time",
+}
+
+// URIMapping records the URI the target ArchivesSpace assigned to an exported
+// record. Matched is set when an existing record (e.g. a repository with the
+// same repo_code) was used rather than a new one created. Unresolved lists the
+// refs which were dropped because they weren't imported.
+type URIMapping struct {
+	RecordType string   `json:"record_type"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Matched    bool     `json:"matched,omitempty"`
+	Unresolved []string `json:"unresolved,omitempty"`
+	Imported   string   `json:"imported"`
+}
+
+// URIMap maps the URIs of exported records to their URIs in the target
+type URIMap map[string]string
+
+// uriMappingKey maps an exported URI to its key in ImportURIsCollection
+func uriMappingKey(uri string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(uri, "/"), "/", "_") + ".json"
+}
+
+// Remap rewrites each "ref" and "uri" in rec to the URI it was imported as,
+// as well as other strings holding an imported URI (e.g. a subject's
+// "vocabulary"). Objects whose ref wasn't imported are removed, as are
+// unknown uris, and "_resolved" copies are dropped. The unresolved refs are
+// returned.
+func (uris URIMap) Remap(rec map[string]interface{}) []string {
+	unresolved := []string{}
+	uris.remap(rec, &unresolved)
+	sort.Strings(unresolved)
+	return unresolved
+}
+
+// remap returns v with its refs rewritten and false when v should be removed
+func (uris URIMap) remap(v interface{}, unresolved *[]string) (interface{}, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		delete(val, "_resolved")
+		if ref, ok := val["ref"].(string); ok == true {
+			to, ok := uris[ref]
+			if ok == false {
+				*unresolved = append(*unresolved, ref)
+				return nil, false
+			}
+			val["ref"] = to
+		}
+		if uri, ok := val["uri"].(string); ok == true {
+			if to, ok := uris[uri]; ok == true {
+				val["uri"] = to
+			} else {
+				delete(val, "uri")
+			}
+		}
+		for k, child := range val {
+			if k == "ref" || k == "uri" {
+				continue
+			}
+			if child, keep := uris.remap(child, unresolved); keep == true {
+				val[k] = child
+			} else {
+				delete(val, k)
+			}
+		}
+		return val, true
+	case []interface{}:
+		list := []interface{}{}
+		for _, child := range val {
+			if child, keep := uris.remap(child, unresolved); keep == true {
+				list = append(list, child)
+			}
+		}
+		return list, true
+	case string:
+		if to, ok := uris[val]; ok == true {
+			return to, true
+		}
+	}
+	return v, true
+}
+
+// ReadURIMap returns the mappings saved by previous ImportArchivesSpace runs
+func (api *ArchivesSpaceAPI) ReadURIMap() (URIMap, error) {
+	c, err := CreateCollection(api, ImportURIsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, ImportURIsCollection, err)
+	}
+	defer c.Close()
+	uris := URIMap{}
+	for _, key := range GetKeys(c) {
+		src, err := ReadJSON(c, key)
+		if err != nil {
+			return nil, fmt.Errorf("Can't read %s/%s, %w", ImportURIsCollection, key, err)
+		}
+		mapping := new(URIMapping)
+		if err := json.Unmarshal(src, mapping); err != nil {
+			return nil, fmt.Errorf("Can't decode %s/%s, %w", ImportURIsCollection, key, err)
+		}
+		uris[mapping.From] = mapping.To
+	}
+	return uris, nil
+}
+
+// importSource describes an exported collection recreated by importRecords
+type importSource struct {
+	// recordType names the records in the URI mappings and messages
+	recordType string
+	// dir is the exported collection relative to api.Dataset
+	dir string
+	// path is the target ArchivesSpace path the records are posted to
+	path string
+	// match returns the URI of an existing target record to use for rec, if any
+	match func(rec map[string]interface{}) string
+	// parent names the field referring to a record of the same collection
+	// which must be imported first (e.g. an archival object's parent)
+	parent string
+}
+
+// importer holds the state of an ImportArchivesSpace run
+type importer struct {
+	api      *ArchivesSpaceAPI
+	uris     URIMap
+	report   *ExportReport
+	verbose  bool
+	imported int
+}
+
+// collectionIDs returns the ids of the records in an exported collection
+func (api *ArchivesSpaceAPI) collectionIDs(dir string) ([]int, error) {
+	return datasetIDs(api.Dataset, dir)
+}
+
+// datasetIDs returns the ids of the records in the collection dir of the
+// dataset at root
+func datasetIDs(root, dir string) ([]int, error) {
+	c, err := dataset.Open(fmt.Sprintf("%s/%s", root, dir))
+	if err != nil {
+		return nil, fmt.Errorf("Can't open collection %s/%s, %w", root, dir, err)
+	}
+	defer c.Close()
+	ids := []int{}
+	for _, key := range GetKeys(c) {
+		if id, err := strconv.Atoi(strings.TrimSuffix(key, ".json")); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// importRecord creates rec in the target, or uses the record src.match
+// finds, and returns the mapping of its exported URI
+func (imp *importer) importRecord(ctx context.Context, src *importSource, from string, rec map[string]interface{}) (*URIMapping, error) {
+	mapping := &URIMapping{
+		RecordType: src.recordType,
+		From:       from,
+		Imported:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if src.match != nil {
+		if to := src.match(rec); to != "" {
+			mapping.To, mapping.Matched = to, true
+			return mapping, nil
+		}
+	}
+	for _, field := range importSkipFields {
+		delete(rec, field)
+	}
+	mapping.Unresolved = imp.uris.Remap(rec)
+	if imp.verbose == true && len(mapping.Unresolved) > 0 {
+		log.Printf("%s dropped refs to records not imported %s\n", from, strings.Join(mapping.Unresolved, ", "))
+	}
+	msg, err := imp.api.CreateAPI(ctx, imp.api.CallURL(src.path, nil), rec)
+	if err != nil {
+		return nil, err
+	}
+	if msg.URI == "" {
+		return nil, fmt.Errorf("%s created without a uri, %+v", from, msg)
+	}
+	mapping.To = msg.URI
+	return mapping, nil
+}
+
+// importRecords recreates the records in src.dir in id order. Records
+// already in the URI map are skipped so an interrupted import can be run
+// again.
+func (imp *importer) importRecords(ctx context.Context, src *importSource) error {
+	api := imp.api
+	ids, err := api.collectionIDs(src.dir)
+	if err != nil {
+		return err
+	}
+	c, err := OpenCollection(api, src.dir)
+	if err != nil {
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, src.dir, err)
+	}
+	defer c.Close()
+	m, err := CreateCollection(api, ImportURIsCollection)
+	if err != nil {
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, ImportURIsCollection, err)
+	}
+	defer m.Close()
+
+	if src.parent != "" {
+		ids = imp.parentOrder(c, src, ids)
+	}
+	for _, id := range ids {
+		fname := fmt.Sprintf("%d.json", id)
+		uri := fmt.Sprintf("%s/%d", src.dir, id)
+		data, err := ReadJSON(c, fname)
+		if err != nil {
+			if err := api.exportFailed(imp.report, src.dir, uri, err); err != nil {
+				return fmt.Errorf("Can't read %s/%s, %w", src.dir, fname, err)
+			}
+			continue
+		}
+		rec := map[string]interface{}{}
+		if err := json.Unmarshal(data, &rec); err != nil {
+			if err := api.exportFailed(imp.report, src.dir, uri, err); err != nil {
+				return fmt.Errorf("Can't decode %s/%s, %w", src.dir, fname, err)
+			}
+			continue
+		}
+		if from, ok := rec["uri"].(string); ok == true && from != "" {
+			uri = from
+		}
+		if _, ok := imp.uris[uri]; ok == true {
+			continue
+		}
+		mapping, err := imp.importRecord(ctx, src, uri, rec)
+		if err != nil {
+			if err := api.exportFailed(imp.report, src.dir, uri, err); err != nil {
+				return fmt.Errorf("Can't import %s, %w", uri, err)
+			}
+			continue
+		}
+		if err := WriteJSON(m, uriMappingKey(uri), mapping); err != nil {
+			return fmt.Errorf("Can't save the mapping of %s, %w", uri, err)
+		}
+		imp.uris[uri] = mapping.To
+		imp.imported++
+		if imp.verbose == true && (imp.imported%100) == 0 {
+			log.Printf("%d records imported\n", imp.imported)
+		}
+	}
+	return nil
+}
+
+// parentOrder returns ids ordered so each record comes after the record
+// its src.parent field refers to, otherwise in id order. Records which
+// can't be read are left in place for importRecords to report.
+func (imp *importer) parentOrder(c *dataset.Collection, src *importSource, ids []int) []int {
+	parents := map[int]int{}
+	for _, id := range ids {
+		data, err := ReadJSON(c, fmt.Sprintf("%d.json", id))
+		if err != nil {
+			continue
+		}
+		rec := map[string]interface{}{}
+		if json.Unmarshal(data, &rec) != nil {
+			continue
+		}
+		if parent, ok := rec[src.parent].(map[string]interface{}); ok == true {
+			if ref := refString(parent); ref != "" {
+				parents[id] = URIToID(ref)
+			}
+		}
+	}
+	known := map[int]bool{}
+	for _, id := range ids {
+		known[id] = true
+	}
+	ordered, seen := []int{}, map[int]bool{}
+	var visit func(id int)
+	visit = func(id int) {
+		if seen[id] == true {
+			return
+		}
+		seen[id] = true
+		if parent, ok := parents[id]; ok == true && known[parent] == true {
+			visit(parent)
+		}
+		ordered = append(ordered, id)
+	}
+	for _, id := range ids {
+		visit(id)
+	}
+	return ordered
+}
+
+// importRepositoryTypes are the repository records imported, in dependency
+// order. Top containers come before the records whose instances refer to
+// them, components and archival objects after the records they belong to.
+var importRepositoryTypes = []string{
+	"top_container",
+	"digital_object",
+	"digital_object_component",
+	"resource",
+	"archival_object",
+	"accession",
+}
+
+// importParentFields name the field of a repository record type referring
+// to its parent in the same collection
+var importParentFields = map[string]string{
+	"digital_object_component": "parent",
+	"archival_object":          "parent",
+}
+
+// ImportArchivesSpace recreates the records written by ExportArchivesSpace
+// in the ArchivesSpace at api.BaseURL. Repositories, vocabularies, terms,
+// subjects, locations, agents, then each repository's top containers,
+// digital objects and their components, resources, archival objects (parents
+// first) and accessions are created so refs can be rewritten to the URIs
+// the target assigns. Repositories with the same repo_code and
+// vocabularies with the same ref_id are reused. Each mapping is saved in
+// ImportURIsCollection, records already mapped are skipped when the import
+// is run again. Failures follow api.ExportPolicy and are returned as an
+// *ExportReport.
+func (api *ArchivesSpaceAPI) ImportArchivesSpace(ctx context.Context, verbose bool) error {
+	uris, err := api.ReadURIMap()
+	if err != nil {
+		return err
+	}
+	if len(uris) > 0 {
+		log.Printf("Resuming import, %d records already imported\n", len(uris))
+	} else if err := api.clearErrors(ImportErrorsCollection); err != nil {
+		return err
+	}
+	imp := &importer{
+		api:     api,
+		uris:    uris,
+		report:  &ExportReport{imported: true},
+		verbose: verbose,
+	}
+
+	repoCodes, err := api.repositoryCodes(ctx)
+	if err != nil {
+		return err
+	}
+	refIDs, err := api.vocabularyRefIDs(ctx)
+	if err != nil {
+		return err
+	}
+	matchField := func(field string, existing map[string]string) func(map[string]interface{}) string {
+		return func(rec map[string]interface{}) string {
+			if s, ok := rec[field].(string); ok == true && s != "" {
+				return existing[s]
+			}
+			return ""
+		}
+	}
+
+	sources := []*importSource{
+		{recordType: "repository", dir: api.Collection("repository"), path: "/repositories", match: matchField("repo_code", repoCodes)},
+		{recordType: "vocabulary", dir: api.Collection("vocabulary"), path: "/vocabularies", match: matchField("ref_id", refIDs)},
+	}
+	if err := imp.run(ctx, sources); err != nil {
+		return err
+	}
+
+	// Terms, then the records which refer to them
+	sources = []*importSource{}
+	oldVocIDs, _ := api.collectionIDs(api.Collection("vocabulary"))
+	for _, vocID := range oldVocIDs {
+		if to, ok := uris[fmt.Sprintf("/vocabularies/%d", vocID)]; ok == true {
+			sources = append(sources, &importSource{recordType: "term", dir: api.Collection("term", vocID), path: to + "/terms"})
+		}
+	}
+	sources = append(sources,
+		&importSource{recordType: "subject", dir: api.Collection("subject"), path: "/subjects"},
+		&importSource{recordType: "location", dir: api.Collection("location"), path: "/locations"},
+	)
+	for _, agentType := range AgentTypes {
+		sources = append(sources, &importSource{recordType: "agent", dir: api.Collection("agent", agentType), path: "/agents/" + agentType})
+	}
+	oldRepoIDs, _ := api.collectionIDs(api.Collection("repository"))
+	for _, repoID := range oldRepoIDs {
+		to, ok := uris[fmt.Sprintf("/repositories/%d", repoID)]
+		if ok == false {
+			continue
+		}
+		for _, recordType := range importRepositoryTypes {
+			sources = append(sources, &importSource{
+				recordType: recordType,
+				dir:        api.Collection(recordType, repoID),
+				path:       to + "/" + recordType + "s",
+				parent:     importParentFields[recordType],
+			})
+		}
+	}
+	if err := imp.run(ctx, sources); err != nil {
+		return err
+	}
+
+	log.Printf("Import complete, %d records imported\n", imp.imported)
+	if len(imp.report.Failures) > 0 {
+		log.Print(imp.report.Summary())
+		if err := api.SaveExportReport(imp.report); err != nil {
+			return err
+		}
+		return imp.report
+	}
+	return nil
+}
+
+// run imports each source, an exported collection which is missing is skipped
+func (imp *importer) run(ctx context.Context, sources []*importSource) error {
+	for _, src := range sources {
+		c, err := OpenCollection(imp.api, src.dir)
+		if err != nil {
+			if imp.verbose == true {
+				log.Printf("Skipping %s, %s\n", src.dir, err)
+			}
+			continue
+		}
+		c.Close()
+		log.Printf("Importing %s\n", src.dir)
+		if err := imp.importRecords(ctx, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
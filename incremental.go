@@ -0,0 +1,184 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/dataset"
+)
+
+const (
+	// ExportStateCollection holds the per collection high-water marks
+	// used by incremental exports
+	ExportStateCollection = "export_state.ds"
+)
+
+// ExportState records what the last export wrote to a collection. SystemMTime
+// is the high-water mark, the newest system_mtime seen, an incremental export
+// only fetches records modified since then.
+type ExportState struct {
+	Collection  string `json:"collection"`
+	SystemMTime string `json:"system_mtime,omitempty"`
+	Exported    string `json:"exported,omitempty"`
+	Written     int    `json:"written"`
+	Deleted     int    `json:"deleted"`
+}
+
+// Since returns the high-water mark as a time, the zero time if none
+// has been recorded.
+func (state *ExportState) Since() time.Time {
+	if state == nil || state.SystemMTime == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, state.SystemMTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Observe raises the high-water mark to mtime when it is newer
+func (state *ExportState) Observe(mtime string) {
+	t, err := time.Parse(time.RFC3339, mtime)
+	if err != nil {
+		return
+	}
+	if state.SystemMTime == "" || t.After(state.Since()) {
+		state.SystemMTime = mtime
+	}
+}
+
+// exportStateKey maps a collection name to its key in ExportStateCollection
+func exportStateKey(dir string) string {
+	return strings.ReplaceAll(dir, "/", "_") + ".json"
+}
+
+// ReadExportState returns the saved state for a collection, a new state
+// is returned if the collection hasn't been exported before.
+func (api *ArchivesSpaceAPI) ReadExportState(dir string) (*ExportState, error) {
+	state := &ExportState{Collection: dir}
+	c, err := CreateCollection(api, ExportStateCollection)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, ExportStateCollection, err)
+	}
+	defer c.Close()
+	key := exportStateKey(dir)
+	if c.HasKey(key) == false {
+		return state, nil
+	}
+	src, err := ReadJSON(c, key)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read export state for %s, %w", dir, err)
+	}
+	if len(src) > 0 {
+		if err := json.Unmarshal(src, state); err != nil {
+			return nil, fmt.Errorf("Can't decode export state for %s, %w", dir, err)
+		}
+	}
+	return state, nil
+}
+
+// WriteExportState saves the state for the next incremental export
+func (api *ArchivesSpaceAPI) WriteExportState(state *ExportState) error {
+	c, err := CreateCollection(api, ExportStateCollection)
+	if err != nil {
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, ExportStateCollection, err)
+	}
+	defer c.Close()
+	if err := WriteJSON(c, exportStateKey(state.Collection), state); err != nil {
+		return fmt.Errorf("Can't write export state for %s, %w", state.Collection, err)
+	}
+	return nil
+}
+
+// VanishedKeys returns the record keys ("ID.json") in keys whose ID is
+// no longer in ids, the list returned by ArchivesSpace. Keys that aren't
+// record keys are ignored.
+func VanishedKeys(keys []string, ids []int) []string {
+	current := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		current[id] = true
+	}
+	vanished := []string{}
+	for _, key := range keys {
+		id, err := strconv.Atoi(strings.TrimSuffix(key, ".json"))
+		if err != nil || strings.HasSuffix(key, ".json") == false {
+			continue
+		}
+		if current[id] == false {
+			vanished = append(vanished, key)
+		}
+	}
+	sort.Strings(vanished)
+	return vanished
+}
+
+// IsDeleted reports if an exported record was marked deleted by an
+// incremental export with MarkDeleted set.
+func IsDeleted(src []byte) bool {
+	rec := struct {
+		Deleted bool `json:"_deleted"`
+	}{}
+	if err := json.Unmarshal(src, &rec); err != nil {
+		return false
+	}
+	return rec.Deleted
+}
+
+// removeVanished removes (or marks when api.MarkDeleted is set) the
+// records in c which are no longer in ids, it returns the number of
+// records removed or newly marked.
+func (api *ArchivesSpaceAPI) removeVanished(c *dataset.Collection, ids []int) (int, error) {
+	cnt := 0
+	for _, key := range VanishedKeys(GetKeys(c), ids) {
+		if api.MarkDeleted == false {
+			if err := c.Delete(key); err != nil {
+				return cnt, fmt.Errorf("Can't remove %s, %w", key, err)
+			}
+			cnt++
+			continue
+		}
+		src, err := ReadJSON(c, key)
+		if err != nil {
+			return cnt, fmt.Errorf("Can't read %s, %w", key, err)
+		}
+		if IsDeleted(src) == true {
+			continue
+		}
+		rec := map[string]interface{}{}
+		if err := json.Unmarshal(src, &rec); err != nil {
+			return cnt, fmt.Errorf("Can't decode %s, %w", key, err)
+		}
+		rec["_deleted"] = true
+		rec["_deleted_time"] = time.Now().UTC().Format(time.RFC3339)
+		if err := WriteJSON(c, key, rec); err != nil {
+			return cnt, fmt.Errorf("Can't mark %s deleted, %w", key, err)
+		}
+		cnt++
+	}
+	return cnt, nil
+}
@@ -64,7 +64,8 @@ func ReadJSON(c *dataset.Collection, fname string) ([]byte, error) {
 	return c.ReadJSON(fname)
 }
 
-// WriteJSON write out an ArchivesSpace data structure as a JSON file.
+// WriteJSON write out an ArchivesSpace data structure as a JSON file,
+// an existing record with the same key is replaced.
 func WriteJSON(c *dataset.Collection, fname string, data interface{}) error {
 	// dir is the name of the collection
 	// fname is the key in the collection
@@ -72,7 +73,11 @@ func WriteJSON(c *dataset.Collection, fname string, data interface{}) error {
 	if err != nil {
 		return fmt.Errorf("WriteJSON(c, %q, data) -> JSON encode, %s", fname, err)
 	}
-	err = c.CreateJSON(fname, src)
+	if c.HasKey(fname) == true {
+		err = c.UpdateJSON(fname, src)
+	} else {
+		err = c.CreateJSON(fname, src)
+	}
 	if err != nil {
 		return fmt.Errorf("Could not write JSON data, %s, %s", fname, err)
 	}
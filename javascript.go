@@ -0,0 +1,349 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	// 3rd Party packages
+	"github.com/robertkrimen/otto"
+)
+
+// jsRecordType describes the api.getX(), api.listXs(), api.createX(),
+// api.updateX() and api.deleteX() functions of a record type. path is the
+// list path, each %s is filled from a leading argument (e.g. the repository id).
+type jsRecordType struct {
+	name   string
+	plural string
+	path   string
+}
+
+// jsRecordTypes are the records scripts can work with
+var jsRecordTypes = []*jsRecordType{
+	{name: "Repository", plural: "Repositories", path: "/repositories"},
+	{name: "Agent", plural: "Agents", path: "/agents/%s"},
+	{name: "Subject", plural: "Subjects", path: "/subjects"},
+	{name: "Location", plural: "Locations", path: "/locations"},
+	{name: "Vocabulary", plural: "Vocabularies", path: "/vocabularies"},
+	{name: "Term", plural: "Terms", path: "/vocabularies/%s/terms"},
+	{name: "Accession", plural: "Accessions", path: "/repositories/%s/accessions"},
+	{name: "DigitalObject", plural: "DigitalObjects", path: "/repositories/%s/digital_objects"},
+	{name: "DigitalObjectComponent", plural: "DigitalObjectComponents", path: "/repositories/%s/digital_object_components"},
+	{name: "Resource", plural: "Resources", path: "/repositories/%s/resources"},
+	{name: "ArchivalObject", plural: "ArchivalObjects", path: "/repositories/%s/archival_objects"},
+	{name: "TopContainer", plural: "TopContainers", path: "/repositories/%s/top_containers"},
+	{name: "Event", plural: "Events", path: "/repositories/%s/events"},
+	{name: "Classification", plural: "Classifications", path: "/repositories/%s/classifications"},
+	{name: "ClassificationTerm", plural: "ClassificationTerms", path: "/repositories/%s/classification_terms"},
+}
+
+// jsExit records the code passed to os.exit(), exited is set once it is called
+type jsExit struct {
+	code   int
+	exited bool
+}
+
+// halt stops vm before its next statement. The interrupt queues itself
+// again each time it runs so a try block in the script can't catch the
+// exit and carry on.
+func (exit *jsExit) halt(vm *otto.Otto) {
+	var halt func()
+	halt = func() {
+		vm.Interrupt <- halt
+		panic(exit)
+	}
+	vm.Interrupt = make(chan func(), 1)
+	vm.Interrupt <- halt
+}
+
+// jsThrow stops the running script with a JavaScript error describing err
+func jsThrow(call otto.FunctionCall, err error) {
+	panic(call.Otto.MakeCustomError("ArchivesSpaceError", err.Error()))
+}
+
+// toJS converts a Go value to a plain JavaScript value via JSON so scripts
+// can change it like any other object
+func toJS(call otto.FunctionCall, data interface{}) otto.Value {
+	src, err := json.Marshal(data)
+	if err != nil {
+		jsThrow(call, err)
+	}
+	val, err := call.Otto.Call("JSON.parse", nil, string(src))
+	if err != nil {
+		jsThrow(call, err)
+	}
+	return val
+}
+
+// fromJS returns the JSON encoding of a JavaScript value
+func fromJS(call otto.FunctionCall, val otto.Value) []byte {
+	src, err := call.Otto.Call("JSON.stringify", nil, val)
+	if err != nil {
+		jsThrow(call, err)
+	}
+	return []byte(src.String())
+}
+
+// jsPath fills the %s in p from the first arguments of call
+func jsPath(call otto.FunctionCall, p string) string {
+	args := []interface{}{}
+	for i := 0; i < strings.Count(p, "%s"); i++ {
+		args = append(args, url.PathEscape(call.Argument(i).String()))
+	}
+	return fmt.Sprintf(p, args...)
+}
+
+// jsURI returns the uri of the record passed as argument i
+func jsURI(call otto.FunctionCall, i int) string {
+	rec := struct {
+		URI string `json:"uri"`
+	}{}
+	if err := json.Unmarshal(fromJS(call, call.Argument(i)), &rec); err != nil || rec.URI == "" {
+		jsThrow(call, fmt.Errorf("the record has no uri"))
+	}
+	return rec.URI
+}
+
+// listIDs returns the ids of the records at the list path p
+func (api *ArchivesSpaceAPI) listIDs(ctx context.Context, p string) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	// Some lists (e.g. repositories) return records rather than ids
+	results := []interface{}{}
+	if err := api.GetAPI(ctx, api.CallURL(p, q), &results); err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for _, result := range results {
+		switch val := result.(type) {
+		case float64:
+			ids = append(ids, int(val))
+		case map[string]interface{}:
+			if uri, ok := val["uri"].(string); ok == true {
+				ids = append(ids, URIToID(uri))
+			}
+		}
+	}
+	return ids, nil
+}
+
+// bindRecordType adds the functions for t to obj
+func (api *ArchivesSpaceAPI) bindRecordType(ctx context.Context, obj *otto.Object, t *jsRecordType) {
+	n := strings.Count(t.path, "%s")
+	obj.Set("get"+t.name, func(call otto.FunctionCall) otto.Value {
+		p := fmt.Sprintf("%s/%s", jsPath(call, t.path), url.PathEscape(call.Argument(n).String()))
+		data := map[string]interface{}{}
+		if err := api.GetAPI(ctx, api.CallURL(p, nil), &data); err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, data)
+	})
+	obj.Set("list"+t.plural, func(call otto.FunctionCall) otto.Value {
+		ids, err := api.listIDs(ctx, jsPath(call, t.path))
+		if err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, ids)
+	})
+	obj.Set("create"+t.name, func(call otto.FunctionCall) otto.Value {
+		msg, err := api.CreateAPI(ctx, api.CallURL(jsPath(call, t.path), nil), json.RawMessage(fromJS(call, call.Argument(n))))
+		if err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, msg)
+	})
+	obj.Set("update"+t.name, func(call otto.FunctionCall) otto.Value {
+		msg, err := api.UpdateAPI(ctx, api.CallURL(jsURI(call, 0), nil), json.RawMessage(fromJS(call, call.Argument(0))))
+		if err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, msg)
+	})
+	obj.Set("delete"+t.name, func(call otto.FunctionCall) otto.Value {
+		msg, err := api.DeleteAPI(ctx, api.CallURL(jsURI(call, 0), nil), nil)
+		if err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, msg)
+	})
+}
+
+// NewJSRuntime returns a JavaScript interpreter with the objects curation
+// scripts expect. api has login(), logout(), get(uri), search(repoID, q)
+// and getX(), listXs(), createX(), updateX(), deleteX() for each record
+// type (e.g. api.getAccession(2, 10), api.updateAccession(accession)).
+// os has getEnv(name), args() and exit(code), dataset has
+// collection(type, ...), keys(collection) and read(collection, key) for
+// the exported records in api.Dataset. API errors are thrown as JavaScript
+// errors.
+func (api *ArchivesSpaceAPI) NewJSRuntime(ctx context.Context, args []string) (*otto.Otto, error) {
+	vm, _, err := api.newJSRuntime(ctx, args)
+	return vm, err
+}
+
+// newJSRuntime is NewJSRuntime, the returned *jsExit is set by os.exit()
+func (api *ArchivesSpaceAPI) newJSRuntime(ctx context.Context, args []string) (*otto.Otto, *jsExit, error) {
+	vm, exit := otto.New(), new(jsExit)
+
+	apiObj, err := vm.Object(`({})`)
+	if err != nil {
+		return nil, nil, err
+	}
+	vm.Set("api", apiObj)
+	apiObj.Set("login", func(call otto.FunctionCall) otto.Value {
+		resp := map[string]interface{}{"isAuth": false}
+		if err := api.Login(ctx); err != nil {
+			resp["error"] = err.Error()
+		} else {
+			resp["isAuth"] = api.IsAuth()
+		}
+		return toJS(call, resp)
+	})
+	apiObj.Set("logout", func(call otto.FunctionCall) otto.Value {
+		resp := map[string]interface{}{"isAuth": false}
+		if err := api.Logout(ctx); err != nil {
+			resp["error"] = err.Error()
+		}
+		return toJS(call, resp)
+	})
+	apiObj.Set("get", func(call otto.FunctionCall) otto.Value {
+		var data interface{}
+		if err := api.GetAPI(ctx, api.CallURL(call.Argument(0).String(), nil), &data); err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, data)
+	})
+	apiObj.Set("search", func(call otto.FunctionCall) otto.Value {
+		repoID, _ := call.Argument(0).ToInteger()
+		opts := new(SearchOptions)
+		if err := json.Unmarshal(fromJS(call, call.Argument(1)), opts); err != nil {
+			jsThrow(call, err)
+		}
+		results, err := api.Search(ctx, int(repoID), opts)
+		if err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, results)
+	})
+	for _, t := range jsRecordTypes {
+		api.bindRecordType(ctx, apiObj, t)
+	}
+
+	osObj, err := vm.Object(`({})`)
+	if err != nil {
+		return nil, nil, err
+	}
+	vm.Set("os", osObj)
+	osObj.Set("getEnv", func(call otto.FunctionCall) otto.Value {
+		return toJS(call, os.Getenv(call.Argument(0).String()))
+	})
+	osObj.Set("args", func(call otto.FunctionCall) otto.Value {
+		if args == nil {
+			// an empty array rather than null
+			return toJS(call, []string{})
+		}
+		return toJS(call, args)
+	})
+	osObj.Set("exit", func(call otto.FunctionCall) otto.Value {
+		code := int64(0)
+		if call.Argument(0).IsUndefined() == false {
+			code, _ = call.Argument(0).ToInteger()
+		}
+		exit.code, exit.exited = int(code), true
+		exit.halt(vm)
+		return otto.UndefinedValue()
+	})
+
+	dsObj, err := vm.Object(`({})`)
+	if err != nil {
+		return nil, nil, err
+	}
+	vm.Set("dataset", dsObj)
+	dsObj.Set("collection", func(call otto.FunctionCall) otto.Value {
+		params := []interface{}{}
+		for i := 1; i < len(call.ArgumentList); i++ {
+			arg := call.ArgumentList[i]
+			if i, err := arg.ToInteger(); err == nil && arg.String() == fmt.Sprintf("%d", i) {
+				params = append(params, int(i))
+			} else {
+				params = append(params, arg.String())
+			}
+		}
+		return toJS(call, api.Collection(call.Argument(0).String(), params...))
+	})
+	dsObj.Set("keys", func(call otto.FunctionCall) otto.Value {
+		c, err := OpenCollection(api, call.Argument(0).String())
+		if err != nil {
+			jsThrow(call, err)
+		}
+		defer c.Close()
+		return toJS(call, GetKeys(c))
+	})
+	dsObj.Set("read", func(call otto.FunctionCall) otto.Value {
+		c, err := OpenCollection(api, call.Argument(0).String())
+		if err != nil {
+			jsThrow(call, err)
+		}
+		defer c.Close()
+		src, err := ReadJSON(c, call.Argument(1).String())
+		if err != nil {
+			jsThrow(call, err)
+		}
+		return toJS(call, json.RawMessage(src))
+	})
+	return vm, exit, nil
+}
+
+// RunScript runs the JavaScript src, read from fname, with NewJSRuntime.
+// It returns the code the script passed to os.exit(), zero when it ends.
+func (api *ArchivesSpaceAPI) RunScript(ctx context.Context, fname string, src []byte, args []string) (code int, err error) {
+	vm, exit, err := api.newJSRuntime(ctx, args)
+	if err != nil {
+		return 1, fmt.Errorf("Can't create the JavaScript runtime, %w", err)
+	}
+	script, err := vm.Compile(fname, src)
+	if err != nil {
+		return 1, fmt.Errorf("Can't compile %s, %w", fname, err)
+	}
+	defer func() {
+		if caught := recover(); caught != nil {
+			if caught != exit {
+				panic(caught)
+			}
+			code, err = exit.code, nil
+		}
+	}()
+	_, err = vm.Run(script)
+	if exit.exited == true {
+		return exit.code, nil
+	}
+	if err != nil {
+		if jsErr, ok := err.(*otto.Error); ok == true {
+			return 1, fmt.Errorf("%s", jsErr.String())
+		}
+		return 1, fmt.Errorf("%s, %w", fname, err)
+	}
+	return 0, nil
+}
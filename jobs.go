@@ -0,0 +1,244 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// DefaultJobPollInterval is how often WaitForJob and StreamJobLog check a job's status
+var DefaultJobPollInterval = 5 * time.Second
+
+// NewJob returns a Job of jobType (e.g. report_job, import_job, print_to_pdf_job,
+// find_and_replace_job) with the job specific details taken from params
+// (e.g. a *ReportJob or *ImportJob).
+func NewJob(jobType string, params interface{}) (*Job, error) {
+	src, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(src, &m); err != nil {
+		return nil, fmt.Errorf("Can't make %s from %T, %w", jobType, params, err)
+	}
+	m["jsonmodel_type"] = jobType
+	return &Job{
+		JSONModelType: "job",
+		JobType:       jobType,
+		Job:           m,
+	}, nil
+}
+
+// IsFinished returns true if the job has completed, failed or been canceled
+func (job *Job) IsFinished() bool {
+	switch job.Status {
+	case "completed", "failed", "canceled":
+		return true
+	}
+	return false
+}
+
+// prepare sets the JSONModel types ArchivesSpace expects of a submitted job
+func (job *Job) prepare() {
+	job.JSONModelType = "job"
+	if job.Job != nil && job.JobType != "" {
+		if _, ok := job.Job["jsonmodel_type"]; ok == false {
+			job.Job["jsonmodel_type"] = job.JobType
+		}
+	}
+}
+
+// SubmitJob submits a job to a Repository's job queue
+func (api *ArchivesSpaceAPI) SubmitJob(ctx context.Context, repoID int, job *Job) (*ResponseMsg, error) {
+	job.prepare()
+	responseMsg, err := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/jobs", repoID), nil), job)
+	if err != nil {
+		return responseMsg, fmt.Errorf("SubmitJob(%d) %w", repoID, err)
+	}
+	job.URI = responseMsg.URI
+	job.ID = responseMsg.ID
+	return responseMsg, nil
+}
+
+// SubmitJobWithFiles submits a job along with the files it processes,
+// e.g. the EAD or CSV files of an import_job
+func (api *ArchivesSpaceAPI) SubmitJobWithFiles(ctx context.Context, repoID int, job *Job, fnames []string) (*ResponseMsg, error) {
+	job.prepare()
+	src, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	if err := w.WriteField("job", string(src)); err != nil {
+		return nil, err
+	}
+	for _, fname := range fnames {
+		fp, err := os.Open(fname)
+		if err != nil {
+			return nil, fmt.Errorf("Can't read %s, %w", fname, err)
+		}
+		part, err := w.CreateFormFile("files[]", path.Base(fname))
+		if err == nil {
+			_, err = io.Copy(part, fp)
+		}
+		fp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Can't attach %s, %w", fname, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/jobs_with_files", repoID), nil)
+	res, content, err := api.doWithRetry(ctx, "POST", u, w.FormDataContentType(), buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("SubmitJobWithFiles(%d) %w", repoID, err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, newAPIError("POST", u, res, content)
+	}
+	responseMsg := new(ResponseMsg)
+	if err := json.Unmarshal(content, responseMsg); err != nil {
+		return nil, fmt.Errorf("SubmitJobWithFiles(%d) %w", repoID, err)
+	}
+	job.URI = responseMsg.URI
+	job.ID = responseMsg.ID
+	return responseMsg, nil
+}
+
+// GetJob retrieves a job and its current status
+func (api *ArchivesSpaceAPI) GetJob(ctx context.Context, repoID, jobID int) (*Job, error) {
+	job := new(Job)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/jobs/%d", repoID, jobID), nil)
+	if err := api.GetAPI(ctx, u, job); err != nil {
+		return nil, fmt.Errorf("GetJob() %s, error, %w", u, err)
+	}
+	job.ID = URIToID(job.URI)
+	return job, nil
+}
+
+// CancelJob cancels a queued or running job
+func (api *ArchivesSpaceAPI) CancelJob(ctx context.Context, repoID, jobID int) (*ResponseMsg, error) {
+	return api.UpdateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/jobs/%d/cancel", repoID, jobID), nil), nil)
+}
+
+// GetJobLog returns the job's log starting at offset bytes
+func (api *ArchivesSpaceAPI) GetJobLog(ctx context.Context, repoID, jobID int, offset int) ([]byte, error) {
+	q := url.Values{}
+	q.Set("offset", strconv.Itoa(offset))
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/jobs/%d/log", repoID, jobID), q)
+	content, err := api.API(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("GetJobLog() %s, error, %w", u, err)
+	}
+	return content, nil
+}
+
+// WaitForJob polls a job every interval until it is finished returning the
+// finished job. Use a context with a deadline to limit the wait.
+func (api *ArchivesSpaceAPI) WaitForJob(ctx context.Context, repoID, jobID int, interval time.Duration) (*Job, error) {
+	if interval <= 0 {
+		interval = DefaultJobPollInterval
+	}
+	for {
+		job, err := api.GetJob(ctx, repoID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.IsFinished() == true {
+			return job, nil
+		}
+		if api.Verbose == true {
+			log.Printf("Job %d is %s", jobID, job.Status)
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return job, fmt.Errorf("Job %d is still %s, %w", jobID, job.Status, err)
+		}
+	}
+}
+
+// StreamJobLog copies the job's log to w as it is written, polling every
+// interval until the job is finished. It returns the finished job.
+func (api *ArchivesSpaceAPI) StreamJobLog(ctx context.Context, repoID, jobID int, w io.Writer, interval time.Duration) (*Job, error) {
+	if interval <= 0 {
+		interval = DefaultJobPollInterval
+	}
+	offset := 0
+	for {
+		// NOTE: check the status before reading so the final read includes the end of the log
+		job, err := api.GetJob(ctx, repoID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		src, err := api.GetJobLog(ctx, repoID, jobID, offset)
+		if err != nil {
+			return job, err
+		}
+		if len(src) > 0 {
+			if _, err := w.Write(src); err != nil {
+				return job, err
+			}
+			offset += len(src)
+		}
+		if job.IsFinished() == true {
+			return job, nil
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return job, fmt.Errorf("Job %d is still %s, %w", jobID, job.Status, err)
+		}
+	}
+}
+
+// ListJobOutputFiles returns the ids of the files a job produced
+func (api *ArchivesSpaceAPI) ListJobOutputFiles(ctx context.Context, repoID, jobID int) ([]int, error) {
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/jobs/%d/output_files", repoID, jobID), nil))
+}
+
+// GetJobOutputFile copies a job's output file to w as it is read. The
+// request isn't retried since part of the file may already be written.
+func (api *ArchivesSpaceAPI) GetJobOutputFile(ctx context.Context, repoID, jobID, fileID int, w io.Writer) error {
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/jobs/%d/output_files/%d", repoID, jobID, fileID), nil)
+	res, err := api.send(ctx, "GET", u, "application/json", nil, api.token())
+	if err != nil {
+		return fmt.Errorf("GetJobOutputFile() %s, error, %w", redactURL(u), err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		content, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("GetJobOutputFile() %s, error, %w", redactURL(u), newAPIError("GET", u, res, content))
+	}
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("Can't copy output file %d of job %d, %w", fileID, jobID, err)
+	}
+	return nil
+}
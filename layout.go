@@ -0,0 +1,283 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	// AgentTypes are the kinds of agent ArchivesSpace keeps, each is exported
+	// to its own collection
+	AgentTypes = []string{"people", "corporate_entities", "families", "software"}
+
+	// DefaultLayout is the collection layout written by ExportArchivesSpace
+	// and read by cait-genpages
+	DefaultLayout = Layout{
+		"repository":               "repository.ds",
+		"subject":                  "subjects.ds",
+		"vocabulary":               "vocabularies.ds",
+		"term":                     "vocabulary-%d/terms.ds",
+		"location":                 "locations.ds",
+		"agent":                    "agents.ds/%s",
+		"accession":                "repository-%d/accessions.ds",
+		"digital_object":           "repository-%d/digital_objects.ds",
+		"digital_object_component": "repository-%d/digital_object_components.ds",
+		"resource":                 "repository-%d/resources.ds",
+		"archival_object":          "repository-%d/archival_objects.ds",
+		"top_container":            "repository-%d/top_containers.ds",
+		"event":                    "repository-%d/events.ds",
+		"classification":           "repository-%d/classifications.ds",
+		"classification_term":      "repository-%d/classification_terms.ds",
+	}
+
+	// LegacyLayout is the layout earlier versions of cait-genpages read,
+	// MigrateLayout moves its collections to DefaultLayout
+	LegacyLayout = Layout{
+		"subject":                  "subjects",
+		"agent":                    "agents/%s",
+		"accession":                "repositories/%d/accessions",
+		"digital_object":           "repositories/%d/digital_objects",
+		"digital_object_component": "repositories/%d/digital_object_components",
+		"classification":           "repositories/%d/classifications",
+		"classification_term":      "repositories/%d/classification_terms",
+	}
+)
+
+// Layout maps record types (e.g. accession, agent) to dataset collection
+// names. Agent collections include %s for the agent type, repository
+// records %d for the repository id and terms %d for the vocabulary id.
+type Layout map[string]string
+
+// Collection returns the collection name for recordType, args fill in the
+// agent type, repository or vocabulary id. An unknown type returns "".
+func (layout Layout) Collection(recordType string, args ...interface{}) string {
+	name, ok := layout[recordType]
+	if ok == false {
+		return ""
+	}
+	if strings.Contains(name, "%") == false {
+		return name
+	}
+	return fmt.Sprintf(name, args...)
+}
+
+// Collections returns the collection names for recordType, one per agent
+// type or id in ids when the name takes an argument.
+func (layout Layout) Collections(recordType string, ids []int) []string {
+	name, ok := layout[recordType]
+	switch {
+	case ok == false:
+		return nil
+	case strings.Contains(name, "%s"):
+		names := []string{}
+		for _, agentType := range AgentTypes {
+			names = append(names, layout.Collection(recordType, agentType))
+		}
+		return names
+	case strings.Contains(name, "%d"):
+		names := []string{}
+		for _, id := range ids {
+			names = append(names, layout.Collection(recordType, id))
+		}
+		return names
+	}
+	return []string{name}
+}
+
+// Collection returns the collection name for recordType in api.Layout,
+// DefaultLayout is used when api.Layout is nil or doesn't name the type.
+func (api *ArchivesSpaceAPI) Collection(recordType string, args ...interface{}) string {
+	if api.Layout != nil {
+		if name := api.Layout.Collection(recordType, args...); name != "" {
+			return name
+		}
+	}
+	return DefaultLayout.Collection(recordType, args...)
+}
+
+// LayoutMove describes a collection MigrateLayout moves
+type LayoutMove struct {
+	RecordType string `json:"record_type"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Records    int    `json:"records"`
+}
+
+// MigrateLayout moves the collections named in from to their names in to,
+// ids are the repository ids to migrate repository collections for. Records
+// are copied to the new collection then removed from the old one, a
+// collection linked to its new name only has the link removed. With
+// dryRun set the moves are returned without changing the dataset.
+func (api *ArchivesSpaceAPI) MigrateLayout(from, to Layout, ids []int, dryRun bool) ([]*LayoutMove, error) {
+	recordTypes := []string{}
+	for recordType := range from {
+		if _, ok := to[recordType]; ok == true && recordType != "term" {
+			recordTypes = append(recordTypes, recordType)
+		}
+	}
+	sort.Strings(recordTypes)
+
+	moves := []*LayoutMove{}
+	for _, recordType := range recordTypes {
+		src, dest := from.Collections(recordType, ids), to.Collections(recordType, ids)
+		for i, name := range src {
+			if name == dest[i] {
+				continue
+			}
+			move := &LayoutMove{RecordType: recordType, From: name, To: dest[i]}
+			n, err := api.moveCollection(move.From, move.To, dryRun)
+			if err != nil {
+				return moves, fmt.Errorf("Can't move %s to %s, %w", move.From, move.To, err)
+			}
+			if n == 0 {
+				continue
+			}
+			move.Records = n
+			moves = append(moves, move)
+		}
+	}
+	return moves, nil
+}
+
+// sameCollection reports if the collections from and to are the same
+// directory, e.g. when one is a symlink to the other
+func (api *ArchivesSpaceAPI) sameCollection(from, to string) bool {
+	fromInfo, err := os.Stat(path.Join(api.Dataset, from))
+	if err != nil {
+		return false
+	}
+	toInfo, err := os.Stat(path.Join(api.Dataset, to))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fromInfo, toInfo)
+}
+
+// replaceLink handles a collection linked to its new name, the link is
+// removed when from links to to, when to links to from the link is
+// replaced by the collection. No records are copied or removed.
+func (api *ArchivesSpaceAPI) replaceLink(from, to string) error {
+	fromPath, toPath := path.Join(api.Dataset, from), path.Join(api.Dataset, to)
+	if info, err := os.Lstat(fromPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(fromPath)
+	}
+	if info, err := os.Lstat(toPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(toPath); err != nil {
+			return err
+		}
+		return os.Rename(fromPath, toPath)
+	}
+	return fmt.Errorf("%s and %s are the same collection through a linked parent directory, remove the link by hand", from, to)
+}
+
+// sameRecord reports if the JSON src and dest hold the same record
+func sameRecord(src, dest []byte) bool {
+	var a, b interface{}
+	if json.Unmarshal(src, &a) != nil || json.Unmarshal(dest, &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// moveCollection copies the records in collection from to collection to
+// and then removes them from from, it returns the number of records moved.
+// When from and to are the same directory (e.g. a symlink made for an
+// earlier layout) only the link is changed. It fails without changing
+// anything when to already holds a different record under a key of from.
+func (api *ArchivesSpaceAPI) moveCollection(from, to string, dryRun bool) (int, error) {
+	src, err := OpenCollection(api, from)
+	if err != nil {
+		// Nothing to move if the old collection doesn't exist
+		return 0, nil
+	}
+	defer src.Close()
+	keys := GetKeys(src)
+	if api.sameCollection(from, to) == true {
+		if dryRun == true {
+			return len(keys), nil
+		}
+		return len(keys), api.replaceLink(from, to)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	// Check every key before changing anything, records already in to are
+	// kept when they match and the move refused when they don't
+	copies := []string{}
+	if dest, err := OpenCollection(api, to); err == nil {
+		defer dest.Close()
+		conflicts := []string{}
+		for _, key := range keys {
+			if dest.HasKey(key) == false {
+				copies = append(copies, key)
+				continue
+			}
+			srcData, err := ReadJSON(src, key)
+			if err != nil {
+				return 0, fmt.Errorf("Can't read %s/%s, %w", from, key, err)
+			}
+			destData, err := ReadJSON(dest, key)
+			if err != nil {
+				return 0, fmt.Errorf("Can't read %s/%s, %w", to, key, err)
+			}
+			if sameRecord(srcData, destData) == false {
+				conflicts = append(conflicts, key)
+			}
+		}
+		if len(conflicts) > 0 {
+			return 0, fmt.Errorf("%d records in %s differ from %s (e.g. %s), remove the older copies first", len(conflicts), to, from, conflicts[0])
+		}
+	} else {
+		copies = keys
+	}
+	if dryRun == true {
+		return len(keys), nil
+	}
+
+	dest, err := CreateCollection(api, to)
+	if err != nil {
+		return 0, err
+	}
+	defer dest.Close()
+	for _, key := range copies {
+		data, err := ReadJSON(src, key)
+		if err != nil {
+			return 0, fmt.Errorf("Can't read %s/%s, %w", from, key, err)
+		}
+		if err := WriteJSON(dest, key, json.RawMessage(data)); err != nil {
+			return 0, fmt.Errorf("Can't write %s/%s, %w", to, key, err)
+		}
+	}
+	// Every record is in to, only now remove them from from
+	for i, key := range keys {
+		if err := src.Delete(key); err != nil {
+			return i, fmt.Errorf("Can't remove %s/%s, %w", from, key, err)
+		}
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,107 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseCoordinateRange parses a coordinate range expression of the form
+// LABEL:START-END (e.g. "Range:1-10" or "Shelf:A-F")
+func ParseCoordinateRange(s string) (*CoordinateRange, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 1 {
+		return nil, fmt.Errorf("Can't parse coordinate range %q, expected LABEL:START-END", s)
+	}
+	bounds := strings.SplitN(s[i+1:], "-", 2)
+	if len(bounds) != 2 || strings.TrimSpace(bounds[0]) == "" || strings.TrimSpace(bounds[1]) == "" {
+		return nil, fmt.Errorf("Can't parse coordinate range %q, expected LABEL:START-END", s)
+	}
+	return &CoordinateRange{
+		Label: strings.TrimSpace(s[0:i]),
+		Start: strings.TrimSpace(bounds[0]),
+		End:   strings.TrimSpace(bounds[1]),
+	}, nil
+}
+
+// SetCoordinateRanges sets the batch's coordinate ranges in order from
+// expressions like "Range:1-10", at most three ranges are allowed
+func (batch *LocationBatch) SetCoordinateRanges(expressions ...string) error {
+	if len(expressions) > 3 {
+		return fmt.Errorf("A location batch has at most three coordinate ranges, got %d", len(expressions))
+	}
+	var ranges [3]*CoordinateRange
+	for i, expr := range expressions {
+		r, err := ParseCoordinateRange(expr)
+		if err != nil {
+			return err
+		}
+		ranges[i] = r
+	}
+	batch.Coordinate1Range, batch.Coordinate2Range, batch.Coordinate3Range = ranges[0], ranges[1], ranges[2]
+	return nil
+}
+
+// CreateLocationBatch creates a location for each combination of the
+// batch's coordinate ranges and returns their URIs. With dryRun nothing is
+// created and the titles ArchivesSpace would generate are returned instead.
+func (api *ArchivesSpaceAPI) CreateLocationBatch(ctx context.Context, batch *LocationBatch, dryRun bool) ([]string, error) {
+	if batch.Coordinate1Range == nil {
+		return nil, fmt.Errorf("A location batch needs at least one coordinate range")
+	}
+	batch.JSONModelType = "location_batch"
+	q := url.Values{}
+	if dryRun == true {
+		q.Set("dry_run", "true")
+	}
+	content, err := api.API(ctx, "POST", api.CallURL("/locations/batch", q), batch)
+	if err != nil {
+		return nil, fmt.Errorf("CreateLocationBatch() %w", err)
+	}
+	var results []string
+	if dryRun == true {
+		var locations []*Location
+		if err := json.Unmarshal(content, &locations); err != nil {
+			return nil, fmt.Errorf("CreateLocationBatch() can't decode dry run, %w", err)
+		}
+		for _, location := range locations {
+			results = append(results, location.Title)
+		}
+		return results, nil
+	}
+	if err := json.Unmarshal(content, &results); err != nil {
+		return nil, fmt.Errorf("CreateLocationBatch() can't decode response, %w", err)
+	}
+	return results, nil
+}
+
+// UpdateLocationBatch applies the non-empty fields of update (e.g. a new
+// building or room) to each location listed in update.RecordURIs
+func (api *ArchivesSpaceAPI) UpdateLocationBatch(ctx context.Context, update *LocationBatchUpdate) (*ResponseMsg, error) {
+	if len(update.RecordURIs) == 0 {
+		return nil, fmt.Errorf("A location batch update needs record_uris")
+	}
+	update.JSONModelType = "location_batch_update"
+	return api.UpdateAPI(ctx, api.CallURL("/locations/batch_update", nil), update)
+}
@@ -0,0 +1,132 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"fmt"
+)
+
+// NewMergeRequest returns a merge request that merges victims into target,
+// target and victims are record URIs (e.g. /agents/people/3)
+func NewMergeRequest(target string, victims ...string) *MergeRequest {
+	req := new(MergeRequest)
+	req.JSONModelType = "merge_request"
+	req.Target = map[string]interface{}{"ref": target}
+	for _, victim := range victims {
+		req.Victims = append(req.Victims, map[string]interface{}{"ref": victim})
+	}
+	return req
+}
+
+// TargetURI returns the URI of the record the victims are merged into
+func (req *MergeRequest) TargetURI() string {
+	return refString(req.Target)
+}
+
+// VictimURIs returns the URIs of the records merged into the target
+func (req *MergeRequest) VictimURIs() []string {
+	var uris []string
+	for _, victim := range req.Victims {
+		if ref := refString(victim); ref != "" {
+			uris = append(uris, ref)
+		}
+	}
+	return uris
+}
+
+// submitMergeRequest posts req to /merge_requests/{recordType}
+func (api *ArchivesSpaceAPI) submitMergeRequest(ctx context.Context, recordType string, req *MergeRequest) (*ResponseMsg, error) {
+	if req.TargetURI() == "" || len(req.VictimURIs()) == 0 {
+		return nil, fmt.Errorf("A %s merge request needs a target and at least one victim", recordType)
+	}
+	for _, victim := range req.VictimURIs() {
+		if victim == req.TargetURI() {
+			return nil, fmt.Errorf("Can't merge %s into itself", victim)
+		}
+	}
+	req.JSONModelType = "merge_request"
+	return api.CreateAPI(ctx, api.CallURL("/merge_requests/"+recordType, nil), req)
+}
+
+// MergeAgents merges the victim agents into target, the records linked
+// to the victims are re-linked to target and the victims are deleted
+func (api *ArchivesSpaceAPI) MergeAgents(ctx context.Context, req *MergeRequest) (*ResponseMsg, error) {
+	return api.submitMergeRequest(ctx, "agent", req)
+}
+
+// MergeSubjects merges the victim subjects into target
+func (api *ArchivesSpaceAPI) MergeSubjects(ctx context.Context, req *MergeRequest) (*ResponseMsg, error) {
+	return api.submitMergeRequest(ctx, "subject", req)
+}
+
+// MergeTopContainers merges the victim top containers into target
+func (api *ArchivesSpaceAPI) MergeTopContainers(ctx context.Context, req *MergeRequest) (*ResponseMsg, error) {
+	return api.submitMergeRequest(ctx, "top_container", req)
+}
+
+// LinkedRecord describes a record that links to an agent
+type LinkedRecord struct {
+	URI         string `json:"uri"`
+	Title       string `json:"title,omitempty"`
+	PrimaryType string `json:"primary_type,omitempty"`
+}
+
+// MergePreview lists the records that will be re-linked to the target by
+// an agent merge request, keyed by victim URI
+type MergePreview struct {
+	Target   string                     `json:"target"`
+	Victims  []string                   `json:"victims"`
+	Relinked map[string][]*LinkedRecord `json:"relinked"`
+}
+
+// GetAgentLinkedRecords returns the records whose linked agents include
+// agentURI using the search index (the agent_uris field).
+func (api *ArchivesSpaceAPI) GetAgentLinkedRecords(ctx context.Context, agentURI string) ([]*LinkedRecord, error) {
+	var records []*LinkedRecord
+	opts := &SearchOptions{Q: fmt.Sprintf("agent_uris:%q", agentURI)}
+	err := api.EachSearchResult(ctx, 0, opts, func(result *SearchResult) error {
+		if result.URI != agentURI {
+			records = append(records, &LinkedRecord{URI: result.URI, Title: result.Title, PrimaryType: result.PrimaryType})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can't search for records linked to %s, %w", agentURI, err)
+	}
+	return records, nil
+}
+
+// PreviewAgentMerge returns the records that would be re-linked by an
+// agent merge request without changing anything
+func (api *ArchivesSpaceAPI) PreviewAgentMerge(ctx context.Context, req *MergeRequest) (*MergePreview, error) {
+	preview := new(MergePreview)
+	preview.Target = req.TargetURI()
+	preview.Victims = req.VictimURIs()
+	preview.Relinked = make(map[string][]*LinkedRecord)
+	for _, victim := range preview.Victims {
+		records, err := api.GetAgentLinkedRecords(ctx, victim)
+		if err != nil {
+			return nil, err
+		}
+		preview.Relinked[victim] = records
+	}
+	return preview, nil
+}
@@ -0,0 +1,282 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultPageSize is used by the paginated list methods when pageSize is less than one
+	DefaultPageSize = 100
+	// MaxIDSetSize is the largest id_set ArchivesSpace accepts in one request
+	MaxIDSetSize = 250
+)
+
+// PageInfo describes a page of results from a paginated ArchivesSpace list
+type PageInfo struct {
+	FirstPage int `json:"first_page"`
+	LastPage  int `json:"last_page"`
+	ThisPage  int `json:"this_page"`
+	Total     int `json:"total"`
+}
+
+// ResultPage is the JSON returned by ArchivesSpace for a page of records
+type ResultPage struct {
+	PageInfo
+	Results []json.RawMessage `json:"results"`
+}
+
+// pageQuery returns the query for page of size pageSize
+func pageQuery(page, pageSize int) url.Values {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	q := url.Values{}
+	q.Set("page", strconv.Itoa(page))
+	q.Set("page_size", strconv.Itoa(pageSize))
+	return q
+}
+
+// modifiedSinceQuery returns the query for page of size pageSize limited
+// to records modified since, a zero since returns all records
+func modifiedSinceQuery(page, pageSize int, since time.Time) url.Values {
+	q := pageQuery(page, pageSize)
+	if since.IsZero() == false {
+		q.Set("modified_since", strconv.FormatInt(since.Unix(), 10))
+	}
+	return q
+}
+
+// idSetQuery returns the query requesting the records in ids
+func idSetQuery(ids []int) url.Values {
+	q := url.Values{}
+	for _, id := range ids {
+		q.Add("id_set[]", strconv.Itoa(id))
+	}
+	return q
+}
+
+// idSets splits ids into slices no larger than MaxIDSetSize
+func idSets(ids []int) [][]int {
+	var sets [][]int
+	for len(ids) > MaxIDSetSize {
+		sets = append(sets, ids[0:MaxIDSetSize])
+		ids = ids[MaxIDSetSize:]
+	}
+	if len(ids) > 0 {
+		sets = append(sets, ids)
+	}
+	return sets
+}
+
+// eachPage calls fetch for page 1, 2, ... until the last page is reached,
+// fetch returns the page details and the number of records on the page.
+func eachPage(fetch func(page int) (*PageInfo, int, error)) error {
+	for page := 1; ; page++ {
+		info, cnt, err := fetch(page)
+		if err != nil {
+			return err
+		}
+		if cnt == 0 || info.ThisPage >= info.LastPage {
+			return nil
+		}
+	}
+}
+
+// ListPageAPI returns a page of records from a paginated list URL,
+// the URL should include the page and page_size query parameters.
+func (api *ArchivesSpaceAPI) ListPageAPI(ctx context.Context, url string) (*ResultPage, error) {
+	content, err := api.API(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ListPageAPI(%q) %w", url, err)
+	}
+	rp := new(ResultPage)
+	if err := json.Unmarshal(content, rp); err != nil {
+		return nil, fmt.Errorf("ListPageAPI(%q) %w", url, err)
+	}
+	return rp, nil
+}
+
+// RecordList is a paginated ArchivesSpace list of records of type T,
+// e.g. api.AccessionList(2). It returns full records a page at a time
+// so large lists are processed in constant memory.
+type RecordList[T any] struct {
+	api   *ArchivesSpaceAPI
+	path  string
+	setID func(*T)
+}
+
+// page returns the page of records described by q
+func (l *RecordList[T]) page(ctx context.Context, q url.Values) ([]*T, *PageInfo, error) {
+	rp, err := l.api.ListPageAPI(ctx, l.api.CallURL(l.path, q))
+	if err != nil {
+		return nil, nil, err
+	}
+	records := make([]*T, len(rp.Results))
+	for i, src := range rp.Results {
+		record := new(T)
+		if err := json.Unmarshal(src, record); err != nil {
+			return nil, nil, fmt.Errorf("Can't decode %s (%s), %w", l.path, q.Encode(), err)
+		}
+		l.setID(record)
+		records[i] = record
+	}
+	return records, &rp.PageInfo, nil
+}
+
+// Page returns page (starting at 1) of the list with up to pageSize
+// full records and the page details.
+func (l *RecordList[T]) Page(ctx context.Context, page, pageSize int) ([]*T, *PageInfo, error) {
+	return l.page(ctx, pageQuery(page, pageSize))
+}
+
+// Get returns the records for ids using id_set requests
+func (l *RecordList[T]) Get(ctx context.Context, ids []int) ([]*T, error) {
+	var records []*T
+	for _, set := range idSets(ids) {
+		var page []*T
+		if err := l.api.GetAPI(ctx, l.api.CallURL(l.path, idSetQuery(set)), &page); err != nil {
+			return nil, fmt.Errorf("Can't get %s id set, %w", l.path, err)
+		}
+		for _, record := range page {
+			l.setID(record)
+		}
+		records = append(records, page...)
+	}
+	return records, nil
+}
+
+// Each calls fn with each record fetching pageSize records per request.
+// Iteration stops with the first error returned by fn.
+func (l *RecordList[T]) Each(ctx context.Context, pageSize int, fn func(*T) error) error {
+	return l.EachModifiedSince(ctx, time.Time{}, pageSize, fn)
+}
+
+// EachModifiedSince calls fn with each record modified since the given
+// time, a zero time visits every record.
+func (l *RecordList[T]) EachModifiedSince(ctx context.Context, since time.Time, pageSize int, fn func(*T) error) error {
+	return eachPage(func(page int) (*PageInfo, int, error) {
+		records, info, err := l.page(ctx, modifiedSinceQuery(page, pageSize, since))
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return nil, 0, err
+			}
+		}
+		return info, len(records), nil
+	})
+}
+
+// Stream sends the records visited by Each on the returned channel, which
+// is closed when the list ends or fails. The error channel
+// then receives the error that stopped the list, nil at the end of the
+// list. Cancel ctx to stop reading early.
+func (l *RecordList[T]) Stream(ctx context.Context, pageSize int) (<-chan *T, <-chan error) {
+	records, errc := make(chan *T), make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(records)
+		errc <- l.Each(ctx, pageSize, func(record *T) error {
+			select {
+			case records <- record:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return records, errc
+}
+
+// AgentList is the paginated list of Agents of agentType (e.g. people, corporate_entities)
+func (api *ArchivesSpaceAPI) AgentList(agentType string) *RecordList[Agent] {
+	return &RecordList[Agent]{api: api, path: fmt.Sprintf(`/agents/%s`, agentType), setID: func(obj *Agent) { obj.ID = URIToID(obj.URI) }}
+}
+
+// AccessionList is the paginated list of Accession records from a Repository
+func (api *ArchivesSpaceAPI) AccessionList(repoID int) *RecordList[Accession] {
+	return &RecordList[Accession]{api: api, path: fmt.Sprintf(`/repositories/%d/accessions`, repoID), setID: func(obj *Accession) { obj.ID = URIToID(obj.URI) }}
+}
+
+// SubjectList is the paginated list of Subject records
+func (api *ArchivesSpaceAPI) SubjectList() *RecordList[Subject] {
+	return &RecordList[Subject]{api: api, path: "/subjects", setID: func(obj *Subject) { obj.ID = URIToID(obj.URI) }}
+}
+
+// LocationList is the paginated list of Location records
+func (api *ArchivesSpaceAPI) LocationList() *RecordList[Location] {
+	return &RecordList[Location]{api: api, path: "/locations", setID: func(obj *Location) { obj.ID = URIToID(obj.URI) }}
+}
+
+// DigitalObjectList is the paginated list of DigitalObject records from a Repository
+func (api *ArchivesSpaceAPI) DigitalObjectList(repoID int) *RecordList[DigitalObject] {
+	return &RecordList[DigitalObject]{api: api, path: fmt.Sprintf(`/repositories/%d/digital_objects`, repoID), setID: func(obj *DigitalObject) { obj.ID = URIToID(obj.URI) }}
+}
+
+// DigitalObjectComponentList is the paginated list of DigitalObjectComponent records from a Repository
+func (api *ArchivesSpaceAPI) DigitalObjectComponentList(repoID int) *RecordList[DigitalObjectComponent] {
+	return &RecordList[DigitalObjectComponent]{api: api, path: fmt.Sprintf(`/repositories/%d/digital_object_components`, repoID), setID: func(obj *DigitalObjectComponent) { obj.ID = URIToID(obj.URI) }}
+}
+
+// ResourceList is the paginated list of Resource records from a Repository
+func (api *ArchivesSpaceAPI) ResourceList(repoID int) *RecordList[Resource] {
+	return &RecordList[Resource]{api: api, path: fmt.Sprintf(`/repositories/%d/resources`, repoID), setID: func(obj *Resource) { obj.ID = URIToID(obj.URI) }}
+}
+
+// ArchivalObjectList is the paginated list of ArchivalObject records from a Repository
+func (api *ArchivesSpaceAPI) ArchivalObjectList(repoID int) *RecordList[ArchivalObject] {
+	return &RecordList[ArchivalObject]{api: api, path: fmt.Sprintf(`/repositories/%d/archival_objects`, repoID), setID: func(obj *ArchivalObject) { obj.ID = URIToID(obj.URI) }}
+}
+
+// TopContainerList is the paginated list of TopContainer records from a Repository
+func (api *ArchivesSpaceAPI) TopContainerList(repoID int) *RecordList[TopContainer] {
+	return &RecordList[TopContainer]{api: api, path: fmt.Sprintf(`/repositories/%d/top_containers`, repoID), setID: func(obj *TopContainer) { obj.ID = URIToID(obj.URI) }}
+}
+
+// ContainerProfileList is the paginated list of ContainerProfile records
+func (api *ArchivesSpaceAPI) ContainerProfileList() *RecordList[ContainerProfile] {
+	return &RecordList[ContainerProfile]{api: api, path: "/container_profiles", setID: func(obj *ContainerProfile) { obj.ID = URIToID(obj.URI) }}
+}
+
+// EventList is the paginated list of Event records from a Repository
+func (api *ArchivesSpaceAPI) EventList(repoID int) *RecordList[Event] {
+	return &RecordList[Event]{api: api, path: fmt.Sprintf(`/repositories/%d/events`, repoID), setID: func(obj *Event) { obj.ID = URIToID(obj.URI) }}
+}
+
+// ClassificationList is the paginated list of Classification records from a Repository
+func (api *ArchivesSpaceAPI) ClassificationList(repoID int) *RecordList[Classification] {
+	return &RecordList[Classification]{api: api, path: fmt.Sprintf(`/repositories/%d/classifications`, repoID), setID: func(obj *Classification) { obj.ID = URIToID(obj.URI) }}
+}
+
+// ClassificationTermList is the paginated list of ClassificationTerm records from a Repository
+func (api *ArchivesSpaceAPI) ClassificationTermList(repoID int) *RecordList[ClassificationTerm] {
+	return &RecordList[ClassificationTerm]{api: api, path: fmt.Sprintf(`/repositories/%d/classification_terms`, repoID), setID: func(obj *ClassificationTerm) { obj.ID = URIToID(obj.URI) }}
+}
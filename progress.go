@@ -0,0 +1,97 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// MaxExportWorkers bounds ExportWorkers so an export doesn't
+	// overwhelm the ArchivesSpace server
+	MaxExportWorkers = 16
+)
+
+// ExportProgress describes how far the export of a collection has got,
+// Rate is in records per second. Resumed counts the records skipped by
+// resuming an export, they're included in Done but not in Rate.
+type ExportProgress struct {
+	Collection string        `json:"collection"`
+	Done       int           `json:"done"`
+	Resumed    int           `json:"resumed"`
+	Total      int           `json:"total"`
+	Failed     int           `json:"failed"`
+	Started    time.Time     `json:"started"`
+	Rate       float64       `json:"rate"`
+	ETA        time.Duration `json:"eta"`
+}
+
+// ExportProgressFunc is called as each page of records is written, calls
+// are never concurrent
+type ExportProgressFunc func(progress ExportProgress)
+
+// update recalculates the rate and ETA at now
+func (progress *ExportProgress) update(now time.Time) {
+	elapsed := now.Sub(progress.Started).Seconds()
+	if elapsed <= 0 || progress.Done <= progress.Resumed {
+		progress.Rate, progress.ETA = 0, 0
+		return
+	}
+	progress.Rate = float64(progress.Done-progress.Resumed) / elapsed
+	remaining := progress.Total - progress.Done
+	if remaining < 0 {
+		remaining = 0
+	}
+	progress.ETA = time.Duration(float64(remaining) / progress.Rate * float64(time.Second))
+}
+
+// String renders progress as a single line, e.g.
+// "subjects.ds 200/1000 (50.0/s, ETA 16s)"
+func (progress ExportProgress) String() string {
+	failed := ""
+	if progress.Failed > 0 {
+		failed = fmt.Sprintf(", %d failed", progress.Failed)
+	}
+	return fmt.Sprintf("%s %d/%d (%.1f/s, ETA %s%s)", progress.Collection, progress.Done, progress.Total, progress.Rate, progress.ETA.Round(time.Second), failed)
+}
+
+// reportProgress updates progress and passes it to api.ExportProgress,
+// verbose logs it when no ExportProgress func is set.
+func (api *ArchivesSpaceAPI) reportProgress(progress *ExportProgress, label string, verbose bool) {
+	progress.update(time.Now())
+	if api.ExportProgress != nil {
+		api.ExportProgress(*progress)
+	} else if verbose == true {
+		log.Printf("%d of %d %s exported\n", progress.Done, progress.Total, label)
+	}
+}
+
+// exportWorkers returns api.ExportWorkers bounded by 1 and MaxExportWorkers
+func (api *ArchivesSpaceAPI) exportWorkers() int {
+	switch {
+	case api.ExportWorkers < 1:
+		return 1
+	case api.ExportWorkers > MaxExportWorkers:
+		return MaxExportWorkers
+	}
+	return api.ExportWorkers
+}
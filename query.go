@@ -0,0 +1,362 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// QueryClause is implemented by the JSONModel query types that make up an
+// AdvancedQuery, BooleanQuery, FieldQuery, DateFieldQuery and BooleanFieldQuery
+type QueryClause interface {
+	queryType() string
+}
+
+func (q *BooleanQuery) queryType() string {
+	return "boolean_query"
+}
+
+func (q *FieldQuery) queryType() string {
+	return "field_query"
+}
+
+func (q *DateFieldQuery) queryType() string {
+	return "date_field_query"
+}
+
+func (q *BooleanFieldQuery) queryType() string {
+	return "boolean_field_query"
+}
+
+// NewAdvancedQuery returns an advanced query for clause, e.g.
+//
+//	NewAdvancedQuery(And(Field("title", "papers"), DateField("create_time", "greater_than", "2016-01-01")))
+func NewAdvancedQuery(clause QueryClause) *AdvancedQuery {
+	return &AdvancedQuery{
+		Query:         clause,
+		JSONModelType: "advanced_query",
+	}
+}
+
+// And returns a boolean query matching records that match all clauses
+func And(clauses ...QueryClause) *BooleanQuery {
+	return &BooleanQuery{Op: "AND", Subqueries: clauses, JSONModelType: "boolean_query"}
+}
+
+// Or returns a boolean query matching records that match any of the clauses
+func Or(clauses ...QueryClause) *BooleanQuery {
+	return &BooleanQuery{Op: "OR", Subqueries: clauses, JSONModelType: "boolean_query"}
+}
+
+// Not returns a boolean query matching records that match none of the clauses
+func Not(clauses ...QueryClause) *BooleanQuery {
+	return &BooleanQuery{Op: "NOT", Subqueries: clauses, JSONModelType: "boolean_query"}
+}
+
+// Field returns a query matching records where field (e.g. title, keyword) contains value
+func Field(field, value string) *FieldQuery {
+	return &FieldQuery{Field: field, Value: value, JSONModelType: "field_query"}
+}
+
+// Literal returns a query matching records where field is exactly value
+func Literal(field, value string) *FieldQuery {
+	return &FieldQuery{Field: field, Value: value, Literal: true, JSONModelType: "field_query"}
+}
+
+// Negate returns the query with its match negated
+func (q *FieldQuery) Negate() *FieldQuery {
+	q.Negated = !q.Negated
+	return q
+}
+
+// DateField returns a query comparing the date field (e.g. create_time) with
+// value (YYYY-MM-DD), comparator is greater_than, lesser_than or equal
+func DateField(field, comparator, value string) *DateFieldQuery {
+	return &DateFieldQuery{Field: field, Comparator: comparator, Value: value, JSONModelType: "date_field_query"}
+}
+
+// BoolField returns a query matching records where the boolean field (e.g. publish) is value
+func BoolField(field string, value bool) *BooleanFieldQuery {
+	return &BooleanFieldQuery{Field: field, Value: value, JSONModelType: "boolean_field_query"}
+}
+
+// MarshalJSON encodes the query with its jsonmodel_type
+func (q *BooleanQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"jsonmodel_type": q.queryType(),
+		"op":             q.Op,
+		"subqueries":     q.Subqueries,
+	})
+}
+
+// MarshalJSON encodes the query with its jsonmodel_type
+func (q *FieldQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"jsonmodel_type": q.queryType(),
+		"field":          q.Field,
+		"value":          q.Value,
+		"negated":        q.Negated,
+		"literal":        q.Literal,
+	})
+}
+
+// MarshalJSON encodes the query with its jsonmodel_type
+func (q *DateFieldQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"jsonmodel_type": q.queryType(),
+		"comparator":     q.Comparator,
+		"field":          q.Field,
+		"value":          q.Value,
+	})
+}
+
+// MarshalJSON encodes the query with its jsonmodel_type
+func (q *BooleanFieldQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"jsonmodel_type": q.queryType(),
+		"field":          q.Field,
+		"value":          q.Value,
+	})
+}
+
+// MarshalJSON encodes the advanced query with its jsonmodel_type
+func (aq *AdvancedQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"jsonmodel_type": "advanced_query",
+		"query":          aq.Query,
+	})
+}
+
+// decodeQueryClause decodes a query by its jsonmodel_type
+func decodeQueryClause(src []byte) (QueryClause, error) {
+	t := struct {
+		JSONModelType string `json:"jsonmodel_type"`
+	}{}
+	if err := json.Unmarshal(src, &t); err != nil {
+		return nil, err
+	}
+	var clause QueryClause
+	switch t.JSONModelType {
+	case "boolean_query":
+		clause = new(BooleanQuery)
+	case "field_query":
+		clause = new(FieldQuery)
+	case "date_field_query":
+		clause = new(DateFieldQuery)
+	case "boolean_field_query":
+		clause = new(BooleanFieldQuery)
+	default:
+		return nil, fmt.Errorf("Unknown query type %q", t.JSONModelType)
+	}
+	if err := json.Unmarshal(src, clause); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+// UnmarshalJSON decodes the query and its subqueries
+func (q *BooleanQuery) UnmarshalJSON(src []byte) error {
+	obj := struct {
+		Op         string            `json:"op"`
+		Subqueries []json.RawMessage `json:"subqueries"`
+	}{}
+	if err := json.Unmarshal(src, &obj); err != nil {
+		return err
+	}
+	q.JSONModelType = q.queryType()
+	q.Op = obj.Op
+	q.Subqueries = nil
+	for _, item := range obj.Subqueries {
+		clause, err := decodeQueryClause(item)
+		if err != nil {
+			return err
+		}
+		q.Subqueries = append(q.Subqueries, clause)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the advanced query and its clauses
+func (aq *AdvancedQuery) UnmarshalJSON(src []byte) error {
+	obj := struct {
+		Query json.RawMessage `json:"query"`
+	}{}
+	if err := json.Unmarshal(src, &obj); err != nil {
+		return err
+	}
+	aq.JSONModelType = "advanced_query"
+	aq.Query = nil
+	if len(obj.Query) == 0 {
+		return nil
+	}
+	clause, err := decodeQueryClause(obj.Query)
+	if err != nil {
+		return err
+	}
+	aq.Query = clause
+	return nil
+}
+
+// SearchOptions describes a search of a repository
+type SearchOptions struct {
+	// Q is a keyword query, AdvancedQuery a structured one, one of them is required
+	Q             string         `json:"q,omitempty"`
+	AdvancedQuery *AdvancedQuery `json:"aq,omitempty"`
+	// Types limits the results to record types (e.g. accession, resource)
+	Types []string `json:"type,omitempty"`
+	// Facets lists the fields (e.g. subjects, primary_type) to count values of
+	Facets []string `json:"facet,omitempty"`
+	// FilterTerms limits the results to records where field has value
+	FilterTerms map[string]string `json:"filter_term,omitempty"`
+	Sort        string            `json:"sort,omitempty"`
+	Page        int               `json:"page,omitempty"`
+	PageSize    int               `json:"page_size,omitempty"`
+}
+
+// query returns the URL query for page of the search
+func (opts *SearchOptions) query(page int) (url.Values, error) {
+	q := pageQuery(page, opts.PageSize)
+	if opts.Q != "" {
+		q.Set("q", opts.Q)
+	}
+	if opts.AdvancedQuery != nil {
+		src, err := json.Marshal(opts.AdvancedQuery)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("aq", string(src))
+	}
+	if opts.Q == "" && opts.AdvancedQuery == nil {
+		return nil, fmt.Errorf("A search needs a q or an advanced query")
+	}
+	for _, t := range opts.Types {
+		q.Add("type[]", t)
+	}
+	for _, facet := range opts.Facets {
+		q.Add("facet[]", facet)
+	}
+	for field, value := range opts.FilterTerms {
+		src, _ := json.Marshal(map[string]string{field: value})
+		q.Add("filter_term[]", string(src))
+	}
+	if opts.Sort != "" {
+		q.Set("sort", opts.Sort)
+	}
+	return q, nil
+}
+
+// SearchResult is a record found by a search, JSON holds the indexed record
+type SearchResult struct {
+	ID          string   `json:"id"`
+	URI         string   `json:"uri"`
+	Title       string   `json:"title"`
+	PrimaryType string   `json:"primary_type"`
+	Types       []string `json:"types,omitempty"`
+	Publish     bool     `json:"publish"`
+	JSON        string   `json:"json,omitempty"`
+}
+
+// Decode decodes the indexed record into obj (e.g. an *Accession)
+func (r *SearchResult) Decode(obj interface{}) error {
+	if r.JSON == "" {
+		return fmt.Errorf("No record JSON for %s", r.URI)
+	}
+	return json.Unmarshal([]byte(r.JSON), obj)
+}
+
+// FacetCount is the number of results with a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets holds the facet counts of a search, ArchivesSpace returns
+// each field's values and counts as a flat list, e.g. ["accession", 10, "resource", 3]
+type SearchFacets struct {
+	FacetFields map[string][]interface{} `json:"facet_fields,omitempty"`
+}
+
+// Counts returns the value counts for a facet field
+func (f *SearchFacets) Counts(field string) []*FacetCount {
+	var counts []*FacetCount
+	if f == nil {
+		return counts
+	}
+	values := f.FacetFields[field]
+	for i := 0; i+1 < len(values); i += 2 {
+		value := fmt.Sprintf("%v", values[i])
+		count, _ := values[i+1].(float64)
+		counts = append(counts, &FacetCount{Value: value, Count: int(count)})
+	}
+	return counts
+}
+
+// SearchResults is a page of search results
+type SearchResults struct {
+	PageInfo
+	PageSize  int             `json:"page_size"`
+	TotalHits int             `json:"total_hits"`
+	Results   []*SearchResult `json:"results"`
+	Facets    *SearchFacets   `json:"facets,omitempty"`
+}
+
+// Search returns a page (opts.Page, starting at 1) of the records in a
+// repository matching opts, a repoID of 0 searches all repositories
+func (api *ArchivesSpaceAPI) Search(ctx context.Context, repoID int, opts *SearchOptions) (*SearchResults, error) {
+	q, err := opts.query(opts.Page)
+	if err != nil {
+		return nil, err
+	}
+	p := "/search"
+	if repoID > 0 {
+		p = fmt.Sprintf("/repositories/%d/search", repoID)
+	}
+	results := new(SearchResults)
+	if err := api.GetAPI(ctx, api.CallURL(p, q), results); err != nil {
+		return nil, fmt.Errorf("Search(%d) %w", repoID, err)
+	}
+	return results, nil
+}
+
+// EachSearchResult calls fn with each result of a search fetching a page at a time,
+// starting with opts.Page. Iteration stops with the first error returned by fn.
+func (api *ArchivesSpaceAPI) EachSearchResult(ctx context.Context, repoID int, opts *SearchOptions, fn func(*SearchResult) error) error {
+	o := *opts
+	first := o.Page
+	if first < 1 {
+		first = 1
+	}
+	return eachPage(func(page int) (*PageInfo, int, error) {
+		o.Page = first + page - 1
+		results, err := api.Search(ctx, repoID, &o)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, result := range results.Results {
+			if err := fn(result); err != nil {
+				return nil, 0, err
+			}
+		}
+		return &results.PageInfo, len(results.Results), nil
+	})
+}
@@ -0,0 +1,130 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// AgentRelationship is implemented by the agent relationship JSONModels,
+// AgentRelationshipAssociative, AgentRelationshipEarlierlater,
+// AgentRelationshipParentchild and AgentRelationshipSubordinatesuperior
+type AgentRelationship interface {
+	relationshipType() string
+}
+
+func (rel *AgentRelationshipAssociative) relationshipType() string {
+	return "agent_relationship_associative"
+}
+
+func (rel *AgentRelationshipEarlierlater) relationshipType() string {
+	return "agent_relationship_earlierlater"
+}
+
+func (rel *AgentRelationshipParentchild) relationshipType() string {
+	return "agent_relationship_parentchild"
+}
+
+func (rel *AgentRelationshipSubordinatesuperior) relationshipType() string {
+	return "agent_relationship_subordinatesuperior"
+}
+
+// newAgentRelationship returns an empty relationship for a JSONModel type
+func newAgentRelationship(jsonModelType string) (AgentRelationship, error) {
+	switch jsonModelType {
+	case "agent_relationship_associative":
+		return new(AgentRelationshipAssociative), nil
+	case "agent_relationship_earlierlater":
+		return new(AgentRelationshipEarlierlater), nil
+	case "agent_relationship_parentchild":
+		return new(AgentRelationshipParentchild), nil
+	case "agent_relationship_subordinatesuperior":
+		return new(AgentRelationshipSubordinatesuperior), nil
+	}
+	return nil, fmt.Errorf("Unknown agent relationship type %q", jsonModelType)
+}
+
+// Relationships returns the agent's related agents as typed relationships
+func (agent *Agent) Relationships() ([]AgentRelationship, error) {
+	var relationships []AgentRelationship
+	for _, item := range agent.RelatedAgents {
+		jsonModelType, _ := item["jsonmodel_type"].(string)
+		rel, err := newAgentRelationship(jsonModelType)
+		if err != nil {
+			return nil, err
+		}
+		src, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(src, rel); err != nil {
+			return nil, fmt.Errorf("Can't decode %s relationship, %s", jsonModelType, err)
+		}
+		relationships = append(relationships, rel)
+	}
+	return relationships, nil
+}
+
+// AddAgentRelationship adds a relationship (e.g. an AgentRelationshipParentchild
+// with a relator of "is_parent_of" and a ref of "/agents/people/3") to agent
+// and saves the agent. Adding a relationship agent already has is an error.
+func (api *ArchivesSpaceAPI) AddAgentRelationship(ctx context.Context, agent *Agent, rel AgentRelationship) (*ResponseMsg, error) {
+	item := map[string]interface{}{}
+	src, err := json.Marshal(rel)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(src, &item); err != nil {
+		return nil, err
+	}
+	item["jsonmodel_type"] = rel.relationshipType()
+	ref, _ := item["ref"].(string)
+	if ref == "" {
+		return nil, fmt.Errorf("Can't add a %s relationship without a ref", rel.relationshipType())
+	}
+	relator, _ := item["relator"].(string)
+	for _, existing := range agent.RelatedAgents {
+		if existing["jsonmodel_type"] == item["jsonmodel_type"] && existing["relator"] == relator && refString(existing) == ref {
+			return nil, fmt.Errorf("%s already has the relationship %s %s", agent.URI, relator, ref)
+		}
+	}
+	agent.RelatedAgents = append(agent.RelatedAgents, item)
+	return api.UpdateAgent(ctx, agent)
+}
+
+// RemoveAgentRelationship removes agent's relationships to ref and saves
+// the agent, if relator isn't empty only relationships with that relator
+// are removed. It is an error if there is nothing to remove.
+func (api *ArchivesSpaceAPI) RemoveAgentRelationship(ctx context.Context, agent *Agent, relator, ref string) (*ResponseMsg, error) {
+	var related []map[string]interface{}
+	for _, item := range agent.RelatedAgents {
+		if refString(item) == ref && (relator == "" || item["relator"] == relator) {
+			continue
+		}
+		related = append(related, item)
+	}
+	if len(related) == len(agent.RelatedAgents) {
+		return nil, fmt.Errorf("%s has no relationship to %s", agent.URI, ref)
+	}
+	agent.RelatedAgents = related
+	return api.UpdateAgent(ctx, agent)
+}
@@ -0,0 +1,212 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ExportErrorsCollection holds the failures of the last ExportArchivesSpace
+	ExportErrorsCollection = "errors.ds"
+	// ImportErrorsCollection holds the failures of the last ImportArchivesSpace
+	ImportErrorsCollection = "import_errors.ds"
+)
+
+// ExportPolicy says what an export does when a record can't be exported
+type ExportPolicy int
+
+const (
+	// ExportContinue collects failures in an *ExportReport and exports
+	// the remaining records
+	ExportContinue ExportPolicy = iota
+	// ExportFailFast stops the export at the first failure
+	ExportFailFast
+)
+
+// ExportFailure describes a record, or a page of records, which couldn't
+// be exported. Status is the HTTP status when ArchivesSpace returned an error.
+type ExportFailure struct {
+	Collection string `json:"collection"`
+	URI        string `json:"uri"`
+	Status     int    `json:"status,omitempty"`
+	Message    string `json:"message"`
+	Time       string `json:"time"`
+
+	err error
+}
+
+// ExportReport collects the failures of an export. It is returned as the
+// error of an export which wrote the records it could.
+type ExportReport struct {
+	Failures []*ExportFailure `json:"failures"`
+
+	// saved is set once the failures have been written by SaveExportReport
+	saved bool
+	// imported is set when the report describes an ImportArchivesSpace run
+	imported bool
+}
+
+// add records a failure exporting uri to collection
+func (report *ExportReport) add(collection, uri string, err error) {
+	failure := &ExportFailure{
+		Collection: collection,
+		URI:        uri,
+		Message:    err.Error(),
+		Time:       time.Now().UTC().Format(time.RFC3339),
+		err:        err,
+	}
+	apiErr := new(APIError)
+	if errors.As(err, &apiErr) == true {
+		failure.Status = apiErr.StatusCode
+	}
+	report.Failures = append(report.Failures, failure)
+}
+
+// asError returns report if it has failures, nil otherwise
+func (report *ExportReport) asError() error {
+	if len(report.Failures) == 0 {
+		return nil
+	}
+	return report
+}
+
+// merge adds the failures in err to report when err is an *ExportReport,
+// otherwise err is recorded as a failure exporting uri to collection.
+func (report *ExportReport) merge(collection, uri string, err error) {
+	failures := new(ExportReport)
+	if errors.As(err, &failures) == true {
+		report.Failures = append(report.Failures, failures.Failures...)
+		return
+	}
+	report.add(collection, uri, err)
+}
+
+// exportFailed applies api.ExportPolicy to a failure exporting uri, with
+// ExportFailFast err is returned, otherwise it is merged into report.
+func (api *ArchivesSpaceAPI) exportFailed(report *ExportReport, collection, uri string, err error) error {
+	if api.ExportPolicy == ExportFailFast {
+		return err
+	}
+	report.merge(collection, uri, err)
+	return nil
+}
+
+// Summary counts the failures by collection, e.g.
+// "3 records couldn't be exported, agents.ds/people 1, subjects.ds 2"
+func (report *ExportReport) Summary() string {
+	counts := map[string]int{}
+	names := []string{}
+	for _, failure := range report.Failures {
+		if _, ok := counts[failure.Collection]; ok == false {
+			names = append(names, failure.Collection)
+		}
+		counts[failure.Collection]++
+	}
+	sort.Strings(names)
+	verb := "exported"
+	if report.imported == true {
+		verb = "imported"
+	}
+	parts := []string{fmt.Sprintf("%d records couldn't be %s", len(report.Failures), verb)}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Error returns the report's Summary
+func (report *ExportReport) Error() string {
+	return report.Summary()
+}
+
+// exportErrorKey maps a failure to its key in ExportErrorsCollection
+func exportErrorKey(failure *ExportFailure) string {
+	key := failure.URI
+	if key == "" {
+		key = failure.Collection
+	}
+	key = strings.NewReplacer("/", "_", "?", "_", "&", "_", "=", "-").Replace(strings.TrimPrefix(key, "/"))
+	return key + ".json"
+}
+
+// SaveExportReport writes the report's failures to ExportErrorsCollection,
+// or ImportErrorsCollection for an import, and, when api.ExportErrorLog is set, appends them to that file as JSON
+// lines. A report is only saved once.
+func (api *ArchivesSpaceAPI) SaveExportReport(report *ExportReport) error {
+	if report == nil || report.saved == true || len(report.Failures) == 0 {
+		return nil
+	}
+	dir := ExportErrorsCollection
+	if report.imported == true {
+		dir = ImportErrorsCollection
+	}
+	c, err := CreateCollection(api, dir)
+	if err != nil {
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, dir, err)
+	}
+	defer c.Close()
+	for _, failure := range report.Failures {
+		if err := WriteJSON(c, exportErrorKey(failure), failure); err != nil {
+			return fmt.Errorf("Can't save export failure for %s, %w", failure.URI, err)
+		}
+	}
+	if api.ExportErrorLog != "" {
+		fp, err := os.OpenFile(api.ExportErrorLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0664)
+		if err != nil {
+			return fmt.Errorf("Can't open %s, %w", api.ExportErrorLog, err)
+		}
+		defer fp.Close()
+		enc := json.NewEncoder(fp)
+		for _, failure := range report.Failures {
+			if err := enc.Encode(failure); err != nil {
+				return fmt.Errorf("Can't write %s, %w", api.ExportErrorLog, err)
+			}
+		}
+	}
+	report.saved = true
+	return nil
+}
+
+// clearExportErrors removes the failures saved by a previous export
+func (api *ArchivesSpaceAPI) clearExportErrors() error {
+	return api.clearErrors(ExportErrorsCollection)
+}
+
+// clearErrors removes the failures saved in the collection dir
+func (api *ArchivesSpaceAPI) clearErrors(dir string) error {
+	c, err := CreateCollection(api, dir)
+	if err != nil {
+		return fmt.Errorf("Can't open collection %s/%s, %w", api.Dataset, dir, err)
+	}
+	defer c.Close()
+	for _, key := range GetKeys(c) {
+		if err := c.Delete(key); err != nil {
+			return fmt.Errorf("Can't remove %s/%s, %w", dir, key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes how API() handles transient failures. Idempotent
+// requests (GET, PUT, DELETE, HEAD, OPTIONS) are retried with exponential
+// backoff and jitter when ArchivesSpace responds with a 5xx or 429 status
+// or the connection fails. If Relogin is true an expired session is
+// renewed once with Login() and the request replayed, this applies to
+// POST requests as well since ArchivesSpace rejects them before processing.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int `json:"max_retries"`
+	// BaseDelay is the delay before the first retry, it doubles each retry
+	BaseDelay time.Duration `json:"base_delay"`
+	// MaxDelay caps the backoff delay, including one set by a Retry-After header
+	MaxDelay time.Duration `json:"max_delay"`
+	// Relogin when true will call Login() once if the session has expired
+	Relogin bool `json:"relogin"`
+}
+
+// DefaultRetryPolicy returns the policy used by New()
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries: 5,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+		Relogin:    true,
+	}
+}
+
+// backoff returns how long to wait before retry number attempt (zero based).
+// A Retry-After header, in seconds or as an HTTP date, takes precedence
+// but is still capped by MaxDelay.
+func (policy *RetryPolicy) backoff(attempt int, header http.Header) time.Duration {
+	if header != nil {
+		if s := header.Get("Retry-After"); s != "" {
+			if i, err := strconv.Atoi(s); err == nil && i >= 0 {
+				return policy.capDelay(time.Duration(i) * time.Second)
+			}
+			if t, err := http.ParseTime(s); err == nil {
+				if d := time.Until(t); d > 0 {
+					return policy.capDelay(d)
+				}
+				return 0
+			}
+		}
+	}
+	d := policy.BaseDelay << uint(attempt)
+	if d <= 0 || (policy.MaxDelay > 0 && d > policy.MaxDelay) {
+		d = policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter, wait at least half the delay
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// capDelay bounds d by MaxDelay, when set
+func (policy *RetryPolicy) capDelay(d time.Duration) time.Duration {
+	if policy.MaxDelay > 0 && d > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+	return d
+}
+
+// RetryStats returns the number of retries and re-logins performed by API()
+// since the ArchivesSpaceAPI was created.
+func (api *ArchivesSpaceAPI) RetryStats() (int, int) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.retries, api.relogins
+}
+
+// count increments a retry or relogin counter
+func (api *ArchivesSpaceAPI) count(counter *int) {
+	api.mu.Lock()
+	*counter++
+	api.mu.Unlock()
+}
+
+func isIdempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// isTransient reports if a request error or HTTP status is worth retrying
+func isTransient(status int, err error) bool {
+	if err != nil {
+		// Timeouts, connection resets, refused connections and
+		// unexpected EOFs all surface as a *url.Error.
+		return true
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// isSessionExpired reports if ArchivesSpace rejected the session token
+func isSessionExpired(status int, content []byte) bool {
+	if status != http.StatusPreconditionFailed && status != http.StatusForbidden && status != http.StatusUnauthorized {
+		return false
+	}
+	return bytes.Contains(content, []byte("SESSION_GONE")) || bytes.Contains(content, []byte("SESSION_EXPIRED"))
+}
+
+// relogin renews the session unless another go routine has already
+// replaced the stale token.
+func (api *ArchivesSpaceAPI) relogin(ctx context.Context, stale string) error {
+	api.loginMu.Lock()
+	defer api.loginMu.Unlock()
+	if api.token() != stale {
+		return nil
+	}
+	api.setToken("")
+	if err := api.Login(ctx); err != nil {
+		return err
+	}
+	api.count(&api.relogins)
+	return nil
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// send performs a single HTTP request, the caller closes the response body
+func (api *ArchivesSpaceAPI) send(ctx context.Context, method string, u string, contentType string, payload []byte, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("X-ArchivesSpace-Session", token)
+	req.Header.Set("Content-Type", contentType)
+	res, err := api.client().Do(req)
+	if err != nil {
+		// Transport errors include the URL, keep credentials out of them
+		if urlErr, ok := err.(*url.Error); ok == true {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// do performs a single HTTP request returning the response with its body
+// already read and closed.
+func (api *ArchivesSpaceAPI) do(ctx context.Context, method string, u string, contentType string, payload []byte, token string) (*http.Response, []byte, error) {
+	res, err := api.send(ctx, method, u, contentType, payload, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return res, nil, err
+	}
+	return res, content, nil
+}
+
+// doWithRetry calls do() applying api.Retry. It returns the last response
+// and body or the last error.
+func (api *ArchivesSpaceAPI) doWithRetry(ctx context.Context, method string, u string, contentType string, payload []byte) (*http.Response, []byte, error) {
+	policy := api.Retry
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+	relogged := false
+	for attempt := 0; ; attempt++ {
+		token := api.token()
+		res, content, err := api.do(ctx, method, u, contentType, payload, token)
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		if err == nil && policy.Relogin && relogged == false && api.Username != "" && isSessionExpired(status, content) {
+			relogged = true
+			if api.Verbose {
+				log.Printf("Session expired, logging in again and replaying %s %s", method, redactURL(u))
+			}
+			if err := api.relogin(ctx, token); err != nil {
+				return res, content, err
+			}
+			attempt--
+			continue
+		}
+		if attempt < policy.MaxRetries && isIdempotent(method) && ctx.Err() == nil && isTransient(status, err) {
+			var header http.Header
+			if res != nil {
+				header = res.Header
+			}
+			delay := policy.backoff(attempt, header)
+			api.count(&api.retries)
+			if api.Verbose {
+				if err != nil {
+					log.Printf("Retry %d of %d for %s %s in %s, %s", attempt+1, policy.MaxRetries, method, redactURL(u), delay, err)
+				} else {
+					log.Printf("Retry %d of %d for %s %s in %s, %s", attempt+1, policy.MaxRetries, method, redactURL(u), delay, res.Status)
+				}
+			}
+			if err := sleep(ctx, delay); err != nil {
+				return res, content, err
+			}
+			continue
+		}
+		return res, content, err
+	}
+}
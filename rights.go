@@ -0,0 +1,121 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GetCollectionManagement retrieves a collection management record from a Repository
+func (api *ArchivesSpaceAPI) GetCollectionManagement(ctx context.Context, repoID, cmID int) (*CollectionManagement, error) {
+	cm := new(CollectionManagement)
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/collection_management/%d", repoID, cmID), nil)
+	if err := api.GetAPI(ctx, u, cm); err != nil {
+		return nil, fmt.Errorf("GetCollectionManagement() %s, error, %w", u, err)
+	}
+	return cm, nil
+}
+
+// ListCollectionManagement returns a list of collection management ids in a Repository
+func (api *ArchivesSpaceAPI) ListCollectionManagement(ctx context.Context, repoID int) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(fmt.Sprintf(`/repositories/%d/collection_management`, repoID), q))
+}
+
+// ResolveCollectionManagement returns the saved collection management record
+// for cm, an embedded record that may only carry its uri. cm is returned
+// as is when it hasn't been saved yet and nil when cm is nil.
+func (api *ArchivesSpaceAPI) ResolveCollectionManagement(ctx context.Context, cm *CollectionManagement) (*CollectionManagement, error) {
+	if cm == nil || cm.URI == "" {
+		return cm, nil
+	}
+	resolved, err := api.GetCollectionManagement(ctx, URIToRepoID(cm.URI), URIToID(cm.URI))
+	if err != nil {
+		return nil, fmt.Errorf("Can't resolve collection management %s, %w", cm.URI, err)
+	}
+	return resolved, nil
+}
+
+// GetAccessionCollectionManagement returns the collection management record of an accession
+func (api *ArchivesSpaceAPI) GetAccessionCollectionManagement(ctx context.Context, accession *Accession) (*CollectionManagement, error) {
+	return api.ResolveCollectionManagement(ctx, accession.CollectionManagement)
+}
+
+// GetResourceCollectionManagement returns the collection management record of a resource
+func (api *ArchivesSpaceAPI) GetResourceCollectionManagement(ctx context.Context, resource *Resource) (*CollectionManagement, error) {
+	return api.ResolveCollectionManagement(ctx, resource.CollectionManagement)
+}
+
+// RightsStatementAgent is an agent linked to a rights statement, Role is
+// the role from the link (e.g. "rights_holder")
+type RightsStatementAgent struct {
+	Statement *RightsStatement `json:"rights_statement,omitempty"`
+	Role      string           `json:"role,omitempty"`
+	Agent     *Agent           `json:"agent,omitempty"`
+}
+
+// ResolveRightsStatements resolves the linked agents of each rights statement
+// (e.g. [{"role": "rights_holder", "ref": "/agents/people/3"}]) in the order
+// they are listed. Agents are fetched once per call even if they are linked
+// from several statements.
+func (api *ArchivesSpaceAPI) ResolveRightsStatements(ctx context.Context, statements []*RightsStatement) ([]*RightsStatementAgent, error) {
+	agents := map[string]*Agent{}
+	var results []*RightsStatementAgent
+	for _, statement := range statements {
+		for _, item := range statement.LinkedAgents {
+			ref := refString(item)
+			// Agent refs look like /agents/people/3
+			p := strings.Split(ref, "/")
+			if len(p) != 4 || p[1] != "agents" {
+				continue
+			}
+			agent, ok := agents[ref]
+			if ok == false {
+				var err error
+				agent, err = api.GetAgent(ctx, p[2], URIToID(ref))
+				if err != nil {
+					return nil, fmt.Errorf("Can't resolve agent %s, %w", ref, err)
+				}
+				agents[ref] = agent
+			}
+			role, _ := item["role"].(string)
+			results = append(results, &RightsStatementAgent{
+				Statement: statement,
+				Role:      role,
+				Agent:     agent,
+			})
+		}
+	}
+	return results, nil
+}
+
+// GetAccessionRightsStatements returns the agents linked to the rights statements of an accession
+func (api *ArchivesSpaceAPI) GetAccessionRightsStatements(ctx context.Context, accession *Accession) ([]*RightsStatementAgent, error) {
+	return api.ResolveRightsStatements(ctx, accession.RightsStatements)
+}
+
+// GetResourceRightsStatements returns the agents linked to the rights statements of a resource
+func (api *ArchivesSpaceAPI) GetResourceRightsStatements(ctx context.Context, resource *Resource) ([]*RightsStatementAgent, error) {
+	return api.ResolveRightsStatements(ctx, resource.RightsStatements)
+}
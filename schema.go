@@ -1,4 +1,3 @@
-//
 // Package cait is a collection of structures and functions
 // for interacting with ArchivesSpace's REST API
 //
@@ -16,16 +15,17 @@
 // 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
 //
 // THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
-//
 package cait
 
 import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 //
@@ -38,7 +38,6 @@ import (
 // with the ArchicesSpace REST API
 type ArchivesSpaceAPI struct {
 	BaseURL      *url.URL `json:"api_url"`
-	CallURL      *url.URL `json:"api_call_url"`
 	AuthToken    string   `json:"token,omitempty"`
 	Username     string   `json:"username,omitempty"`
 	Password     string   `json:"password,omitempty"`
@@ -47,6 +46,52 @@ type ArchivesSpaceAPI struct {
 	Htdocs       string   `json:"htdocs,omitempty"`
 	HtdocsIndex  string   `json:"htdocs_index,omitempty"`
 	Templates    string   `json:"templates,omitempty"`
+
+	// HTTPClient is used for all requests to ArchivesSpace, if nil
+	// then http.DefaultClient is used.
+	HTTPClient *http.Client `json:"-"`
+	// Retry controls retries and re-login in API(), if nil requests
+	// are attempted once.
+	Retry *RetryPolicy `json:"-"`
+	// Verbose logs retries and re-logins
+	Verbose bool `json:"-"`
+	// ValidateEnumerations checks controlled values (e.g. extent_type,
+	// date_type) against the cached enumerations before records are
+	// created or updated
+	ValidateEnumerations bool `json:"-"`
+	// Incremental exports only fetch records modified since the
+	// previous export and remove records deleted from ArchivesSpace
+	Incremental bool `json:"-"`
+	// MarkDeleted marks deleted records with "_deleted" during an
+	// incremental export instead of removing them
+	MarkDeleted bool `json:"-"`
+	// ExportWorkers is the number of pages of records an export fetches
+	// and writes at once, less than one means one page at a time
+	ExportWorkers int `json:"-"`
+	// ExportProgress, if set, is called as an export writes each page
+	ExportProgress ExportProgressFunc `json:"-"`
+	// Resume continues the last ExportArchivesSpace from its checkpoint,
+	// skipping finished stages
+	Resume bool `json:"-"`
+	// ExportPolicy is what an export does when a record fails, the
+	// default ExportContinue exports the rest and reports the failures
+	ExportPolicy ExportPolicy `json:"-"`
+	// ExportErrorLog, if set, is a JSONL file export failures are appended to
+	ExportErrorLog string `json:"-"`
+	// Layout maps record types to collection names, DefaultLayout is
+	// used when nil
+	Layout Layout `json:"-"`
+
+	// mu guards AuthToken so a single ArchivesSpaceAPI can be shared
+	// between go routines.
+	mu sync.RWMutex
+	// loginMu serializes re-logins after a session expires
+	loginMu sync.Mutex
+	// retries and relogins count what API() has done, guarded by mu
+	retries  int
+	relogins int
+	// enums caches the enumeration table, guarded by mu
+	enums *EnumerationTable
 }
 
 // ResponseMsg is a structure to hold the JSON portion of a response from the ArchivesSpaceAPI
@@ -78,6 +123,7 @@ type AbstractAgent struct {
 	AgentContacts             []*AgentContact          `json:"agent_contacts,omitempty"`
 	LinkedAgentRoles          []interface{}            `json:"linked_agent_roles,omitempty"`
 	ExternalDocuments         []map[string]interface{} `json:"external_documents"`
+	RelatedAgents             []map[string]interface{} `json:"related_agents,omitempty"`
 	RightsStatements          []*RightsStatement       `json:"rights_statements"`
 	SystemGenerated           bool                     `json:"system_generated,omitempty"`
 	Notes                     []*NoteText              `json:"notes,omitmepty"`
@@ -221,7 +267,7 @@ type Accession struct {
 	UserDefined            *UserDefined             `json:"user_defined,omitempty"`
 	RelatedResources       []map[string]interface{} `json:"related_resources,omitempty"`
 	Suppressed             bool                     `json:"suppressed"`
-	AcquisitionType        string                   `json:"acquision_type,omitempty"`
+	AcquisitionType        string                   `json:"acquisition_type,omitempty"`
 	ResourceType           string                   `json:"resource_type"`
 	RestrictionsApply      bool                     `json:"restrictions_apply"`
 	RetentionRule          string                   `json:"retention_rule,omitempty"`
@@ -297,7 +343,7 @@ type ActiveEdits struct {
 
 // AdvancedQuery JSONModel(:advanced_query)
 type AdvancedQuery struct {
-	Query map[string]interface{} `json:"query,omitempty"` //FIXME, maybe this should be an interface to boolean_query, field_query, data_field_query,boolean_field_query and Object?
+	Query QueryClause `json:"query,omitempty"` // One of BooleanQuery, FieldQuery, DateFieldQuery or BooleanFieldQuery
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -323,6 +369,7 @@ type Agent struct {
 	AgentContacts             []*AgentContact          `json:"agent_contacts,omitempty"`
 	LinkedAgentRoles          []interface{}            `json:"linked_agent_roles,omitempty"`
 	ExternalDocuments         []map[string]interface{} `json:"external_documents"`
+	RelatedAgents             []map[string]interface{} `json:"related_agents,omitempty"`
 
 	//	RightsStatements          []*RightsStatement       `json:"rights_statements"`
 	RightsStatements []interface{}   `json:"rights_statements"`
@@ -466,7 +513,7 @@ type AgentRelationshipAssociative struct {
 	Repository     map[string]string `json:"repository,omitempty"`
 
 	Relator  string                 `json:"relator,omitempty"`
-	Ref      map[string]interface{} `json:"ref,omitempty"`
+	Ref      string                 `json:"ref,omitempty"`
 	Resolved map[string]interface{} `json:"_resolved,omitempty"`
 }
 
@@ -485,7 +532,7 @@ type AgentRelationshipEarlierlater struct {
 	Repository     map[string]string `json:"repository,omitempty"`
 
 	Relator  string                 `json:"relator,omitempty"`
-	Ref      map[string]interface{} `json:"ref,omitempty"`
+	Ref      string                 `json:"ref,omitempty"`
 	Resolved map[string]interface{} `json:"_resolved,omitempty"`
 }
 
@@ -504,7 +551,7 @@ type AgentRelationshipParentchild struct {
 	Repository     map[string]string `json:"repository,omitempty"`
 
 	Relator  string                 `json:"relator,omitempty"`
-	Ref      map[string]interface{} `json:"ref,omitempty"`
+	Ref      string                 `json:"ref,omitempty"`
 	Resolved map[string]interface{} `json:"_resolved,omitempty"`
 }
 
@@ -523,7 +570,7 @@ type AgentRelationshipSubordinatesuperior struct {
 	Repository     map[string]string `json:"repository,omitempty"`
 
 	Relator  string                 `json:"relator,omitempty"`
-	Ref      map[string]interface{} `json:"ref,omitempty"`
+	Ref      string                 `json:"ref,omitempty"`
 	Resolved map[string]interface{} `json:"_resolved,omitempty"`
 }
 
@@ -557,6 +604,7 @@ type AgentSoftware struct {
 
 // ArchivalObject JSONModel(:archival_object)
 type ArchivalObject struct {
+	ID                int                      `json:"id,omitempty"`
 	URI               string                   `json:"uri,omitempty"`
 	ExternalIDs       []*ExternalID            `json:"external_ids"`
 	Title             string                   `json:"title,omitempty"`
@@ -612,7 +660,7 @@ type ArchivalRecordChildren struct {
 // BooleanFieldQuery JSONModel(:boolean_field_query)
 type BooleanFieldQuery struct {
 	Field string `json:"field,omitempty"`
-	Value bool   `json:"value,omitempty"`
+	Value bool   `json:"value"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -626,9 +674,8 @@ type BooleanFieldQuery struct {
 
 // BooleanQuery JSONModel(:boolean_query)
 type BooleanQuery struct {
-	Op string `json:"op,omitempty"` // ENUM as: string AND OR NOT
-	//FIXME: this needs to be re-thought, do I use an interface type, a struct?
-	Subqueries map[string]interface{} `json:"subqueries,omitempty"` // One of 	JSONModel(:boolean_query) object,JSONModel(:field_query) object,JSONModel(:boolean_field_query) object,JSONModel(:date_field_query) object
+	Op         string        `json:"op,omitempty"`         // ENUM as: string AND OR NOT
+	Subqueries []QueryClause `json:"subqueries,omitempty"` // Each one of BooleanQuery, FieldQuery, DateFieldQuery or BooleanFieldQuery
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -642,34 +689,37 @@ type BooleanQuery struct {
 
 // Classification JSONModel(:classification)
 type Classification struct {
-	URI           string                 `json:"uri,omitempty"`
-	Identifier    string                 `json:"identifier,omitempty"`
-	Title         string                 `json:"title,omitempty"`
-	Description   string                 `json:"description,omitempty"`
-	Publish       bool                   `json:"publish,omitempty"` //NOTE: default should true
-	PathFromRoot  map[string]interface{} `json:"path_from_root,omitempty"`
-	LinkedRecords map[string]interface{} `json:"linked_records,omitempty"`
-	Creator       map[string]interface{} `json:"creator,omitempty"`
+	URI           string                   `json:"uri,omitempty"`
+	ID            int                      `json:"id,omitempty"`
+	Identifier    string                   `json:"identifier,omitempty"`
+	Title         string                   `json:"title,omitempty"`
+	Description   string                   `json:"description,omitempty"`
+	Publish       bool                     `json:"publish,omitempty"` //NOTE: default should true
+	PathFromRoot  []map[string]interface{} `json:"path_from_root,omitempty"`
+	LinkedRecords []map[string]interface{} `json:"linked_records,omitempty"`
+	Creator       map[string]interface{}   `json:"creator,omitempty"`
 
-	LockVersion    json.Number `json:"lock_version,Number"`
-	JSONModelType  string      `json:"jsonmodel_type,omitempty"`
-	CreatedBy      string      `json:"created_by,omitempty,omitempty"`
-	LastModifiedBy string      `json:"last_modified_by,omitempty"`
-	UserMTime      string      `json:"user_mtime,omitempty,omitempty"`
-	SystemMTime    string      `json:"system_mtime,omitempty,omitempty"`
-	CreateTime     string      `json:"create_time,omitempty,omitempty"`
+	LockVersion    json.Number       `json:"lock_version,Number"`
+	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
+	CreatedBy      string            `json:"created_by,omitempty,omitempty"`
+	LastModifiedBy string            `json:"last_modified_by,omitempty"`
+	UserMTime      string            `json:"user_mtime,omitempty,omitempty"`
+	SystemMTime    string            `json:"system_mtime,omitempty,omitempty"`
+	CreateTime     string            `json:"create_time,omitempty,omitempty"`
+	Repository     map[string]string `json:"repository,omitempty"`
 }
 
 // ClassificationTerm JSONModel(:classification_term)
 type ClassificationTerm struct {
-	URI           string                 `json:"uri,omitempty"`
-	Identifier    string                 `json:"identifier,omitempty"`
-	Title         string                 `json:"title,omitempty"`
-	Description   string                 `json:"description,omitempty"`
-	Publish       bool                   `json:"publish,omitempty"` //NOTE: default should true
-	PathFromRoot  map[string]interface{} `json:"path_from_root,omitempty"`
-	LinkedRecords map[string]interface{} `json:"linked_records,omitempty"`
-	Creator       map[string]interface{} `json:"creator,omitempty"`
+	URI           string                   `json:"uri,omitempty"`
+	ID            int                      `json:"id,omitempty"`
+	Identifier    string                   `json:"identifier,omitempty"`
+	Title         string                   `json:"title,omitempty"`
+	Description   string                   `json:"description,omitempty"`
+	Publish       bool                     `json:"publish,omitempty"` //NOTE: default should true
+	PathFromRoot  []map[string]interface{} `json:"path_from_root,omitempty"`
+	LinkedRecords []map[string]interface{} `json:"linked_records,omitempty"`
+	Creator       map[string]interface{}   `json:"creator,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -780,10 +830,10 @@ type Container struct {
 // ContainerLocation JSONModel(:container_location)
 type ContainerLocation struct {
 	Status    string                 `json:"status,omitempty"`
-	StartDate *Date                  `json:"start_date,omitempty"`
-	EndDate   *Date                  `json:"end_date,omitempty"`
+	StartDate string                 `json:"start_date,omitempty"`
+	EndDate   string                 `json:"end_date,omitempty"`
 	Note      string                 `json:"note,omitempty"`
-	Ref       string                 `json:"location,omitempty"`
+	Ref       string                 `json:"ref,omitempty"`
 	Resolved  map[string]interface{} `json:"_resolved,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
@@ -798,6 +848,7 @@ type ContainerLocation struct {
 
 // ContainerProfile JSONModel(:container_profile)
 type ContainerProfile struct {
+	ID              int    `json:"id,omitempty"`
 	URI             string `json:"uri,omitempty"`
 	Name            string `json:"name,omitempty"`
 	URL             string `json:"url,omitempty"`
@@ -805,7 +856,7 @@ type ContainerProfile struct {
 	ExtentDimension string `json:"extent_dimension,omitempty" ` //ENUM as: height width depth
 	Height          string `json:"height,omitempty"`
 	Width           string `json:"width,omitempty"`
-	Depth           string `json:"width,omitempty"`
+	Depth           string `json:"depth,omitempty"`
 	DisplayString   string `json:"display_string,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
@@ -843,7 +894,7 @@ type Date struct {
 type DateFieldQuery struct {
 	Comparator string `json:"comparator,omitempty"` // ENUM as: greater_than lesser_than equal
 	Field      string `json:"field,omitempty"`
-	Value      *Date  `json:"value,omitempty"`
+	Value      string `json:"value,omitempty"` // e.g. 2016-01-02
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -959,10 +1010,15 @@ type DigitalObject struct {
 	CollectionManagement *CollectionManagement    `json:"collection_management,omitempty"`
 	UserDefined          []map[string]interface{} `json:"user_defined,omitmepty"`
 	LinkedInstances      []map[string]interface{} `json:"linked_instances,omitempty"`
+
+	// Components are the digital object's components in tree order, they
+	// are attached by cait and are not part of the JSONModel
+	Components []*DigitalObjectComponent `json:"-"`
 }
 
 // DigitalObjectComponent JSONModel(:digital_object_component)
 type DigitalObjectComponent struct {
+	ID                int                      `json:"id,omitempty"`
 	URI               string                   `json:"uri,omitempty"`
 	ExternalIDs       []*ExternalID            `json:"external_ids,omitempty"`
 	Title             string                   `json:"title,omitempty"`
@@ -991,7 +1047,7 @@ type DigitalObjectComponent struct {
 	DisplayString          string                 `json:"display_string,omitempty"`
 	FileVersions           []*FileVersion         `json:"file_versions,omitempty"`
 	Parent                 map[string]interface{} `json:"parent,omitempty"`
-	DigitalObject          *DigitalObject         `json:"digital_object,omitempty"`
+	DigitalObject          map[string]interface{} `json:"digital_object,omitempty"`
 	Position               int                    `json:"position,omitempty"`
 	Notes                  []*NoteText            `json:"notes,omitempty"`
 	HasUnpublishedAncestor bool                   `json:"has_unpublished_ancestor,omitempty"`
@@ -1039,6 +1095,7 @@ type DigitalRecordChildren struct {
 
 // Enumeration JSONModel(:enumeration)
 type Enumeration struct {
+	ID                int                 `json:"id,omitempty"`
 	URI               string              `json:"uri,omitempty"`
 	Name              string              `json:"name,omitempty"`
 	DefaultValue      string              `json:"default_value,omitempty"`
@@ -1060,10 +1117,10 @@ type Enumeration struct {
 
 // EnumerationMigration JSONModel(:enumeration_migration)
 type EnumerationMigration struct {
-	URI     string       `json:"uri,omitempty"`
-	EnumURI *Enumeration `json:"enum_uri,omitempty"`
-	From    string       `json:"from,omitempty"`
-	To      string       `json:"to,omitempty"`
+	URI     string `json:"uri,omitempty"`
+	EnumURI string `json:"enum_uri,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1077,6 +1134,7 @@ type EnumerationMigration struct {
 
 // EnumerationValue JSONModel(:enumeration_value)
 type EnumerationValue struct {
+	ID         int    `json:"id,omitempty"`
 	URI        string `json:"uri,omitempty"`
 	Value      string `json:"value,omitempty"`
 	Position   int    `json:"position,omitempty"`
@@ -1094,6 +1152,7 @@ type EnumerationValue struct {
 
 // Event JSONModel(:event)
 type Event struct {
+	ID                int                      `json:"id,omitempty"`
 	URI               string                   `json:"uri,omitempty"`
 	ExternalIDs       []*ExternalID            `json:"external_ids,omitempty"`
 	ExternalDocuments []map[string]interface{} `json:"external_documents,omitempty"`
@@ -1103,8 +1162,8 @@ type Event struct {
 	Outcome           string                   `json:"outcome,omitempty"`
 	OutcomeNote       string                   `json:"outcome_note,omitempty"`
 	Suppressed        bool                     `json:"suppressed,omitempty"`
-	LinkedAgents      []*Agent                 `json:"linked_agents,omitempty"`
-	LinkedRecords     map[string]interface{}   `json:"linked_records,omitempty"`
+	LinkedAgents      []map[string]interface{} `json:"linked_agents,omitempty"`
+	LinkedRecords     []map[string]interface{} `json:"linked_records,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1227,6 +1286,7 @@ type FindAndReplaceJob struct {
 
 // Group JSONModel(:group)
 type Group struct {
+	ID                int      `json:"id,omitempty"`
 	URI               string   `json:"uri,omitempty"`
 	GroupCode         string   `json:"group_code,omitempty"`
 	Description       string   `json:"description,omitempty"`
@@ -1277,6 +1337,7 @@ type Instance struct {
 
 // Job JSONModel(:job)
 type Job struct {
+	ID            int                    `json:"id,omitempty"`
 	URI           string                 `json:"uri,omitempty"`
 	JobType       string                 `json:"job_type,omitempty"`
 	Job           map[string]interface{} `json:"job,omitempty"`
@@ -1305,18 +1366,18 @@ type Location struct {
 	Title                string        `json:"title,omitempty"`
 	ExternalIDs          []*ExternalID `json:"external_ids,omitempty"`
 	Building             string        `json:"building,omitempty"`
-	Floor                string        `json:"Floor,omitempty"`
-	Room                 string        `json:"Room,omitempty"`
+	Floor                string        `json:"floor,omitempty"`
+	Room                 string        `json:"room,omitempty"`
 	Area                 string        `json:"area,omitempty"`
 	Barcode              string        `json:"barcode,omitempty"`
-	Classification       `json:"string,omitempty"`
-	Coordinate1Label     string `json:"coordinatel_1_label"`
-	Coordinate1Indicator string `json:"coordinate_1_indicator,omitempty"`
-	Coordinate2Label     string `json:"coordinate_2_label,omitempty"`
-	Coordinate2Indicator string `json:"coordinate_2_indicator,omitempty"`
-	Coordinate3Label     string `json:"coordinate_3_label,omitempty"`
-	Coordinate3Indicator string `json:"coordinate_3_indicator,omitempty"`
-	Temporary            string `json:"temporary,omitempty"`
+	Classification       string        `json:"classification,omitempty"`
+	Coordinate1Label     string        `json:"coordinate_1_label,omitempty"`
+	Coordinate1Indicator string        `json:"coordinate_1_indicator,omitempty"`
+	Coordinate2Label     string        `json:"coordinate_2_label,omitempty"`
+	Coordinate2Indicator string        `json:"coordinate_2_indicator,omitempty"`
+	Coordinate3Label     string        `json:"coordinate_3_label,omitempty"`
+	Coordinate3Indicator string        `json:"coordinate_3_indicator,omitempty"`
+	Temporary            string        `json:"temporary,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1328,24 +1389,34 @@ type Location struct {
 	Repository     map[string]string `json:"repository,omitempty"`
 }
 
+// CoordinateRange describes the coordinates generated by a LocationBatch,
+// Start and End are numbers (e.g. "1" and "10") or letters (e.g. "A" and "F")
+type CoordinateRange struct {
+	Label  string `json:"label,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	Start  string `json:"start,omitempty"`
+	End    string `json:"end,omitempty"`
+}
+
 // LocationBatch JSONModel(:location_batch)
 type LocationBatch struct {
 	URI                  string        `json:"uri,omitempty"`
 	Title                string        `json:"title,omitempty"`
 	ExternalIDs          []*ExternalID `json:"external_ids,omitempty"`
 	Building             string        `json:"building,omitempty"`
-	Floor                string        `json:"Floor,omitempty"`
-	Room                 string        `json:"Room,omitempty"`
+	Floor                string        `json:"floor,omitempty"`
+	Room                 string        `json:"room,omitempty"`
 	Area                 string        `json:"area,omitempty"`
 	Barcode              string        `json:"barcode,omitempty"`
-	Classification       `json:"string,omitempty"`
-	Coordinate1Label     string `json:"coordinatel_1_label"`
-	Coordinate1Indicator string `json:"coordinate_1_indicator,omitempty"`
-	Coordinate2Label     string `json:"coordinate_2_label,omitempty"`
-	Coordinate2Indicator string `json:"coordinate_2_indicator,omitempty"`
-	Coordinate3Label     string `json:"coordinate_3_label,omitempty"`
-	Coordinate3Indicator string `json:"coordinate_3_indicator,omitempty"`
-	Temporary            string `json:"temporary,omitempty"`
+	Classification       string        `json:"classification,omitempty"`
+	Coordinate1Label     string        `json:"coordinate_1_label,omitempty"`
+	Coordinate1Indicator string        `json:"coordinate_1_indicator,omitempty"`
+	Coordinate2Label     string        `json:"coordinate_2_label,omitempty"`
+	Coordinate2Indicator string        `json:"coordinate_2_indicator,omitempty"`
+	Coordinate3Label     string        `json:"coordinate_3_label,omitempty"`
+	Coordinate3Indicator string        `json:"coordinate_3_indicator,omitempty"`
+	Temporary            string        `json:"temporary,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1356,10 +1427,10 @@ type LocationBatch struct {
 	CreateTime     string            `json:"create_time,omitempty,omitempty"`
 	Repository     map[string]string `json:"repository,omitempty"`
 
-	Locations        []*Location            `json:"locations,omitempty"`
-	Coordinate1Range map[string]interface{} `json:"coordinate_1_range,omitempty"`
-	Coordinate2Range map[string]interface{} `json:"coordinate_2_range,omitempty"`
-	Coordinate3Range map[string]interface{} `json:"coordinate_3_range,omitempty"`
+	Locations        []*Location      `json:"locations,omitempty"`
+	Coordinate1Range *CoordinateRange `json:"coordinate_1_range,omitempty"`
+	Coordinate2Range *CoordinateRange `json:"coordinate_2_range,omitempty"`
+	Coordinate3Range *CoordinateRange `json:"coordinate_3_range,omitempty"`
 }
 
 // LocationBatchUpdate JSONModel(:location_batch_update)
@@ -1368,18 +1439,18 @@ type LocationBatchUpdate struct {
 	Title                string        `json:"title,omitempty"`
 	ExternalIDs          []*ExternalID `json:"external_ids,omitempty"`
 	Building             string        `json:"building,omitempty"`
-	Floor                string        `json:"Floor,omitempty"`
-	Room                 string        `json:"Room,omitempty"`
+	Floor                string        `json:"floor,omitempty"`
+	Room                 string        `json:"room,omitempty"`
 	Area                 string        `json:"area,omitempty"`
 	Barcode              string        `json:"barcode,omitempty"`
-	Classification       `json:"string,omitempty"`
-	Coordinate1Label     string `json:"coordinatel_1_label"`
-	Coordinate1Indicator string `json:"coordinate_1_indicator,omitempty"`
-	Coordinate2Label     string `json:"coordinate_2_label,omitempty"`
-	Coordinate2Indicator string `json:"coordinate_2_indicator,omitempty"`
-	Coordinate3Label     string `json:"coordinate_3_label,omitempty"`
-	Coordinate3Indicator string `json:"coordinate_3_indicator,omitempty"`
-	Temporary            string `json:"temporary,omitempty"`
+	Classification       string        `json:"classification,omitempty"`
+	Coordinate1Label     string        `json:"coordinate_1_label,omitempty"`
+	Coordinate1Indicator string        `json:"coordinate_1_indicator,omitempty"`
+	Coordinate2Label     string        `json:"coordinate_2_label,omitempty"`
+	Coordinate2Indicator string        `json:"coordinate_2_indicator,omitempty"`
+	Coordinate3Label     string        `json:"coordinate_3_label,omitempty"`
+	Coordinate3Indicator string        `json:"coordinate_3_indicator,omitempty"`
+	Temporary            string        `json:"temporary,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1390,14 +1461,14 @@ type LocationBatchUpdate struct {
 	CreateTime     string            `json:"create_time,omitempty,omitempty"`
 	Repository     map[string]string `json:"repository,omitempty"`
 
-	RecordURIs []*Location `json:"record_uris,omitempty"`
+	RecordURIs []string `json:"record_uris,omitempty"`
 }
 
 // MergeRequest JSONModel(:merge_request)
 type MergeRequest struct {
-	URI     string                 `json:"uri,omitempty"`
-	Target  map[string]interface{} `json:"target,omitempty"`
-	Victims map[string]interface{} `json:"victims,omitempty"`
+	URI     string                   `json:"uri,omitempty"`
+	Target  map[string]interface{}   `json:"target,omitempty"`
+	Victims []map[string]interface{} `json:"victims,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1946,10 +2017,9 @@ type Resource struct {
 	Dates             []*Date                  `json:"dates,omitempty"`
 	ExternalDocuments []map[string]interface{} `json:"external_documents,omitempty"`
 
-	//	RightsStatements  []*RightsStatement       `json:"rights_statement"`
-	RightsStatements []interface{} `json:"rights_statements,omitempty"`
-	LinkedAgents     []*Agent      `json:"linked_agents,ommitempty"`
-	Suppressed       bool          `json:"suppressed,omitempty"`
+	RightsStatements []*RightsStatement `json:"rights_statements,omitempty"`
+	LinkedAgents     []*Agent           `json:"linked_agents,ommitempty"`
+	Suppressed       bool               `json:"suppressed,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -1983,7 +2053,7 @@ type Resource struct {
 	FindingAidSponsor           string                   `xml:"control>filedesc>titlestmt>sponsor" json:"finding_aid_sponsor,omitempty"`
 	FindingAidEditionStatement  string                   `json:"finding_aid_edition_statement,omitempty"`
 	FindingAidSeriesStatement   string                   `json:"finding_aid_series_statement,omitempty"`
-	FindingAidStatus            string                   `json:"finging_aid_status,omitempty"`
+	FindingAidStatus            string                   `json:"finding_aid_status,omitempty"`
 	FindingAidNote              string                   `json:"finding_aid_note,omitempty"`
 	RevisionStatements          []*RevisionStatement     `json:"revision_statements,omitempty"`
 	Instances                   []*Instance              `json:"instances,omitempty"`
@@ -2074,6 +2144,10 @@ type RightsStatement struct {
 	RestrictionEndDate     *Date                    `json:"restriction_end_date,omitempty"`
 	GrantedNote            string                   `json:"granted_note,omitempty"`
 	ExternalDocuments      []map[string]interface{} `json:"external_documents"`
+	Status                 string                   `json:"status,omitempty"`
+	StartDate              string                   `json:"start_date,omitempty"`
+	EndDate                string                   `json:"end_date,omitempty"`
+	LinkedAgents           []map[string]interface{} `json:"linked_agents,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -2167,21 +2241,22 @@ type Term struct {
 
 // TopContainer JSONModel(:top_container)
 type TopContainer struct {
-	URI                string                 `json:"uri,omitempty"`
-	Indicator          string                 `json:"indicator,omitempty"`
-	Type               string                 `json:"type,omitempty"`
-	Barcode            string                 `json:"barcode,omitempty"`
-	DisplayString      string                 `json:"display_string,omitempty"`
-	LongDisplayString  string                 `json:"long_display_string,omitempty"`
-	ILSHoldingID       string                 `json:"ils_holding_id,omitempty"`
-	ILSItemID          string                 `json:"ils_item_id,omitempty"`
-	ExportedToILS      string                 `json:"exported_to_ils,omitempty"`
-	Restricted         bool                   `json:"restricted,omitempty"`
-	ActiveRestrictions map[string]interface{} `json:"active_restrictions,omitempty"`
-	ContainerLocations map[string]interface{} `json:"container_locations,omitempty"`
-	ContainerProfile   map[string]interface{} `json:"container_profile,omitempty"`
-	Series             map[string]interface{} `json:"series,omitempty"`
-	Collection         map[string]interface{} `json:"collection,omitempty"`
+	ID                 int                      `json:"id,omitempty"`
+	URI                string                   `json:"uri,omitempty"`
+	Indicator          string                   `json:"indicator,omitempty"`
+	Type               string                   `json:"type,omitempty"`
+	Barcode            string                   `json:"barcode,omitempty"`
+	DisplayString      string                   `json:"display_string,omitempty"`
+	LongDisplayString  string                   `json:"long_display_string,omitempty"`
+	ILSHoldingID       string                   `json:"ils_holding_id,omitempty"`
+	ILSItemID          string                   `json:"ils_item_id,omitempty"`
+	ExportedToILS      string                   `json:"exported_to_ils,omitempty"`
+	Restricted         bool                     `json:"restricted,omitempty"`
+	ActiveRestrictions []map[string]interface{} `json:"active_restrictions,omitempty"`
+	ContainerLocations []*ContainerLocation     `json:"container_locations,omitempty"`
+	ContainerProfile   map[string]interface{}   `json:"container_profile,omitempty"`
+	Series             []map[string]interface{} `json:"series,omitempty"`
+	Collection         []map[string]interface{} `json:"collection,omitempty"`
 
 	LockVersion    json.Number       `json:"lock_version,Number"`
 	JSONModelType  string            `json:"jsonmodel_type,omitempty"`
@@ -2197,12 +2272,13 @@ type TopContainer struct {
 
 // User is a JSONModel used to administer ArchivesSpace
 type User struct {
+	ID           int                    `json:"id,omitempty"`
 	URI          string                 `json:"uri,omitempty"`
 	Username     string                 `json:"username,omitempty"`
 	Name         string                 `json:"name,omitempty"`
 	IsSystemUser bool                   `json:"is_system_user,omitempty"`
-	Permissions  map[string]string      `json:"permissions,omitempty"`
-	Groups       map[string]interface{} `json:"groups,omitempty"`
+	Permissions  map[string][]string    `json:"permissions,omitempty"`
+	Groups       []string               `json:"groups,omitempty"`
 	EMail        string                 `json:"email,omitempty"`
 	FirstName    string                 `json:"first_name,omitempty"`
 	LastName     string                 `json:"last_name,omitempty"`
@@ -2278,9 +2354,7 @@ type Vocabulary struct {
 	Repository     map[string]string `json:"repository,omitempty"`
 }
 
-//
 // String functions for cait public structures
-//
 func stringify(o interface{}) string {
 	src, _ := json.Marshal(o)
 	return string(src)
@@ -2331,7 +2405,7 @@ func (accession *Accession) String() string {
 	return stringify(accession)
 }
 
-//String return a Subject
+// String return a Subject
 func (subject *Subject) String() string {
 	return stringify(subject)
 }
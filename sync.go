@@ -0,0 +1,358 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// SyncCreate is the action for a record missing from the target
+	SyncCreate = "create"
+	// SyncUpdate is the action for a record which differs on the target
+	SyncUpdate = "update"
+	// SyncUnchanged is the action for a record which is the same on both
+	SyncUnchanged = "unchanged"
+)
+
+// SyncIgnoreFields are assigned by each ArchivesSpace so aren't compared by Sync
+var SyncIgnoreFields = []string{
+	"id",
+	"uri",
+	"lock_version",
+	"create_time",
+	"system_mtime",
+	"user_mtime",
+	"created_by",
+	"last_modified_by",
+	"_resolved",
+	"_deleted",
+	"_deleted_time",
+}
+
+// SyncKeyFunc returns the stable key identifying a record in both
+// ArchivesSpace instances, "" when the record has none
+type SyncKeyFunc func(rec map[string]interface{}) string
+
+// AccessionSyncKey returns an accession's identifier, id_0 to id_3 joined with "-"
+func AccessionSyncKey(rec map[string]interface{}) string {
+	parts := []string{}
+	for _, field := range []string{"id_0", "id_1", "id_2", "id_3"} {
+		if s, ok := rec[field].(string); ok == true && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// AgentSyncKey returns the first authority_id of an agent's names
+func AgentSyncKey(rec map[string]interface{}) string {
+	names, _ := rec["names"].([]interface{})
+	for _, name := range names {
+		if m, ok := name.(map[string]interface{}); ok == true {
+			if s, ok := m["authority_id"].(string); ok == true && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// SubjectSyncKey returns a subject's authority_id
+func SubjectSyncKey(rec map[string]interface{}) string {
+	s, _ := rec["authority_id"].(string)
+	return s
+}
+
+// SyncAction is the plan, and once applied the outcome, for one record.
+// Changes lists the fields the target gets from the source, LockVersion
+// is the target's lock_version after an update.
+type SyncAction struct {
+	RecordType  string       `json:"record_type"`
+	Key         string       `json:"key"`
+	Action      string       `json:"action"`
+	Source      string       `json:"source"`
+	Target      string       `json:"target,omitempty"`
+	LockVersion json.Number  `json:"lock_version,omitempty"`
+	Changes     []*FieldDiff `json:"changes,omitempty"`
+	Unresolved  []string     `json:"unresolved,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// syncSource describes a list of records compared by syncRecords
+type syncSource struct {
+	recordType string
+	// sourcePath and targetPath are the list paths on each instance
+	sourcePath string
+	targetPath string
+	key        SyncKeyFunc
+}
+
+// recordMapList is the paginated list at path with records decoded as JSON objects
+func (api *ArchivesSpaceAPI) recordMapList(path string) *RecordList[map[string]interface{}] {
+	return &RecordList[map[string]interface{}]{api: api, path: path, setID: func(*map[string]interface{}) {}}
+}
+
+// repositoryCodes maps repo_code to URI for each repository
+func (api *ArchivesSpaceAPI) repositoryCodes(ctx context.Context) (map[string]string, error) {
+	repos, err := api.ListRepositories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't list the repositories of %s, %w", api.BaseURL, err)
+	}
+	codes := map[string]string{}
+	for _, repo := range repos {
+		codes[repo.RepoCode] = repo.URI
+	}
+	return codes, nil
+}
+
+// vocabularyRefIDs maps ref_id to URI for each vocabulary
+func (api *ArchivesSpaceAPI) vocabularyRefIDs(ctx context.Context) (map[string]string, error) {
+	ids, err := api.ListVocabularies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't list the vocabularies of %s, %w", api.BaseURL, err)
+	}
+	refIDs := map[string]string{}
+	for _, id := range ids {
+		voc, err := api.GetVocabulary(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("Can't get vocabulary %d of %s, %w", id, api.BaseURL, err)
+		}
+		refIDs[voc.RefID] = fmt.Sprintf("/vocabularies/%d", id)
+	}
+	return refIDs, nil
+}
+
+// syncKeys returns the URI of each record of the list at path by its key,
+// the first record with a key wins
+func (api *ArchivesSpaceAPI) syncKeys(ctx context.Context, path string, key SyncKeyFunc) (map[string]string, error) {
+	keys := map[string]string{}
+	err := api.recordMapList(path).Each(ctx, DefaultPageSize, func(rec *map[string]interface{}) error {
+		if k := key(*rec); k != "" && keys[k] == "" {
+			keys[k], _ = (*rec)["uri"].(string)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// syncRecords compares the records of src on api and target by their keys
+// and, unless dryRun is set, creates or updates them on target. Records
+// matched by key are added to uris so later refs to them are rewritten.
+// Only the keys and URIs are kept, the source records are compared a page
+// at a time with the matching target records fetched by id_set.
+func (api *ArchivesSpaceAPI) syncRecords(ctx context.Context, target *ArchivesSpaceAPI, src *syncSource, uris URIMap, dryRun bool) ([]*SyncAction, error) {
+	targetKeys, err := target.syncKeys(ctx, src.targetPath, src.key)
+	if err != nil {
+		return nil, fmt.Errorf("Can't list %s on %s, %w", src.targetPath, target.BaseURL, err)
+	}
+	// Map every matched record first so refs between records of src resolve
+	sourceKeys, err := api.syncKeys(ctx, src.sourcePath, src.key)
+	if err != nil {
+		return nil, fmt.Errorf("Can't list %s on %s, %w", src.sourcePath, api.BaseURL, err)
+	}
+	for key, from := range sourceKeys {
+		if to, ok := targetKeys[key]; ok == true {
+			uris[from] = to
+		}
+	}
+
+	actions := []*SyncAction{}
+	sourceList, targetList := api.recordMapList(src.sourcePath), target.recordMapList(src.targetPath)
+	err = eachPage(func(page int) (*PageInfo, int, error) {
+		recs, info, err := sourceList.Page(ctx, page, DefaultPageSize)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Can't list %s on %s, %w", src.sourcePath, api.BaseURL, err)
+		}
+		ids := []int{}
+		for _, rec := range recs {
+			if to, ok := targetKeys[src.key(*rec)]; ok == true {
+				ids = append(ids, URIToID(to))
+			}
+		}
+		found, err := targetList.Get(ctx, ids)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Can't get %s on %s, %w", src.targetPath, target.BaseURL, err)
+		}
+		byURI := map[string]map[string]interface{}{}
+		for _, rec := range found {
+			uri, _ := (*rec)["uri"].(string)
+			byURI[uri] = *rec
+		}
+		for _, rec := range recs {
+			if action := api.syncRecord(ctx, target, src, *rec, targetKeys, byURI, uris, dryRun); action != nil {
+				actions = append(actions, action)
+			}
+		}
+		return info, len(recs), nil
+	})
+	return actions, err
+}
+
+// syncRecord compares rec with its match in found, looked up by the URI
+// in targetKeys, and unless dryRun is set creates or updates it on target
+func (api *ArchivesSpaceAPI) syncRecord(ctx context.Context, target *ArchivesSpaceAPI, src *syncSource, rec map[string]interface{}, targetKeys map[string]string, found map[string]map[string]interface{}, uris URIMap, dryRun bool) *SyncAction {
+	key := src.key(rec)
+	if key == "" {
+		if api.Verbose == true {
+			log.Printf("Skipping %s, it has no %s key\n", rec["uri"], src.recordType)
+		}
+		return nil
+	}
+	action := &SyncAction{RecordType: src.recordType, Key: key}
+	action.Source, _ = rec["uri"].(string)
+	for _, field := range importSkipFields {
+		delete(rec, field)
+	}
+	action.Unresolved = uris.Remap(rec)
+	existing := map[string]interface{}(nil)
+	if to, ok := targetKeys[key]; ok == true {
+		action.Target, uris[action.Source] = to, to
+		if existing = found[to]; existing == nil {
+			action.Error = fmt.Sprintf("%s was deleted on %s since it was listed", to, target.BaseURL)
+			return action
+		}
+	}
+	if existing == nil {
+		action.Action = SyncCreate
+		action.Changes = DiffRecords(map[string]interface{}{}, rec, SyncIgnoreFields)
+	} else {
+		action.Changes = DiffRecords(existing, rec, SyncIgnoreFields)
+		action.Action = SyncUpdate
+		if len(action.Changes) == 0 {
+			action.Action = SyncUnchanged
+		}
+	}
+	if dryRun == true || action.Action == SyncUnchanged {
+		return action
+	}
+	var (
+		msg *ResponseMsg
+		err error
+	)
+	if action.Action == SyncCreate {
+		msg, err = target.CreateAPI(ctx, target.CallURL(src.targetPath, nil), rec)
+	} else {
+		// The update replaces the whole record, don't overwrite changes
+		// made on the target since it was compared
+		current := map[string]interface{}{}
+		if err := target.GetAPI(ctx, target.CallURL(action.Target, nil), &current); err != nil {
+			action.Error = err.Error()
+			return action
+		}
+		if fmt.Sprintf("%v", current["lock_version"]) != fmt.Sprintf("%v", existing["lock_version"]) {
+			action.Error = fmt.Sprintf("%s changed on %s since it was compared, lock_version %v is now %v", action.Target, target.BaseURL, existing["lock_version"], current["lock_version"])
+			return action
+		}
+		// Updates must carry the target's lock_version or ArchivesSpace rejects them as stale
+		rec["uri"] = action.Target
+		rec["lock_version"] = existing["lock_version"]
+		msg, err = target.UpdateAPI(ctx, target.CallURL(action.Target, nil), rec)
+	}
+	if err != nil {
+		action.Error = err.Error()
+		return action
+	}
+	if action.Action == SyncCreate {
+		action.Target = msg.URI
+		uris[action.Source] = msg.URI
+	}
+	action.LockVersion = msg.LockVersion
+	return action
+}
+
+// Sync makes the subjects, agents and accessions on target match those at
+// api. Subjects and agents are matched by authority_id, accessions by
+// id_0 to id_3 within repositories with the same repo_code. Records missing
+// from target are created, differing ones updated with the target's
+// lock_version, and refs are rewritten to the target's URIs (refs to records
+// which aren't on target are dropped and listed as unresolved). A target
+// record edited since it was compared isn't updated, its action gets an
+// error. With dryRun nothing is changed and the returned actions are the plan.
+func (api *ArchivesSpaceAPI) Sync(ctx context.Context, target *ArchivesSpaceAPI, dryRun bool) ([]*SyncAction, error) {
+	uris := URIMap{}
+	repoCodes, err := api.repositoryCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetCodes, err := target.repositoryCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for code, from := range repoCodes {
+		if to, ok := targetCodes[code]; ok == true {
+			uris[from] = to
+		}
+	}
+	refIDs, err := api.vocabularyRefIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetRefIDs, err := target.vocabularyRefIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for refID, from := range refIDs {
+		if to, ok := targetRefIDs[refID]; ok == true {
+			uris[from] = to
+		}
+	}
+
+	actions := []*SyncAction{}
+	sources := []*syncSource{
+		{recordType: "subject", sourcePath: "/subjects", targetPath: "/subjects", key: SubjectSyncKey},
+	}
+	for _, agentType := range AgentTypes {
+		path := "/agents/" + agentType
+		sources = append(sources, &syncSource{recordType: "agent", sourcePath: path, targetPath: path, key: AgentSyncKey})
+	}
+	repos, err := api.ListRepositories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't list the repositories of %s, %w", api.BaseURL, err)
+	}
+	for _, repo := range repos {
+		to, ok := uris[repo.URI]
+		if ok == false {
+			actions = append(actions, &SyncAction{
+				RecordType: "repository",
+				Key:        repo.RepoCode,
+				Source:     repo.URI,
+				Error:      fmt.Sprintf("no repository with repo_code %q on %s", repo.RepoCode, target.BaseURL),
+			})
+			continue
+		}
+		sources = append(sources, &syncSource{recordType: "accession", sourcePath: repo.URI + "/accessions", targetPath: to + "/accessions", key: AccessionSyncKey})
+	}
+	for _, src := range sources {
+		if api.Verbose == true {
+			log.Printf("Comparing %s with %s\n", src.sourcePath, src.targetPath)
+		}
+		list, err := api.syncRecords(ctx, target, src, uris, dryRun)
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, list...)
+	}
+	return actions, nil
+}
@@ -0,0 +1,303 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// TreeNode is a node returned by the resource tree endpoints
+// (tree/root, tree/node and tree/waypoint). Children are fetched
+// in waypoints of WaypointSize nodes.
+type TreeNode struct {
+	URI                  string                            `json:"uri"`
+	Title                string                            `json:"title,omitempty"`
+	Level                string                            `json:"level,omitempty"`
+	Identifier           string                            `json:"identifier,omitempty"`
+	JSONModelType        string                            `json:"jsonmodel_type,omitempty"`
+	ParentID             int                               `json:"parent_id,omitempty"`
+	Position             int                               `json:"position"`
+	ChildCount           int                               `json:"child_count"`
+	Waypoints            int                               `json:"waypoints"`
+	WaypointSize         int                               `json:"waypoint_size"`
+	Suppressed           bool                              `json:"suppressed,omitempty"`
+	HasDigitalInstance   bool                              `json:"has_digital_instance,omitempty"`
+	PrecomputedWaypoints map[string]map[string][]*TreeNode `json:"precomputed_waypoints,omitempty"`
+}
+
+// getTreeRoot returns the root node of the tree of the record at base
+// (e.g. /repositories/2/resources/1)
+func (api *ArchivesSpaceAPI) getTreeRoot(ctx context.Context, base string) (*TreeNode, error) {
+	node := new(TreeNode)
+	u := api.CallURL(base+"/tree/root", nil)
+	if err := api.GetAPI(ctx, u, node); err != nil {
+		return nil, fmt.Errorf("tree root %s, error, %w", u, err)
+	}
+	return node, nil
+}
+
+// getTreeNode returns the node for nodeURI in the tree of the record at base
+func (api *ArchivesSpaceAPI) getTreeNode(ctx context.Context, base string, nodeURI string) (*TreeNode, error) {
+	node := new(TreeNode)
+	q := url.Values{}
+	q.Set("node_uri", nodeURI)
+	u := api.CallURL(base+"/tree/node", q)
+	if err := api.GetAPI(ctx, u, node); err != nil {
+		return nil, fmt.Errorf("tree node %s, error, %w", u, err)
+	}
+	return node, nil
+}
+
+// getTreeWaypoint returns a waypoint of parentURI's children in the tree
+// of the record at base, an empty parentURI returns the top level children
+func (api *ArchivesSpaceAPI) getTreeWaypoint(ctx context.Context, base string, parentURI string, offset int) ([]*TreeNode, error) {
+	var nodes []*TreeNode
+	q := url.Values{}
+	q.Set("offset", strconv.Itoa(offset))
+	if parentURI != "" {
+		q.Set("parent_node", parentURI)
+	}
+	u := api.CallURL(base+"/tree/waypoint", q)
+	if err := api.GetAPI(ctx, u, &nodes); err != nil {
+		return nil, fmt.Errorf("tree waypoint %s, error, %w", u, err)
+	}
+	return nodes, nil
+}
+
+// children returns the children of node in position order using the
+// precomputed waypoint when available
+func (api *ArchivesSpaceAPI) children(ctx context.Context, base string, node *TreeNode, parentURI string) ([]*TreeNode, error) {
+	var children []*TreeNode
+	for offset := 0; offset < node.Waypoints; offset++ {
+		if nodes, ok := node.PrecomputedWaypoints[parentURI][strconv.Itoa(offset)]; ok == true {
+			children = append(children, nodes...)
+			continue
+		}
+		nodes, err := api.getTreeWaypoint(ctx, base, parentURI, offset)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, nodes...)
+	}
+	return children, nil
+}
+
+// walkTree visits each node of the tree of the record at base depth first
+func (api *ArchivesSpaceAPI) walkTree(ctx context.Context, base string, fn func(node *TreeNode, depth int) error) error {
+	root, err := api.getTreeRoot(ctx, base)
+	if err != nil {
+		return err
+	}
+	var walk func(node *TreeNode, parentURI string, depth int) error
+	walk = func(node *TreeNode, parentURI string, depth int) error {
+		if err := fn(node, depth); err != nil {
+			return err
+		}
+		if node.ChildCount == 0 {
+			return nil
+		}
+		children, err := api.children(ctx, base, node, parentURI)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := walk(child, child.URI, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, "", 0)
+}
+
+// GetResourceTreeRoot returns the root node of a resource's tree
+func (api *ArchivesSpaceAPI) GetResourceTreeRoot(ctx context.Context, repoID, resourceID int) (*TreeNode, error) {
+	return api.getTreeRoot(ctx, fmt.Sprintf("/repositories/%d/resources/%d", repoID, resourceID))
+}
+
+// GetResourceTreeNode returns the node for nodeURI (e.g. /repositories/2/archival_objects/3)
+// in a resource's tree
+func (api *ArchivesSpaceAPI) GetResourceTreeNode(ctx context.Context, repoID, resourceID int, nodeURI string) (*TreeNode, error) {
+	return api.getTreeNode(ctx, fmt.Sprintf("/repositories/%d/resources/%d", repoID, resourceID), nodeURI)
+}
+
+// GetResourceTreeWaypoint returns a waypoint (a batch of children) of parentURI,
+// an empty parentURI returns the top level children of the resource
+func (api *ArchivesSpaceAPI) GetResourceTreeWaypoint(ctx context.Context, repoID, resourceID int, parentURI string, offset int) ([]*TreeNode, error) {
+	return api.getTreeWaypoint(ctx, fmt.Sprintf("/repositories/%d/resources/%d", repoID, resourceID), parentURI, offset)
+}
+
+// WalkResourceTree visits each node of a resource's tree depth first in
+// position order calling fn with the node and its depth (the root is 0).
+// Waypoints are fetched as they are needed, walking stops with the first
+// error returned by fn.
+func (api *ArchivesSpaceAPI) WalkResourceTree(ctx context.Context, repoID, resourceID int, fn func(node *TreeNode, depth int) error) error {
+	return api.walkTree(ctx, fmt.Sprintf("/repositories/%d/resources/%d", repoID, resourceID), fn)
+}
+
+// GetResourceTree returns the complete tree of a resource as a ResourceTree
+func (api *ArchivesSpaceAPI) GetResourceTree(ctx context.Context, repoID, resourceID int) (*ResourceTree, error) {
+	var (
+		root  *ResourceTree
+		stack []*ResourceTree
+	)
+	err := api.WalkResourceTree(ctx, repoID, resourceID, func(node *TreeNode, depth int) error {
+		tree := &ResourceTree{
+			ID:          URIToID(node.URI),
+			RecordURI:   node.URI,
+			Title:       node.Title,
+			Level:       node.Level,
+			HasChildren: node.ChildCount > 0,
+			NodeType:    node.JSONModelType,
+			Suppressed:  node.Suppressed,
+		}
+		if depth == 0 {
+			root = tree
+		} else {
+			parent := stack[depth-1]
+			parent.Children = append(parent.Children, tree)
+		}
+		stack = append(stack[0:depth], tree)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetResourceTree(%d, %d) %w", repoID, resourceID, err)
+	}
+	return root, nil
+}
+
+// Walk visits tree and its children depth first calling fn with each
+// node and its depth, walking stops with the first error returned by fn.
+func (tree *ResourceTree) Walk(fn func(node *ResourceTree, depth int) error) error {
+	var walk func(node *ResourceTree, depth int) error
+	walk = func(node *ResourceTree, depth int) error {
+		if err := fn(node, depth); err != nil {
+			return err
+		}
+		for _, child := range node.Children {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(tree, 0)
+}
+
+// WalkClassificationTree visits each node of a classification's tree (the
+// classification and its terms) depth first in position order calling fn
+// with the node and its depth (the root is 0).
+func (api *ArchivesSpaceAPI) WalkClassificationTree(ctx context.Context, repoID, classificationID int, fn func(node *TreeNode, depth int) error) error {
+	return api.walkTree(ctx, fmt.Sprintf("/repositories/%d/classifications/%d", repoID, classificationID), fn)
+}
+
+// GetClassificationTree returns the complete tree of a classification as a ClassificationTree
+func (api *ArchivesSpaceAPI) GetClassificationTree(ctx context.Context, repoID, classificationID int) (*ClassificationTree, error) {
+	var (
+		root  *ClassificationTree
+		stack []*ClassificationTree
+	)
+	err := api.WalkClassificationTree(ctx, repoID, classificationID, func(node *TreeNode, depth int) error {
+		tree := &ClassificationTree{
+			ID:          URIToID(node.URI),
+			RecordURI:   node.URI,
+			Title:       node.Title,
+			Identifier:  node.Identifier,
+			HasChildren: node.ChildCount > 0,
+			NodeType:    node.JSONModelType,
+			Suppressed:  node.Suppressed,
+		}
+		if depth == 0 {
+			root = tree
+		} else {
+			parent := stack[depth-1]
+			parent.Children = append(parent.Children, tree)
+		}
+		stack = append(stack[0:depth], tree)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetClassificationTree(%d, %d) %w", repoID, classificationID, err)
+	}
+	return root, nil
+}
+
+// WalkDigitalObjectTree visits each node of a digital object's tree (the
+// digital object and its components) depth first in position order calling
+// fn with the node and its depth (the root is 0).
+func (api *ArchivesSpaceAPI) WalkDigitalObjectTree(ctx context.Context, repoID, objID int, fn func(node *TreeNode, depth int) error) error {
+	return api.walkTree(ctx, fmt.Sprintf("/repositories/%d/digital_objects/%d", repoID, objID), fn)
+}
+
+// GetDigitalObjectTree returns the complete tree of a digital object as a DigitalObjectTree
+func (api *ArchivesSpaceAPI) GetDigitalObjectTree(ctx context.Context, repoID, objID int) (*DigitalObjectTree, error) {
+	var (
+		root  *DigitalObjectTree
+		stack []*DigitalObjectTree
+	)
+	err := api.WalkDigitalObjectTree(ctx, repoID, objID, func(node *TreeNode, depth int) error {
+		tree := &DigitalObjectTree{
+			ID:          URIToID(node.URI),
+			RecordURI:   node.URI,
+			Title:       node.Title,
+			Level:       node.Level,
+			HasChildren: node.ChildCount > 0,
+			NodeType:    node.JSONModelType,
+			Suppressed:  node.Suppressed,
+		}
+		if depth == 0 {
+			root = tree
+		} else {
+			parent := stack[depth-1]
+			parent.Children = append(parent.Children, tree)
+		}
+		stack = append(stack[0:depth], tree)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetDigitalObjectTree(%d, %d) %w", repoID, objID, err)
+	}
+	return root, nil
+}
+
+// ResolveDigitalObjectComponents fetches a digital object's components and
+// attaches them, in tree order, to obj.Components
+func (api *ArchivesSpaceAPI) ResolveDigitalObjectComponents(ctx context.Context, obj *DigitalObject) ([]*DigitalObjectComponent, error) {
+	var ids []int
+	repoID, objID := URIToRepoID(obj.URI), URIToID(obj.URI)
+	err := api.WalkDigitalObjectTree(ctx, repoID, objID, func(node *TreeNode, depth int) error {
+		if depth > 0 {
+			ids = append(ids, URIToID(node.URI))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can't walk the tree of %s, %w", obj.URI, err)
+	}
+	components, err := api.DigitalObjectComponentList(repoID).Get(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get components of %s, %w", obj.URI, err)
+	}
+	obj.Components = SortDigitalObjectComponents(components)
+	return obj.Components, nil
+}
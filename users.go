@@ -0,0 +1,336 @@
+//
+// Package cait is a collection of structures and functions
+// for interacting with ArchivesSpace's REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2016, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package cait
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RosterEntry describes an account to provision, see ReadRoster
+type RosterEntry struct {
+	Username  string   `json:"username"`
+	Name      string   `json:"name,omitempty"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	EMail     string   `json:"email,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	RepoID    int      `json:"repo_id,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+}
+
+// CreateUser creates a new user account with password
+func (api *ArchivesSpaceAPI) CreateUser(ctx context.Context, user *User, password string) (*ResponseMsg, error) {
+	if password == "" {
+		return nil, fmt.Errorf("Can't create user %q without a password", user.Username)
+	}
+	user.JSONModelType = "user"
+	user.LockVersion = "0"
+	q := url.Values{}
+	q.Set("password", password)
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL("/users", q), user)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	user.URI = responseMsg.URI
+	user.ID = responseMsg.ID
+	user.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetUser retrieves a user account by id
+func (api *ArchivesSpaceAPI) GetUser(ctx context.Context, userID int) (*User, error) {
+	user := new(User)
+	u := api.CallURL(fmt.Sprintf("/users/%d", userID), nil)
+	if err := api.GetAPI(ctx, u, user); err != nil {
+		return nil, fmt.Errorf("GetUser() %s, error, %w", u, err)
+	}
+	user.ID = URIToID(user.URI)
+	return user, nil
+}
+
+// GetUserByUsername retrieves a user account by username
+func (api *ArchivesSpaceAPI) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	user := new(User)
+	u := api.CallURL(fmt.Sprintf("/users/byusername/%s", url.PathEscape(username)), nil)
+	if err := api.GetAPI(ctx, u, user); err != nil {
+		return nil, fmt.Errorf("GetUserByUsername() %s, error, %w", u, err)
+	}
+	user.ID = URIToID(user.URI)
+	return user, nil
+}
+
+// UpdateUser updates an existing user account, the password is
+// left unchanged when password is an empty string
+func (api *ArchivesSpaceAPI) UpdateUser(ctx context.Context, user *User, password string) (*ResponseMsg, error) {
+	q := url.Values{}
+	if password != "" {
+		q.Set("password", password)
+	}
+	return api.UpdateAPI(ctx, api.CallURL(user.URI, q), user)
+}
+
+// DeleteUser deletes a user account
+func (api *ArchivesSpaceAPI) DeleteUser(ctx context.Context, user *User) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(user.URI, nil), user)
+}
+
+// ListUsers returns a list of user ids
+func (api *ArchivesSpaceAPI) ListUsers(ctx context.Context) ([]int, error) {
+	q := url.Values{}
+	q.Set("all_ids", "true")
+	return api.ListAPI(ctx, api.CallURL(`/users`, q))
+}
+
+// SetUserGroups adds a user to the groups (by URI) of a Repository, if replace is true
+// the user is first removed from the Repository's other groups
+func (api *ArchivesSpaceAPI) SetUserGroups(ctx context.Context, userID, repoID int, groupURIs []string, replace bool) (*ResponseMsg, error) {
+	q := url.Values{}
+	q.Set("repo_id", strconv.Itoa(repoID))
+	q.Set("remove_groups", strconv.FormatBool(replace))
+	for _, groupURI := range groupURIs {
+		q.Add("groups[]", groupURI)
+	}
+	return api.UpdateAPI(ctx, api.CallURL(fmt.Sprintf("/users/%d/groups", userID), q), nil)
+}
+
+// CreateGroup creates a new group in a Repository
+func (api *ArchivesSpaceAPI) CreateGroup(ctx context.Context, repoID int, group *Group) (*ResponseMsg, error) {
+	group.JSONModelType = "group"
+	group.LockVersion = "0"
+	responseMsg, responseErr := api.CreateAPI(ctx, api.CallURL(fmt.Sprintf("/repositories/%d/groups", repoID), nil), group)
+	if responseErr != nil || responseMsg.Status != "Created" {
+		return responseMsg, responseErr
+	}
+	group.URI = responseMsg.URI
+	group.ID = responseMsg.ID
+	group.LockVersion = responseMsg.LockVersion
+	return responseMsg, responseErr
+}
+
+// GetGroup retrieves a group including its member usernames
+func (api *ArchivesSpaceAPI) GetGroup(ctx context.Context, repoID, groupID int) (*Group, error) {
+	group := new(Group)
+	q := url.Values{}
+	q.Set("list_members", "true")
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/groups/%d", repoID, groupID), q)
+	if err := api.GetAPI(ctx, u, group); err != nil {
+		return nil, fmt.Errorf("GetGroup() %s, error, %w", u, err)
+	}
+	group.ID = URIToID(group.URI)
+	return group, nil
+}
+
+// UpdateGroup updates an existing group. Membership is replaced by
+// group.MemberUsernames unless it is nil.
+func (api *ArchivesSpaceAPI) UpdateGroup(ctx context.Context, group *Group) (*ResponseMsg, error) {
+	q := url.Values{}
+	q.Set("with_members", strconv.FormatBool(group.MemberUsernames != nil))
+	return api.UpdateAPI(ctx, api.CallURL(group.URI, q), group)
+}
+
+// DeleteGroup deletes a group
+func (api *ArchivesSpaceAPI) DeleteGroup(ctx context.Context, group *Group) (*ResponseMsg, error) {
+	return api.DeleteAPI(ctx, api.CallURL(group.URI, nil), group)
+}
+
+// ListGroups returns the groups of a Repository
+func (api *ArchivesSpaceAPI) ListGroups(ctx context.Context, repoID int) ([]*Group, error) {
+	var groups []*Group
+	u := api.CallURL(fmt.Sprintf("/repositories/%d/groups", repoID), nil)
+	if err := api.GetAPI(ctx, u, &groups); err != nil {
+		return nil, fmt.Errorf("ListGroups() %s, error, %w", u, err)
+	}
+	for _, group := range groups {
+		group.ID = URIToID(group.URI)
+	}
+	return groups, nil
+}
+
+// AddGroupMembers adds usernames to a group's membership
+func (api *ArchivesSpaceAPI) AddGroupMembers(ctx context.Context, repoID, groupID int, usernames ...string) (*ResponseMsg, error) {
+	group, err := api.GetGroup(ctx, repoID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	members := append([]string{}, group.MemberUsernames...)
+	for _, username := range usernames {
+		if containsString(members, username) == false {
+			members = append(members, username)
+		}
+	}
+	group.MemberUsernames = members
+	return api.UpdateGroup(ctx, group)
+}
+
+// RemoveGroupMembers removes usernames from a group's membership
+func (api *ArchivesSpaceAPI) RemoveGroupMembers(ctx context.Context, repoID, groupID int, usernames ...string) (*ResponseMsg, error) {
+	group, err := api.GetGroup(ctx, repoID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	members := []string{}
+	for _, username := range group.MemberUsernames {
+		if containsString(usernames, username) == false {
+			members = append(members, username)
+		}
+	}
+	group.MemberUsernames = members
+	return api.UpdateGroup(ctx, group)
+}
+
+// containsString returns true if s is in l
+func containsString(l []string, s string) bool {
+	for _, item := range l {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadRoster reads a CSV roster of accounts to provision. The first row names
+// the columns: username (required), name, first_name, last_name, email,
+// password, repo_id and groups (group codes separated by semicolons).
+func ReadRoster(r io.Reader) ([]*RosterEntry, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Can't read roster, %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	cols := map[string]int{}
+	for i, name := range rows[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["username"]; ok == false {
+		return nil, fmt.Errorf("Roster is missing a username column")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := cols[name]; ok == true && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	var entries []*RosterEntry
+	for n, row := range rows[1:] {
+		entry := &RosterEntry{
+			Username:  get(row, "username"),
+			Name:      get(row, "name"),
+			FirstName: get(row, "first_name"),
+			LastName:  get(row, "last_name"),
+			EMail:     get(row, "email"),
+			Password:  get(row, "password"),
+		}
+		if entry.Username == "" {
+			return nil, fmt.Errorf("Roster row %d is missing a username", n+2)
+		}
+		if entry.Name == "" {
+			entry.Name = strings.TrimSpace(entry.FirstName + " " + entry.LastName)
+		}
+		if s := get(row, "repo_id"); s != "" {
+			if entry.RepoID, err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("Roster row %d, repo_id %q is not a number", n+2, s)
+			}
+		}
+		for _, code := range strings.Split(get(row, "groups"), ";") {
+			if code = strings.TrimSpace(code); code != "" {
+				entry.Groups = append(entry.Groups, code)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ProvisionUser creates the account for entry if it doesn't exist, otherwise
+// updates the account's name and contact details. If entry.Groups is not
+// empty the account's groups in entry.RepoID are set to those group codes.
+func (api *ArchivesSpaceAPI) ProvisionUser(ctx context.Context, entry *RosterEntry) (*ResponseMsg, error) {
+	var (
+		responseMsg *ResponseMsg
+		apiErr      *APIError
+	)
+	user, err := api.GetUserByUsername(ctx, entry.Username)
+	switch {
+	case err == nil:
+		if entry.Name != "" {
+			user.Name = entry.Name
+		}
+		if entry.FirstName != "" {
+			user.FirstName = entry.FirstName
+		}
+		if entry.LastName != "" {
+			user.LastName = entry.LastName
+		}
+		if entry.EMail != "" {
+			user.EMail = entry.EMail
+		}
+		responseMsg, err = api.UpdateUser(ctx, user, entry.Password)
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		user = &User{
+			Username:  entry.Username,
+			Name:      entry.Name,
+			FirstName: entry.FirstName,
+			LastName:  entry.LastName,
+			EMail:     entry.EMail,
+		}
+		responseMsg, err = api.CreateUser(ctx, user, entry.Password)
+	}
+	if err != nil {
+		return responseMsg, fmt.Errorf("Can't provision %s, %w", entry.Username, err)
+	}
+	if len(entry.Groups) == 0 {
+		return responseMsg, nil
+	}
+	if entry.RepoID == 0 {
+		return responseMsg, fmt.Errorf("Can't assign groups to %s without a repo_id", entry.Username)
+	}
+	groups, err := api.ListGroups(ctx, entry.RepoID)
+	if err != nil {
+		return responseMsg, fmt.Errorf("Can't assign groups to %s, %w", entry.Username, err)
+	}
+	var groupURIs []string
+	for _, code := range entry.Groups {
+		found := false
+		for _, group := range groups {
+			if group.GroupCode == code {
+				groupURIs = append(groupURIs, group.URI)
+				found = true
+				break
+			}
+		}
+		if found == false {
+			return responseMsg, fmt.Errorf("Can't assign %s to group %q, no such group in repository %d", entry.Username, code, entry.RepoID)
+		}
+	}
+	if _, err := api.SetUserGroups(ctx, URIToID(user.URI), entry.RepoID, groupURIs, true); err != nil {
+		return responseMsg, fmt.Errorf("Can't assign groups to %s, %w", entry.Username, err)
+	}
+	return responseMsg, nil
+}
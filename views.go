@@ -55,44 +55,60 @@ type NavView []*NavElementView
 
 // NormalizedDigitalObjectView returns a structure suitable for templating public web content.
 type NormalizedDigitalObjectView struct {
-	URI               string   `json:"uri"`
-	Title             string   `json:"title"`
-	DigitalObjectType string   `json:"digital_object_type"`
-	Publish           bool     `json:"publish"`
-	FileURIs          []string `json:"file_uris"`
+	URI               string                                  `json:"uri"`
+	Title             string                                  `json:"title"`
+	DigitalObjectType string                                  `json:"digital_object_type"`
+	Publish           bool                                    `json:"publish"`
+	FileURIs          []string                                `json:"file_uris"`
+	FileVersions      []*FileVersion                          `json:"file_versions,omitempty"`
+	Components        []*NormalizedDigitalObjectComponentView `json:"components,omitempty"`
+}
+
+// NormalizedDigitalObjectComponentView returns a structure suitable for templating
+// the parts (e.g. the tapes of an oral history) of a digital object, Depth is 1
+// for the top level components.
+type NormalizedDigitalObjectComponentView struct {
+	URI          string         `json:"uri"`
+	Title        string         `json:"title"`
+	Label        string         `json:"label,omitempty"`
+	ComponentID  string         `json:"component_id,omitempty"`
+	Depth        int            `json:"depth"`
+	FileURIs     []string       `json:"file_uris"`
+	FileVersions []*FileVersion `json:"file_versions,omitempty"`
 }
 
 // NormalizedAccessionView returns a structure suitable for templating public web content.
 type NormalizedAccessionView struct {
-	ID                     string                         `json:"id"`
-	URI                    string                         `json:"uri"`
-	Title                  string                         `json:"title"`
-	Identifier             string                         `json:"identifier"`
-	ResourceType           string                         `json:"resource_type"`
-	ContentDescription     string                         `json:"content_description"`
-	ConditionDescription   string                         `json:"condition_description,omitempty"`
-	AccessRestrictions     bool                           `json:"access_restrictions"`
-	AccessRestrictionsNote string                         `json:"access_restrictions_notes"`
-	UseRestrictions        bool                           `json:"use_restrictions"`
-	UseRestrictionsNote    string                         `json:"use_restrictions_notes"`
-	Dates                  []*Date                        `json:"dates"`
-	DateExpression         string                         `json:"date_expression"`
-	Subjects               []string                       `json:"subjects,omitempty"`
-	SubjectsFunction       []string                       `json:"subjects_function,omitempty"`
-	SubjectsTopical        []string                       `json:"subjects_topical,omitempty"`
-	Extents                []string                       `json:"extents"`
-	RelatedResources       []string                       `json:"related_resources,omitempty"`
-	RelatedAccessions      []string                       `json:"related_accessions,omitempty"`
-	DigitalObjects         []*NormalizedDigitalObjectView `json:"digital_objects,omitempty"`
-	Deaccessions           string                         `json:"deaccessions,omitempty"`
-	LinkedAgentsCreators   []string                       `json:"linked_agents_creators"`
-	LinkedAgentsSubjects   []string                       `json:"linked_agents_subjects"`
-	LinkedAgentsSources    []string                       `json:"linked_agents_sources,omitempty"`
-	AccessionDate          string                         `json:"accession_date"`
-	CreatedBy              string                         `json:"created_by"`
-	Created                string                         `json:"created"`
-	LastModifiedBy         string                         `json:"last_modified_by"`
-	LastModified           string                         `json:"last_modified"`
+	ID                     string                          `json:"id"`
+	URI                    string                          `json:"uri"`
+	Title                  string                          `json:"title"`
+	Identifier             string                          `json:"identifier"`
+	ResourceType           string                          `json:"resource_type"`
+	ContentDescription     string                          `json:"content_description"`
+	ConditionDescription   string                          `json:"condition_description,omitempty"`
+	AccessRestrictions     bool                            `json:"access_restrictions"`
+	AccessRestrictionsNote string                          `json:"access_restrictions_notes"`
+	UseRestrictions        bool                            `json:"use_restrictions"`
+	UseRestrictionsNote    string                          `json:"use_restrictions_notes"`
+	Dates                  []*Date                         `json:"dates"`
+	DateExpression         string                          `json:"date_expression"`
+	Subjects               []string                        `json:"subjects,omitempty"`
+	SubjectsFunction       []string                        `json:"subjects_function,omitempty"`
+	SubjectsTopical        []string                        `json:"subjects_topical,omitempty"`
+	Extents                []string                        `json:"extents"`
+	RelatedResources       []string                        `json:"related_resources,omitempty"`
+	RelatedAccessions      []string                        `json:"related_accessions,omitempty"`
+	DigitalObjects         []*NormalizedDigitalObjectView  `json:"digital_objects,omitempty"`
+	Classifications        []*NormalizedClassificationView `json:"classifications,omitempty"`
+	Deaccessions           string                          `json:"deaccessions,omitempty"`
+	LinkedAgentsCreators   []string                        `json:"linked_agents_creators"`
+	LinkedAgentsSubjects   []string                        `json:"linked_agents_subjects"`
+	LinkedAgentsSources    []string                        `json:"linked_agents_sources,omitempty"`
+	AccessionDate          string                          `json:"accession_date"`
+	CreatedBy              string                          `json:"created_by"`
+	Created                string                          `json:"created"`
+	LastModifiedBy         string                          `json:"last_modified_by"`
+	LastModified           string                          `json:"last_modified"`
 }
 
 // FlattenDates takes an array of Date types, flatten it into a human readable string.
@@ -113,8 +129,9 @@ func FlattenDates(dates []*Date) string {
 }
 
 // NormalizeView returns a normalized view from an Accession structure and
-// an array of subject structures.
-func (a *Accession) NormalizeView(agents []*Agent, subjects map[string]*Subject, digitalObjects map[string]*DigitalObject) (*NormalizedAccessionView, error) {
+// an array of subject structures. Classification refs are resolved with
+// classifications, or the "_resolved" view added on export, if classifications is nil.
+func (a *Accession) NormalizeView(agents []*Agent, subjects map[string]*Subject, digitalObjects map[string]*DigitalObject, classifications *ClassificationMap) (*NormalizedAccessionView, error) {
 	agentMap := make(map[string]string)
 	for _, agent := range agents {
 		title := agent.Title
@@ -173,6 +190,19 @@ func (a *Accession) NormalizeView(agents []*Agent, subjects map[string]*Subject,
 			}
 		}
 	}
+	for _, item := range a.Classifications {
+		if classifications != nil {
+			if view := classifications.Resolve(refString(item)); view != nil {
+				v.Classifications = append(v.Classifications, view)
+			}
+		} else if m, ok := item["_resolved"]; ok == true {
+			view := new(NormalizedClassificationView)
+			src, _ := json.Marshal(m)
+			if err := json.Unmarshal(src, &view); err == nil {
+				v.Classifications = append(v.Classifications, view)
+			}
+		}
+	}
 	//NOTE: Normalized view adds Linked Agents by type creator, subject, sources ...
 	for _, item := range a.LinkedAgents {
 		if ref, ok := item["ref"].(string); ok == true {
@@ -194,7 +224,8 @@ func (a *Accession) NormalizeView(agents []*Agent, subjects map[string]*Subject,
 
 //FIXME: NormalizeView takes an Agent/People object and returns a normalized view
 
-// NormalizeView takes a digital object and returns a normalized view
+// NormalizeView takes a digital object and returns a normalized view, published
+// components (see ResolveDigitalObjectComponents) are listed in tree order
 func (o *DigitalObject) NormalizeView() *NormalizedDigitalObjectView {
 	result := new(NormalizedDigitalObjectView)
 	result.URI = o.URI
@@ -205,15 +236,74 @@ func (o *DigitalObject) NormalizeView() *NormalizedDigitalObjectView {
 		if fv.FileURI != "" {
 			result.FileURIs = append(result.FileURIs, fv.FileURI)
 		}
+		result.FileVersions = append(result.FileVersions, fv)
+	}
+	depths := map[string]int{}
+	for _, c := range o.Components {
+		depth := depths[refString(c.Parent)] + 1
+		depths[c.URI] = depth
+		if c.Publish == false || c.Suppressed == true || c.HasUnpublishedAncestor == true {
+			continue
+		}
+		view := new(NormalizedDigitalObjectComponentView)
+		view.URI = c.URI
+		view.Title = c.Title
+		if view.Title == "" {
+			view.Title = c.DisplayString
+		}
+		view.Label = c.Label
+		view.ComponentID = c.ComponentID
+		view.Depth = depth
+		for _, fv := range c.FileVersions {
+			if fv.FileURI != "" {
+				view.FileURIs = append(view.FileURIs, fv.FileURI)
+			}
+			view.FileVersions = append(view.FileVersions, fv)
+		}
+		result.Components = append(result.Components, view)
 	}
 	return result
 }
 
-// MakeAgentList given a base data directory read in the subject JSON blobs and builds
-// a slice or subject data. Takes the path to the subjects directory as a parameter.
-func (api *ArchivesSpaceAPI) MakeAgentList(dname string) ([]*Agent, error) {
+// SortDigitalObjectComponents returns components in tree order, depth first
+// following their parent refs ordered by position.
+func SortDigitalObjectComponents(components []*DigitalObjectComponent) []*DigitalObjectComponent {
+	children := map[string][]*DigitalObjectComponent{}
+	known := map[string]bool{}
+	for _, c := range components {
+		known[c.URI] = true
+	}
+	for _, c := range components {
+		parent := refString(c.Parent)
+		if known[parent] == false {
+			parent = ""
+		}
+		children[parent] = append(children[parent], c)
+	}
+	var (
+		walk   func(parent string)
+		sorted []*DigitalObjectComponent
+	)
+	walk = func(parent string) {
+		nodes := children[parent]
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].Position < nodes[j].Position
+		})
+		for _, c := range nodes {
+			sorted = append(sorted, c)
+			walk(c.URI)
+		}
+	}
+	walk("")
+	return sorted
+}
+
+// MakeAgentList reads the exported agents of agentType (e.g. people) and builds
+// a slice of agent data. The collection is named by the api's Layout.
+func (api *ArchivesSpaceAPI) MakeAgentList(agentType string) ([]*Agent, error) {
 	var agents []*Agent
 
+	dname := api.Collection("agent", agentType)
 	c, err := OpenCollection(api, dname)
 	if err != nil {
 		return nil, fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
@@ -259,10 +349,11 @@ func (s subjectList) HasSubject(a string) bool {
 	return false
 }
 
-// MakeSubjectList given a base data directory read in the subject JSON blobs and builds
-// a slice or subject data. Takes the path to the subjects directory as a parameter.
-func (api *ArchivesSpaceAPI) MakeSubjectList(dname string) ([]string, error) {
+// MakeSubjectList reads the exported subjects and builds a sorted slice of
+// published subject terms. The collection is named by the api's Layout.
+func (api *ArchivesSpaceAPI) MakeSubjectList() ([]string, error) {
 	var subjects subjectList
+	dname := api.Collection("subject")
 	c, err := OpenCollection(api, dname)
 	if err != nil {
 		return nil, fmt.Errorf("Can't open collection %s/%s, %s", api.Dataset, dname, err)
@@ -296,11 +387,12 @@ func (api *ArchivesSpaceAPI) MakeSubjectList(dname string) ([]string, error) {
 	return subjects, nil
 }
 
-// MakeSubjectMap given a base data directory read in the subject JSON blobs and builds
-// a map or subject data. Takes the path to the subjects directory as a parameter.
-func (api *ArchivesSpaceAPI) MakeSubjectMap(dname string) (map[string]*Subject, error) {
+// MakeSubjectMap reads the exported subjects and builds a map of subject data
+// by URI. The collection is named by the api's Layout.
+func (api *ArchivesSpaceAPI) MakeSubjectMap() (map[string]*Subject, error) {
 	subjects := make(map[string]*Subject)
 
+	dname := api.Collection("subject")
 	c, err := OpenCollection(api, dname)
 	if err != nil {
 		return nil, fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
@@ -323,11 +415,12 @@ func (api *ArchivesSpaceAPI) MakeSubjectMap(dname string) (map[string]*Subject,
 	return subjects, nil
 }
 
-// MakeDigitalObjectMap given a base data directory read in the Digital Object JSON blobs
-// and build a map of object data. Takes the path to the subjects directory as a parameter.
-func (api *ArchivesSpaceAPI) MakeDigitalObjectMap(dname string) (map[string]*DigitalObject, error) {
+// MakeDigitalObjectMap reads the exported Digital Objects of a repository and builds
+// a map of object data by URI. The collection is named by the api's Layout.
+func (api *ArchivesSpaceAPI) MakeDigitalObjectMap(repoID int) (map[string]*DigitalObject, error) {
 	digitalObjects := make(map[string]*DigitalObject)
 
+	dname := api.Collection("digital_object", repoID)
 	c, err := OpenCollection(api, dname)
 	if err != nil {
 		return nil, fmt.Errorf("Can't open collection %s, %s", api.Dataset, err)
@@ -351,15 +444,51 @@ func (api *ArchivesSpaceAPI) MakeDigitalObjectMap(dname string) (map[string]*Dig
 
 }
 
+// AttachDigitalObjectComponents given the digital object map from MakeDigitalObjectMap
+// reads the repository's digital object components and attaches them, in tree order,
+// to their digital object. Returns the number of components attached.
+func (api *ArchivesSpaceAPI) AttachDigitalObjectComponents(digitalObjects map[string]*DigitalObject, repoID int) (int, error) {
+	dname := api.Collection("digital_object_component", repoID)
+	c, err := OpenCollection(api, dname)
+	if err != nil {
+		return 0, fmt.Errorf("Can't open collection %s/%s, %s", api.Dataset, dname, err)
+	}
+	defer c.Close()
+
+	components := map[string][]*DigitalObjectComponent{}
+	for _, key := range GetKeys(c) {
+		src, err := ReadJSON(c, key)
+		if err != nil {
+			return 0, fmt.Errorf("Can't read Digital Object Component %s, %s", key, err)
+		}
+		component := new(DigitalObjectComponent)
+		err = json.Unmarshal(src, &component)
+		if err != nil {
+			return 0, fmt.Errorf("Can't parse Digital Object Component %s, %s", key, err)
+		}
+		ref := refString(component.DigitalObject)
+		components[ref] = append(components[ref], component)
+	}
+	cnt := 0
+	for ref, list := range components {
+		if obj, ok := digitalObjects[ref]; ok == true {
+			obj.Components = SortDigitalObjectComponents(list)
+			cnt += len(list)
+		}
+	}
+	return cnt, nil
+}
+
 //
 // Browsing data
 //
 
-// MakeAccessionTitleIndex crawls the path for accession records and generates
+// MakeAccessionTitleIndex reads a repository's accession records and generates
 // a map of navigation links that can be used in search results or browsing views.
-// The parameter dname usually is set to the value of $CAIT_DATASET
+// The collection is named by the api's Layout.
 // Output is a map of URI pointing at NavElementView for that URI.
-func (api *ArchivesSpaceAPI) MakeAccessionTitleIndex(dname string) (map[string]*NavElementView, error) {
+func (api *ArchivesSpaceAPI) MakeAccessionTitleIndex(repoID int) (map[string]*NavElementView, error) {
+	dname := api.Collection("accession", repoID)
 	// Title index keyed by URI
 	titleIndex := make(map[string]*NavElementView)
 	titlesWithURI := []string{}